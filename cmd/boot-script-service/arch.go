@@ -0,0 +1,349 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+/*
+ * Per-architecture boot images
+ *
+ * A role may have nodes of more than one architecture.  Host, role and
+ * profile entries can carry a variant for each architecture, with its own
+ * kernel, initrd and params:
+ *
+ *   "arch-variants": {"arm64": {"kernel": "s3://boot-images/arm64/kernel"}}
+ *
+ * A node booting with an entry which has variants uses the kernel and initrd
+ * of the variant for its architecture in place of the entry's, and the
+ * variant's params are appended to the entry's.  The architecture is the
+ * arch= of the bootscript request, or else the one HSM has for the node.  If
+ * neither is known, the node is chained back to us with its architecture.
+ *
+ * Architecture names are normalized, so that aarch64 and arm64 are the same
+ * variant, as are amd64, i386, i686 and x86_64.  Other names than these and
+ * riscv64 are refused for variants, and ignored in requests.  When a role
+ * entry with variants is stored, every architecture HSM has for the nodes of
+ * the role must have a kernel.
+ */
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+const (
+	archX86_64  = "x86_64"
+	archArm64   = "arm64"
+	archRiscv64 = "riscv64"
+)
+
+// The stored form of an architecture variant, with image storage keys as in
+// BootDataStore.
+type archVariantStore struct {
+	Params string `json:"params,omitempty"`
+	Kernel string `json:"kernel,omitempty"` // Image storage key
+	Initrd string `json:"initrd,omitempty"` // Image storage key
+}
+
+type archVariantData struct {
+	Params string
+	Kernel ImageData
+	Initrd ImageData
+}
+
+// The normal name of an architecture, as given by iPXE, GRUB or HSM.  An
+// empty string is returned if the architecture is not known.
+func normalizeArch(arch string) string {
+	a := strings.ToLower(strings.TrimSpace(arch))
+	// GRUB platforms, such as x86_64-efi and i386-pc
+	if i := strings.IndexByte(a, '-'); i > 0 {
+		a = a[:i]
+	}
+	switch a {
+	case "x86_64", "amd64", "x64", "x86", "i386", "i486", "i586", "i686", "ia32":
+		// 32-bit iPXE, such as undionly.kpxe, also boots 64-bit nodes
+		return archX86_64
+	case "arm64", "aarch64", "arm":
+		// HSM has ARM for arm64 nodes
+		return archArm64
+	case archRiscv64:
+		return archRiscv64
+	default:
+		return ""
+	}
+}
+
+// The architecture of a booting node: the arch= of its request, or else the
+// one HSM has for it.
+func nodeArch(arch string, comp SMComponent) string {
+	if a := normalizeArch(arch); a != "" {
+		return a
+	}
+	return normalizeArch(comp.Arch)
+}
+
+// Check the variants of a request, returning their stored form and the
+// writes of their images, along with the image checks for the caller to
+// record.  Empty variants are kept, for mergeArchVariants() to remove.
+func storeArchVariants(variants map[string]bssTypes.ArchVariant) (map[string]archVariantStore, []kvOp, []imageCheck, error) {
+	if len(variants) == 0 {
+		return nil, nil, nil, nil
+	}
+	arches := make([]string, 0, len(variants))
+	for arch := range variants {
+		arches = append(arches, arch)
+	}
+	sort.Strings(arches)
+
+	var checks []imageCheck
+	var images []kvOp
+	ret := make(map[string]archVariantStore, len(variants))
+	for _, arch := range arches {
+		v := variants[arch]
+		a := normalizeArch(arch)
+		if a == "" {
+			return nil, nil, checks, paramsError(fmt.Sprintf("Invalid architecture '%s'", arch))
+		}
+		if _, dup := ret[a]; dup {
+			return nil, nil, checks, paramsError(fmt.Sprintf("Architecture %s has more than one variant", a))
+		}
+		if err := checkParams(v.Params); err != nil {
+			return nil, nil, checks, err
+		}
+		c, err := checkImagePaths(v.Kernel, v.Initrd)
+		checks = append(checks, c...)
+		if err != nil {
+			return nil, nil, checks, err
+		}
+		kernelOp, initrdOp, err := imageOps(v.Kernel, v.Initrd)
+		if err != nil {
+			return nil, nil, checks, err
+		}
+		images = appendImageOps(images, kernelOp, initrdOp)
+		ret[a] = archVariantStore{Params: v.Params, Kernel: kernelOp.key, Initrd: initrdOp.key}
+	}
+	return ret, images, checks, nil
+}
+
+// Replace the variants of the architectures in update.  An empty variant
+// removes its architecture.
+func mergeArchVariants(old, update map[string]archVariantStore) map[string]archVariantStore {
+	ret := make(map[string]archVariantStore, len(old)+len(update))
+	for a, v := range old {
+		ret[a] = v
+	}
+	for a, v := range update {
+		if v == (archVariantStore{}) {
+			delete(ret, a)
+		} else {
+			ret[a] = v
+		}
+	}
+	if len(ret) == 0 {
+		return nil
+	}
+	return ret
+}
+
+// Lay the variants of a more specific entry over those of a less specific
+// one.  The fields set in a variant of over win.
+func overlayArchVariants(under, over map[string]archVariantStore) map[string]archVariantStore {
+	if len(over) == 0 {
+		return under
+	}
+	if len(under) == 0 {
+		return over
+	}
+	ret := make(map[string]archVariantStore, len(under)+len(over))
+	for a, v := range under {
+		ret[a] = v
+	}
+	for a, v := range over {
+		u := ret[a]
+		if v.Params != "" {
+			u.Params = v.Params
+		}
+		if v.Kernel != "" {
+			u.Kernel = v.Kernel
+		}
+		if v.Initrd != "" {
+			u.Initrd = v.Initrd
+		}
+		ret[a] = u
+	}
+	return ret
+}
+
+// Take an image out of the variants which use it, returning whether any did.
+func clearArchImage(variants map[string]archVariantStore, key string) bool {
+	changed := false
+	for a, v := range variants {
+		if v.Kernel != key && v.Initrd != key {
+			continue
+		}
+		if v.Kernel == key {
+			v.Kernel = ""
+		}
+		if v.Initrd == key {
+			v.Initrd = ""
+		}
+		if v == (archVariantStore{}) {
+			delete(variants, a)
+		} else {
+			variants[a] = v
+		}
+		changed = true
+	}
+	return changed
+}
+
+func archVariantsConvert(variants map[string]archVariantStore) map[string]archVariantData {
+	if len(variants) == 0 {
+		return nil
+	}
+	ret := make(map[string]archVariantData, len(variants))
+	for a, v := range variants {
+		d := archVariantData{Params: v.Params}
+		if v.Kernel != "" {
+			d.Kernel, _ = getImage(v.Kernel, "")
+		}
+		if v.Initrd != "" {
+			d.Initrd, _ = getImage(v.Initrd, "")
+		}
+		ret[a] = d
+	}
+	return ret
+}
+
+func archVariantsAPI(variants map[string]archVariantData) map[string]bssTypes.ArchVariant {
+	if len(variants) == 0 {
+		return nil
+	}
+	ret := make(map[string]bssTypes.ArchVariant, len(variants))
+	for a, v := range variants {
+		ret[a] = bssTypes.ArchVariant{Params: v.Params, Kernel: v.Kernel.Path, Initrd: v.Initrd.Path}
+	}
+	return ret
+}
+
+// The boot data of a node with the given architecture.  The variant for the
+// architecture, if there is one, replaces the kernel and initrd and its params
+// are appended.  If the architecture is not known the boot data is returned
+// as it is, variants and all.
+func selectArch(bd BootData, arch string) BootData {
+	if arch == "" || bd.ArchVariants == nil {
+		return bd
+	}
+	v, ok := bd.ArchVariants[arch]
+	bd.ArchVariants = nil
+	if !ok {
+		return bd
+	}
+	if v.Kernel.Path != "" {
+		bd.Kernel = v.Kernel
+	}
+	if v.Initrd.Path != "" {
+		bd.Initrd = v.Initrd
+	}
+	if v.Params != "" {
+		bd.Params = strings.TrimSpace(bd.Params + " " + v.Params)
+	}
+	return bd
+}
+
+// The architectures HSM has for the nodes of a role, sorted.
+func roleArches(role string) []string {
+	set := map[string]bool{}
+	if state := getState(); state != nil {
+		for _, c := range state.Components {
+			if a := normalizeArch(c.Arch); c.Role == role && a != "" {
+				set[a] = true
+			}
+		}
+	}
+	ret := make([]string, 0, len(set))
+	for a := range set {
+		ret = append(ret, a)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Make sure that every architecture of the nodes of a role has a kernel, if
+// the role's entry has variants.  Other entries are not checked.
+func checkRoleArches(name string, bds BootDataStore) error {
+	bds = withProfile(bds)
+	if len(bds.ArchVariants) == 0 || bds.Kernel != "" {
+		return nil
+	}
+	var missing []string
+	for _, a := range roleArches(name) {
+		if bds.ArchVariants[a].Kernel == "" {
+			missing = append(missing, a)
+		}
+	}
+	if len(missing) > 0 {
+		return paramsError(fmt.Sprintf("Role %s has %s nodes, but no kernel for them",
+			name, strings.Join(missing, " and ")))
+	}
+	return nil
+}
+
+// Check which nodes have no kernel for their architecture, by role and
+// architecture.  Nodes whose architecture HSM does not know are left out.
+func archCheckGetAPI(w http.ResponseWriter, r *http.Request) {
+	debugf("archCheckGetAPI(): Received request %v\n", r.URL)
+	r.ParseForm()
+	role := strings.Join(r.Form["role"], "")
+
+	byKey := map[string]*bssTypes.RoleArch{}
+	var keys []string
+	if state := getState(); state != nil {
+		for _, comp := range state.Components {
+			arch := normalizeArch(comp.Arch)
+			if arch == "" || (role != "" && comp.Role != role) {
+				continue
+			}
+			key := comp.Role + "/" + arch
+			ra, ok := byKey[key]
+			if !ok {
+				ra = &bssTypes.RoleArch{Role: comp.Role, Arch: arch}
+				byKey[key] = ra
+				keys = append(keys, key)
+			}
+			ra.Nodes++
+			if bd := selectArch(lookupNode(comp.ID, comp.ID, comp), arch); bd.Kernel.Path == "" {
+				ra.Missing = append(ra.Missing, comp.ID)
+			}
+		}
+	}
+	sort.Strings(keys)
+	ret := make([]bssTypes.RoleArch, 0, len(keys))
+	for _, k := range keys {
+		sort.Strings(byKey[k].Missing)
+		ret = append(ret, *byKey[k])
+	}
+	sendJSON(w, http.StatusOK, ret)
+}
@@ -0,0 +1,207 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+// Remove the images the tests store once they are done.
+func removeArchImages() {
+	for _, k := range []string{"http://images/x86/kernel", "http://images/arm/kernel", "http://images/arm/kernel2"} {
+		removeImage(k, kernelImageType, changeRequest{})
+	}
+	removeImage("http://images/x86/initrd", initrdImageType, changeRequest{})
+}
+
+func archNode(id, role, arch string, nid int) SMComponent {
+	return SMComponent{Component: base.Component{ID: id, Type: "Node", Role: role, Arch: arch,
+		NID: json.Number(strconv.Itoa(nid))}, EndpointEnabled: true}
+}
+
+func TestNormalizeArch(t *testing.T) {
+	tests := map[string]string{
+		"x86_64": archX86_64, "AMD64": archX86_64, "X86": archX86_64,
+		"aarch64": archArm64, "arm64": archArm64, "ARM": archArm64,
+		"i386": archX86_64, "i686": archX86_64, "x86_64-efi": archX86_64, "i386-pc": archX86_64,
+		"arm64-efi": archArm64, "riscv64": archRiscv64,
+		"": "", "UNKNOWN": "", "Other": "", "arm32": "", "sparc": "", "-efi": "",
+	}
+	for in, want := range tests {
+		if got := normalizeArch(in); got != want {
+			t.Errorf("normalizeArch(%q) = %q, expected %q", in, got, want)
+		}
+	}
+}
+
+func TestSelectArch(t *testing.T) {
+	bd := BootData{
+		Params: "console=ttyS0",
+		Kernel: ImageData{Path: "http://images/x86/kernel"},
+		Initrd: ImageData{Path: "http://images/x86/initrd"},
+		ArchVariants: map[string]archVariantData{
+			archArm64: {Params: "iommu.passthrough=1", Kernel: ImageData{Path: "http://images/arm/kernel"}},
+		},
+	}
+	if got := selectArch(bd, ""); !reflect.DeepEqual(got, bd) {
+		t.Errorf("selectArch() without an architecture changed the boot data: %+v", got)
+	}
+	got := selectArch(bd, archArm64)
+	if got.Kernel.Path != "http://images/arm/kernel" || got.Initrd.Path != "http://images/x86/initrd" ||
+		got.Params != "console=ttyS0 iommu.passthrough=1" || got.ArchVariants != nil {
+		t.Errorf("selectArch(arm64) = %+v", got)
+	}
+	if got = selectArch(bd, archX86_64); got.Kernel.Path != "http://images/x86/kernel" || got.Params != "console=ttyS0" {
+		t.Errorf("selectArch(x86_64) = %+v", got)
+	}
+
+	old := map[string]archVariantStore{archArm64: {Kernel: "k1"}, archX86_64: {Kernel: "k2"}}
+	merged := mergeArchVariants(old, map[string]archVariantStore{archArm64: {}, "riscv64": {Params: "quiet"}})
+	if !reflect.DeepEqual(merged, map[string]archVariantStore{archX86_64: {Kernel: "k2"}, "riscv64": {Params: "quiet"}}) {
+		t.Errorf("mergeArchVariants() = %v", merged)
+	}
+	over := overlayArchVariants(old, map[string]archVariantStore{archArm64: {Params: "quiet"}})
+	if over[archArm64] != (archVariantStore{Params: "quiet", Kernel: "k1"}) || over[archX86_64].Kernel != "k2" {
+		t.Errorf("overlayArchVariants() = %v", over)
+	}
+}
+
+func TestArchVariants(t *testing.T) {
+	withComponents(t,
+		archNode("x9c0s1b0n0", "ArchRole", "ARM", 1),
+		archNode("x9c0s1b0n1", "ArchRole", "X86", 2),
+		archNode("x9c0s1b0n2", "ArchRole", "UNKNOWN", 3))
+	defer removeArchImages()
+
+	// Every architecture of the role needs a kernel
+	bp := bssTypes.BootParams{Hosts: []string{"ArchRole"}, Params: "console=ttyS0",
+		ArchVariants: map[string]bssTypes.ArchVariant{
+			"x86_64": {Kernel: "http://images/x86/kernel", Initrd: "http://images/x86/initrd"},
+		}}
+	if err, _ := Store(bp); err == nil || !strings.Contains(err.Error(), "arm64") {
+		t.Errorf("Store() without an arm64 kernel returned %v", err)
+	}
+	bp.ArchVariants["sparc"] = bssTypes.ArchVariant{Kernel: "http://images/arm/kernel"}
+	if err, _ := Store(bp); err == nil || !strings.Contains(err.Error(), "sparc") {
+		t.Errorf("Store() with an unknown architecture returned %v", err)
+	}
+	delete(bp.ArchVariants, "sparc")
+	bp.ArchVariants["aarch64"] = bssTypes.ArchVariant{Params: "iommu.passthrough=1", Kernel: "http://images/arm/kernel"}
+	if err, _ := Store(bp); err != nil {
+		t.Fatalf("Store() failed: %v", err)
+	}
+	defer deleteHost("ArchRole")
+	err := Update(bssTypes.BootParams{Hosts: []string{"ArchRole"},
+		ArchVariants: map[string]bssTypes.ArchVariant{"arm64": {}}})
+	if err == nil {
+		t.Errorf("Update() removing the arm64 variant succeeded")
+	}
+
+	bootscript := func(query string) string {
+		rr := serveRequest(t, bootScript, http.MethodGet, "/bootscript?"+query, nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GET bootscript?%s returned %d: %s", query, rr.Code, rr.Body)
+		}
+		return rr.Body.String()
+	}
+	if s := bootscript("name=x9c0s1b0n0"); !strings.Contains(s, "http://images/arm/kernel console=ttyS0 iommu.passthrough=1 ") {
+		t.Errorf("arm64 node did not boot its variant:\n%s", s)
+	}
+	if s := bootscript("name=x9c0s1b0n1"); !strings.Contains(s, "http://images/x86/kernel initrd=initrd console=ttyS0 ") ||
+		!strings.Contains(s, "http://images/x86/initrd") {
+		t.Errorf("x86_64 node did not boot its variant:\n%s", s)
+	}
+	if s := bootscript("name=x9c0s1b0n2"); !strings.Contains(s, "&arch=${buildarch}") {
+		t.Errorf("Node without an architecture was not asked for it:\n%s", s)
+	}
+	if s := bootscript("name=x9c0s1b0n2&arch=aarch64"); !strings.Contains(s, "http://images/arm/kernel") {
+		t.Errorf("arch= did not select the variant:\n%s", s)
+	}
+
+	// The variants are shown by GET and their images are referenced
+	rr := serveRequest(t, bootParameters, http.MethodGet, "/bootparameters?name=ArchRole", nil)
+	var bps []bssTypes.BootParams
+	json.Unmarshal(rr.Body.Bytes(), &bps)
+	if len(bps) != 1 || bps[0].ArchVariants["arm64"].Kernel != "http://images/arm/kernel" ||
+		bps[0].ArchVariants["x86_64"].Initrd != "http://images/x86/initrd" {
+		t.Errorf("GET bootparameters did not show the variants: %s", rr.Body)
+	}
+	if refs, _ := imageRefs(imageFind("http://images/arm/kernel", kernelImageType)); len(refs) != 1 || refs[0] != paramsPfx+"ArchRole" {
+		t.Errorf("Variant kernel references %v", refs)
+	}
+
+	// A host entry without an arm64 kernel shows up in the check
+	Store(bssTypes.BootParams{Hosts: []string{"x9c0s1b0n0"},
+		ArchVariants: map[string]bssTypes.ArchVariant{"x86_64": {Kernel: "http://images/x86/kernel"}}})
+	defer deleteHost("x9c0s1b0n0")
+	rr = serveRequest(t, archCheck, http.MethodGet, "/bootparameters/arch-check?role=ArchRole", nil)
+	var ras []bssTypes.RoleArch
+	json.Unmarshal(rr.Body.Bytes(), &ras)
+	want := []bssTypes.RoleArch{
+		{Role: "ArchRole", Arch: "arm64", Nodes: 1, Missing: []string{"x9c0s1b0n0"}},
+		{Role: "ArchRole", Arch: "x86_64", Nodes: 1},
+	}
+	if rr.Code != http.StatusOK || !reflect.DeepEqual(ras, want) {
+		t.Errorf("arch-check returned %d: %s", rr.Code, rr.Body)
+	}
+}
+
+func TestProfileArchVariants(t *testing.T) {
+	defer removeArchImages()
+	rr := serveRequest(t, profiles, http.MethodPut, "/profiles/archprof", bssTypes.BootProfile{Kernel: "http://images/x86/kernel",
+		ArchVariants: map[string]bssTypes.ArchVariant{"arm64": {Kernel: "http://images/arm/kernel", Params: "quiet"}}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("PUT returned %d: %s", rr.Code, rr.Body)
+	}
+	defer serveRequest(t, profiles, http.MethodDelete, "/profiles/archprof", nil)
+	rr = serveRequest(t, profiles, http.MethodPatch, "/profiles/archprof", bssTypes.BootProfile{
+		ArchVariants: map[string]bssTypes.ArchVariant{"arm64": {Kernel: "http://images/arm/kernel2"}}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("PATCH returned %d: %s", rr.Code, rr.Body)
+	}
+
+	Store(bssTypes.BootParams{Hosts: []string{"x9c0s2b0n0"}, Profile: "archprof",
+		ArchVariants: map[string]bssTypes.ArchVariant{"arm64": {Params: "console=ttyAMA0"}}})
+	defer deleteHost("x9c0s2b0n0")
+	bd := selectArch(lookup("x9c0s2b0n0", "", "", ""), archArm64)
+	if bd.Kernel.Path != "http://images/arm/kernel2" || bd.Params != "console=ttyAMA0" {
+		t.Errorf("Host variant over the profile's: kernel %s, params '%s'", bd.Kernel.Path, bd.Params)
+	}
+
+	serveRequest(t, profiles, http.MethodPatch, "/profiles/archprof", bssTypes.BootProfile{
+		ArchVariants: map[string]bssTypes.ArchVariant{"arm64": {}}})
+	rr = serveRequest(t, profiles, http.MethodGet, "/profiles/archprof", nil)
+	var bp bssTypes.BootProfile
+	json.Unmarshal(rr.Body.Bytes(), &bp)
+	if bp.Kernel != "http://images/x86/kernel" || bp.ArchVariants != nil {
+		t.Errorf("PATCH did not remove the variant: %s", rr.Body)
+	}
+}
@@ -0,0 +1,216 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+/*
+ * Artifact server
+ *
+ * When BSS_ARTIFACT_DIR is set, the files under it, such as kernels, initrds
+ * and iPXE binaries, are served at /boot/v1/artifacts/<path> over HTTP, with
+ * range requests, and over TFTP if BSS_TFTP_LISTEN is set.  Boot scripts
+ * then point plain image paths at the artifact server instead of handing
+ * them to the node as they are.
+ *
+ * With BSS_ARTIFACT_S3_PROXY, s3 images are also served through the artifact
+ * server, as /boot/v1/artifacts/s3/<bucket>/<key>.  Each object is
+ * downloaded once through a presigned URL into BSS_ARTIFACT_CACHE_DIR, and
+ * downloaded again once it is older than BSS_ARTIFACT_CACHE_TTL seconds.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+)
+
+const (
+	artifactsEndpoint = baseEndpoint + "/artifacts"
+	artifactS3Pfx     = "/s3/"
+)
+
+var artifactDir = ""
+var artifactS3Proxy = false
+var artifactCacheDir = filepath.Join(os.TempDir(), "bss-artifacts")
+var artifactCacheTTL = 3600
+
+var artifactClient = &http.Client{Timeout: 10 * time.Minute}
+
+// Presigns s3 URLs, replaced by the tests
+var artifactSignURL = checkURL
+
+// S3 downloads in progress, so that nodes booting together share one
+type artifactFetch struct {
+	done chan struct{}
+	err  error
+}
+
+var artifactFetchMutex sync.Mutex
+var artifactFetches = make(map[string]*artifactFetch)
+
+func artifactsEnabled() bool {
+	return artifactDir != "" || artifactS3Proxy
+}
+
+// The URL a node downloads an image from.  Plain paths are served by the
+// artifact server, as are s3 URLs if it proxies them.  Other s3 URLs are
+// presigned.
+func imageURL(p string) (string, error) {
+	u, err := url.Parse(p)
+	if err == nil && artifactsEnabled() {
+		server := chainProto + "://" + ipxeServer + gwURI + artifactsEndpoint
+		switch {
+		case artifactDir != "" && u.Scheme == "":
+			return server + path.Clean("/"+u.Path), nil
+		case artifactS3Proxy && strings.EqualFold(u.Scheme, "s3"):
+			return server + "/s3" + path.Clean("/"+u.Host+"/"+u.Path), nil
+		}
+	}
+	return checkURL(p)
+}
+
+// Download an s3 object into the cache.
+func fetchS3Artifact(name, dest string) error {
+	signed, err := artifactSignURL("s3://" + strings.TrimPrefix(name, artifactS3Pfx))
+	if err != nil {
+		return err
+	}
+	resp, err := artifactClient.Get(signed)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return os.ErrNotExist
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("s3 download of %s failed: %s", name, resp.Status)
+	}
+	if err = os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(dest), ".fetch-")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, resp.Body)
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dest)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	log.Printf("Cached artifact %s", name)
+	return nil
+}
+
+// Make sure an s3 object is in the cache, returning its file.
+func cachedS3Artifact(name string) (string, error) {
+	dest := filepath.Join(artifactCacheDir, filepath.FromSlash(name))
+	if fi, err := os.Stat(dest); err == nil &&
+		time.Since(fi.ModTime()) < time.Duration(artifactCacheTTL)*time.Second {
+		return dest, nil
+	}
+	artifactFetchMutex.Lock()
+	f, busy := artifactFetches[name]
+	if !busy {
+		f = &artifactFetch{done: make(chan struct{})}
+		artifactFetches[name] = f
+	}
+	artifactFetchMutex.Unlock()
+	if !busy {
+		f.err = fetchS3Artifact(name, dest)
+		artifactFetchMutex.Lock()
+		delete(artifactFetches, name)
+		artifactFetchMutex.Unlock()
+		close(f.done)
+	}
+	<-f.done
+	return dest, f.err
+}
+
+// Open an artifact by its name, a path under BSS_ARTIFACT_DIR or an s3
+// object under /s3/.
+func artifactOpen(name string) (*os.File, error) {
+	name = path.Clean("/" + name)
+	var file string
+	switch {
+	case artifactS3Proxy && strings.HasPrefix(name, artifactS3Pfx):
+		var err error
+		if file, err = cachedS3Artifact(name); err != nil {
+			return nil, err
+		}
+	case artifactDir != "":
+		file = filepath.Join(artifactDir, filepath.FromSlash(name))
+	default:
+		return nil, os.ErrNotExist
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := f.Stat(); err != nil || fi.IsDir() {
+		f.Close()
+		return nil, os.ErrNotExist
+	}
+	return f, nil
+}
+
+// Serve an artifact over HTTP.
+func artifactsGetAPI(w http.ResponseWriter, r *http.Request) {
+	debugf("artifactsGetAPI(): Received request %v\n", r.URL)
+	name := strings.TrimPrefix(r.URL.Path, artifactsEndpoint)
+	f, err := artifactOpen(name)
+	if errors.Is(err, os.ErrNotExist) {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound,
+			fmt.Sprintf("Artifact %s does not exist", name))
+		return
+	} else if err != nil {
+		log.Printf("Failed to open artifact %s: %s", name, err)
+		base.SendProblemDetailsGeneric(w, http.StatusBadGateway,
+			fmt.Sprintf("Artifact %s is not available: %s", name, err))
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
+}
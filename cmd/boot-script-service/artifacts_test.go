@@ -0,0 +1,196 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const artifactContent = "0123456789abcdefghij"
+
+func withArtifactDir(t *testing.T) func() {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "images"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "images", "kernel"), []byte(artifactContent), 0644)
+	artifactDir = dir
+	return func() { artifactDir = "" }
+}
+
+func artifactRequest(path string, hdr map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodGet, testBaseURL+"/artifacts"+path, nil)
+	for k, v := range hdr {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(artifacts).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestArtifactsHTTP(t *testing.T) {
+	if rr := artifactRequest("/images/kernel", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("Disabled artifact server returned %d", rr.Code)
+	}
+	defer withArtifactDir(t)()
+
+	rr := artifactRequest("/images/kernel", nil)
+	if rr.Code != http.StatusOK || rr.Body.String() != artifactContent {
+		t.Errorf("GET returned %d: %s", rr.Code, rr.Body)
+	}
+	rr = artifactRequest("/images/kernel", map[string]string{"Range": "bytes=2-5"})
+	if rr.Code != http.StatusPartialContent || rr.Body.String() != "2345" {
+		t.Errorf("Ranged GET returned %d: %s", rr.Code, rr.Body)
+	}
+	for _, p := range []string{"/images", "/images/missing", "/../images/kernel/../../etc/passwd"} {
+		if rr = artifactRequest(p, nil); rr.Code != http.StatusNotFound {
+			t.Errorf("GET %s returned %d", p, rr.Code)
+		}
+	}
+}
+
+func TestArtifactsS3Proxy(t *testing.T) {
+	var fetches int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		if r.URL.Path != "/boot-images/k1/kernel" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(artifactContent))
+	}))
+	defer ts.Close()
+	cacheDir := artifactCacheDir
+	artifactS3Proxy, artifactCacheDir = true, t.TempDir()
+	artifactSignURL = func(u string) (string, error) {
+		return ts.URL + "/" + strings.TrimPrefix(u, "s3://"), nil
+	}
+	defer func() { artifactS3Proxy, artifactCacheDir, artifactSignURL = false, cacheDir, checkURL }()
+
+	for i := 0; i < 2; i++ {
+		if rr := artifactRequest("/s3/boot-images/k1/kernel", nil); rr.Code != http.StatusOK || rr.Body.String() != artifactContent {
+			t.Errorf("GET returned %d: %s", rr.Code, rr.Body)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("Object downloaded %d times", fetches)
+	}
+	if rr := artifactRequest("/s3/boot-images/missing", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("GET of a missing object returned %d", rr.Code)
+	}
+}
+
+func TestImageURL(t *testing.T) {
+	server := chainProto + "://" + ipxeServer + gwURI + artifactsEndpoint
+	if u, _ := imageURL("/images/kernel"); u != "/images/kernel" {
+		t.Errorf("Plain path without an artifact server became %s", u)
+	}
+	defer withArtifactDir(t)()
+	artifactS3Proxy = true
+	defer func() { artifactS3Proxy = false }()
+	tests := []struct {
+		path, want string
+	}{
+		{"/images/kernel", server + "/images/kernel"},
+		{"images/../kernel", server + "/kernel"},
+		{"s3://boot-images/k1/kernel", server + "/s3/boot-images/k1/kernel"},
+		{"http://images/k1/kernel", "http://images/k1/kernel"},
+	}
+	for _, tt := range tests {
+		if u, err := imageURL(tt.path); err != nil || u != tt.want {
+			t.Errorf("imageURL(%s) = %s, %v, expected %s", tt.path, u, err, tt.want)
+		}
+	}
+}
+
+func TestTFTP(t *testing.T) {
+	defer withArtifactDir(t)()
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The transfers must finish before the artifact directory is reset
+	served := make(chan struct{})
+	go func() {
+		tftpServeConn(server)
+		close(served)
+	}()
+	defer func() {
+		server.Close()
+		<-served
+	}()
+
+	client, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	read := func() ([]byte, net.Addr) {
+		buf := make([]byte, 1500)
+		client.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, addr, err := client.ReadFrom(buf)
+		if err != nil {
+			t.Fatal("TFTP read failed:", err)
+		}
+		return buf[:n], addr
+	}
+	ack := func(addr net.Addr, block uint16) {
+		client.WriteTo(tftpPacket(tftpACK, block, nil), addr)
+	}
+
+	client.WriteTo([]byte("\x00\x01images/kernel\x00octet\x00blksize\x008\x00tsize\x000\x00"), server.LocalAddr())
+	pkt, addr := read()
+	if !bytes.Equal(pkt, []byte("\x00\x06blksize\x008\x00tsize\x0020\x00")) {
+		t.Fatalf("Expected an OACK, got %q", pkt)
+	}
+	ack(addr, 0)
+	var got []byte
+	for block := uint16(1); ; block++ {
+		pkt, _ = read()
+		if binary.BigEndian.Uint16(pkt) != tftpDATA || binary.BigEndian.Uint16(pkt[2:]) != block {
+			t.Fatalf("Expected block %d, got %q", block, pkt)
+		}
+		got = append(got, pkt[4:]...)
+		ack(addr, block)
+		if len(pkt)-4 < 8 {
+			break
+		}
+	}
+	if string(got) != artifactContent {
+		t.Errorf("Received %q", got)
+	}
+
+	client.WriteTo([]byte("\x00\x01missing\x00octet\x00"), server.LocalAddr())
+	if pkt, _ = read(); binary.BigEndian.Uint16(pkt) != tftpERROR || binary.BigEndian.Uint16(pkt[2:]) != tftpErrNotFound {
+		t.Errorf("Expected file not found, got %q", pkt)
+	}
+}
@@ -43,6 +43,7 @@ import (
 
 	base "github.com/Cray-HPE/hms-base"
 	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+	"github.com/Cray-HPE/hms-bss/pkg/cmdline"
 	hmetcd "github.com/Cray-HPE/hms-hmetcd"
 	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/google/uuid"
@@ -63,11 +64,26 @@ type BootDataStore struct {
 	Initrd        string             `json:"initrd,omitempty"`        // Image storage key
 	CloudInit     bssTypes.CloudInit `json:"cloud-init,omitempty"`    // Image storage key
 	ReferralToken string             `json:"referral-token,omitempty` // UUID
+	Profile       string             `json:"profile,omitempty"`       // Boot profile name
+
+	ArchVariants map[string]archVariantStore `json:"arch-variants,omitempty"`
 }
 
 type ImageData struct {
 	Path   string `json:"path"`             // URL or path to the image
 	Params string `json:"params,omitempty"` // boot parameters associated with this image
+
+	// Image catalog metadata, see images.go
+	Name    string            `json:"name,omitempty"`
+	Version string            `json:"version,omitempty"`
+	Arch    string            `json:"arch,omitempty"`
+	Size    int64             `json:"size,omitempty"`
+	Sha256  string            `json:"sha256,omitempty"`
+	Created string            `json:"created,omitempty"` // RFC3339
+	Labels  map[string]string `json:"labels,omitempty"`
+
+	// Result of the last check of the image, see validation.go
+	Validation *bssTypes.ImageStatus `json:"validation,omitempty"`
 }
 
 type BootData struct {
@@ -76,6 +92,8 @@ type BootData struct {
 	Initrd        ImageData
 	CloudInit     bssTypes.CloudInit
 	ReferralToken string
+	Profile       string
+	ArchVariants  map[string]archVariantData
 }
 
 const DefaultTag = "Default"
@@ -143,6 +161,18 @@ func getImage(imtype, subkey string) (ImageData, error) {
 	return imdata, err
 }
 
+// An image with new boot parameters, keeping its catalog metadata.
+func imageWithParams(op kvOp, params string) ImageData {
+	var imdata ImageData
+	value := op.value
+	if op.check {
+		value = op.prev
+	}
+	json.Unmarshal([]byte(value), &imdata)
+	imdata.Params = params
+	return imdata
+}
+
 func getImageInfo(imtype string) []ImageData {
 	var ret []ImageData
 	kvl, err := getImages(imtype)
@@ -203,32 +233,49 @@ func imageFind(path string, imtype string) string {
 
 var kvMutex sync.Mutex
 
-func imageStore(path string, imtype string) string {
-	debugf("ImageStore(%s, %s)\n", path, imtype)
-	kvMutex.Lock()
-	defer kvMutex.Unlock()
-	kvstore.DistTimedLock(5)
-	defer kvstore.DistUnlock()
-
+// The write which adds an image path to a batch, with the image key.  A new
+// image is stored by the batch.  An existing image is only checked, so that
+// the batch fails rather than reference an image deleted in the meantime.
+func imageOp(path string, imtype string) (kvOp, error) {
+	debugf("imageOp(%s, %s)\n", path, imtype)
 	kvl, err := getImages(imtype)
-	var k string
-	var imdata ImageData
-	if err == nil {
-		k, imdata = imageLookup(path, imtype, kvl)
+	if err != nil {
+		return kvOp{}, fmt.Errorf("Cannot store image path %s: %s", path, err)
 	}
-	debugf("imageLookup() -> (%s, %v)\n", k, imdata)
-	if k != "" {
-		// This path is already stored, return the key for it
-		return k
+	if k, _ := imageLookup(path, imtype, kvl); k != "" {
+		for _, kv := range kvl {
+			if kv.Key == k {
+				return kvOp{key: k, check: true, guarded: true, prev: kv.Value, prevExists: true}, nil
+			}
+		}
 	}
-	key := makeImageKey(imtype, path)
-	imdata = ImageData{path, ""}
-	err = storeData(key, imdata)
-	if err != nil {
-		debugf("Cannot store %s path %s: %v\n", imtype, path, err)
-		key = ""
+	op := storeOp(makeImageKey(imtype, path), ImageData{Path: path, Created: time.Now().UTC().Format(time.RFC3339)})
+	op.noHistory = true
+	return op, nil
+}
+
+// The writes which add the kernel and initrd paths of a request to a batch.
+// The write of an empty path has no key.
+func imageOps(kernel, initrd string) (kvOp, kvOp, error) {
+	var k, i kvOp
+	var err error
+	if kernel != "" {
+		k, err = imageOp(kernel, kernelImageType)
+	}
+	if err == nil && initrd != "" {
+		i, err = imageOp(initrd, initrdImageType)
+	}
+	return k, i, err
+}
+
+// Append the image writes which have a key.
+func appendImageOps(ops []kvOp, images ...kvOp) []kvOp {
+	for _, op := range images {
+		if op.key != "" {
+			ops = append(ops, op)
+		}
 	}
-	return key
+	return ops
 }
 
 func nidName(nid int) string {
@@ -236,99 +283,172 @@ func nidName(nid int) string {
 }
 
 func Remove(bp bssTypes.BootParams) error {
+	_, err := removeParams(bp, changeRequest{})
+	return err
+}
+
+// Remove boot parameters, returning the result for each host.  Either all of
+// the hosts are removed or, if any does not exist or fails, none are.
+// Any If-Match ETags are checked, and the history recorded, by applyTargets.
+func removeParams(bp bssTypes.BootParams, cr changeRequest) ([]bssTypes.HostResult, error) {
 	debugf("Remove(): Ready to remove %v\n", bp)
-	var err error
+	var targets []paramTarget
 	for _, h := range bp.Hosts {
-		e := removeHost(h)
-		if err == nil {
-			err = e
-		}
+		targets = append(targets, paramTarget{h, bssTypes.HostDeleted, kvOp{key: paramsPfx + h, delete: true}})
 	}
 	for _, m := range bp.Macs {
 		comp, ok := FindSMCompByMAC(m)
 		if ok {
-			e := removeHost(comp.ID)
-			if err == nil {
-				err = e
-			}
+			targets = append(targets, paramTarget{m, bssTypes.HostDeleted, kvOp{key: paramsPfx + comp.ID, delete: true}})
 		}
 	}
 	for _, n := range bp.Nids {
-		comp, ok := FindSMCompByNid(int(n))
-		if ok {
-			e := removeHost(comp.ID)
-			if err == nil {
-				err = e
-			}
-		} else {
-			e := removeHost(nidName(int(n)))
-			if err == nil {
-				err = e
+		host := nidName(int(n))
+		if comp, ok := FindSMCompByNid(int(n)); ok {
+			host = comp.ID
+		}
+		targets = append(targets, paramTarget{strconv.Itoa(int(n)), bssTypes.HostDeleted, kvOp{key: paramsPfx + host, delete: true}})
+	}
+
+	if len(targets) == 0 {
+		err := removeImage(bp.Kernel, kernelImageType, cr)
+		if e := removeImage(bp.Initrd, initrdImageType, cr); err == nil {
+			err = e
+		}
+		return nil, err
+	}
+	missing := false
+	for _, t := range targets {
+		if _, exists, e := kvstore.Get(t.op.key); e != nil || !exists {
+			missing = true
+		}
+	}
+	if missing {
+		// Nothing is removed if any of the hosts do not exist
+		var results []bssTypes.HostResult
+		var err error
+		for _, t := range targets {
+			r := bssTypes.HostResult{Host: t.name, Status: bssTypes.HostNotApplied}
+			if _, exists, e := kvstore.Get(t.op.key); e != nil || !exists {
+				err = removeError(strings.TrimPrefix(t.op.key, paramsPfx), e)
+				r.Status, r.Error = bssTypes.HostNotFound, err.Error()
 			}
+			results = append(results, r)
 		}
+		return results, err
 	}
-	e := removeImage(bp.Kernel, kernelImageType)
-	if err == nil {
-		err = e
+
+	// The images are removed in the same batch as the hosts.  The hosts
+	// being removed don't need their references cleared.
+	images, iops, err := removeImageOps(bp.Kernel, bp.Initrd)
+	if err != nil {
+		return nil, err
+	}
+	removed := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		removed[t.op.key] = true
 	}
-	e = removeImage(bp.Initrd, initrdImageType)
+	var other []kvOp
+	for _, op := range iops {
+		if !removed[op.key] {
+			other = append(other, op)
+		}
+	}
+	results, err := applyTargets(targets, other, cr)
 	if err == nil {
-		err = e
+		for _, key := range images {
+			_ = imageCache.Delete(key)
+		}
 	}
-	return err
+	return results, err
 }
 
-func removeHost(h string) error {
-	key := paramsPfx + h
-	_, exists, err := kvstore.Get(key)
-	if !exists {
-		err = fmt.Errorf("Key %s does not exist", key)
-	} else if err == nil {
-		err = kvstore.Delete(key)
-	}
-	if err != nil {
-		msg := fmt.Sprintf("Key %s deletion: %s", h, err.Error())
-		herr := base.NewHMSError("Storage", msg)
-		herr.AddProblem(base.NewProblemDetailsStatus(msg, http.StatusInternalServerError))
-		return herr
+func removeError(h string, err error) error {
+	if err == nil {
+		err = fmt.Errorf("Key %s does not exist", paramsPfx+h)
 	}
-	return nil
+	msg := fmt.Sprintf("Key %s deletion: %s", h, err.Error())
+	herr := base.NewHMSError("Storage", msg)
+	herr.AddProblem(base.NewProblemDetailsStatus(msg, http.StatusInternalServerError))
+	return herr
 }
 
-func removeImage(path, imtype string) error {
-	var err error
-	if path != "" {
-		kvl, _ := getImages(imtype)
-		key, _ := imageLookup(path, imtype, kvl)
-		if key != "" {
-			// We found the image.  First, remove references from the dataStore
-			err = kvstore.Delete(key)
-			_ = imageCache.Delete(key)
-			if err != nil {
-				msg := fmt.Sprintf("Key %s deletion: %v\n", key, err)
-				herr := base.NewHMSError("Storage", msg)
-				herr.AddProblem(base.NewProblemDetailsStatus(msg, http.StatusInternalServerError))
-				return herr
+// The writes which remove the kernel and initrd images with these paths, and
+// the references to them from boot parameters and boot profiles, along with
+// the keys of the images.  Paths which are empty or not stored are skipped.
+func removeImageOps(kernel, initrd string) ([]string, []kvOp, error) {
+	var keys []string
+	var ops []kvOp
+	for _, im := range []struct{ path, imtype string }{{kernel, kernelImageType}, {initrd, initrdImageType}} {
+		if im.path == "" {
+			continue
+		}
+		kvl, _ := getImages(im.imtype)
+		if key, _ := imageLookup(im.path, im.imtype, kvl); key != "" {
+			keys = append(keys, key)
+			ops = append(ops, kvOp{key: key, delete: true})
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil, nil
+	}
+	kvl, err := getTags()
+	if err == nil {
+		pkvl, _ := kvstore.GetRange(profilesPfx+keyMin, profilesPfx+keyMax)
+		kvl = append(kvl, pkvl...)
+		for _, x := range kvl {
+			var bds BootDataStore
+			if json.Unmarshal([]byte(x.Value), &bds) != nil {
+				continue
 			}
-			// Now remove any references to this image
-			kvl, err = getTags()
-			if err == nil {
-				for _, x := range kvl {
-					var bds BootDataStore
-					e := json.Unmarshal([]byte(x.Value), &bds)
-					if e == nil {
-						if imtype == kernelImageType && bds.Kernel == key {
-							bds.Kernel = ""
-							err = storeData(x.Key, bds)
-						} else if imtype == initrdImageType && bds.Initrd == key {
-							bds.Initrd = ""
-							err = storeData(x.Key, bds)
-						}
-					}
+			changed := false
+			for _, key := range keys {
+				if clearArchImage(bds.ArchVariants, key) {
+					changed = true
+				}
+				if bds.Kernel == key {
+					bds.Kernel = ""
+					changed = true
+				} else if bds.Initrd == key {
+					bds.Initrd = ""
+					changed = true
 				}
 			}
+			if changed {
+				ops = append(ops, storeOp(x.Key, bds))
+			}
+		}
+	}
+	return keys, ops, err
+}
+
+// Remove an image, and the references to it from boot parameters and boot
+// profiles, all or nothing.
+func removeImage(path, imtype string, cr changeRequest) error {
+	kernel, initrd := path, ""
+	if imtype == initrdImageType {
+		kernel, initrd = "", path
+	}
+	keys, ops, err := removeImageOps(kernel, initrd)
+	if len(keys) == 0 {
+		return nil
+	}
+	retry := false
+	_, e := applyWithHistory(func() ([]kvOp, error) {
+		// The references are found again if they changed in the meantime
+		if retry {
+			_, ops, err = removeImageOps(kernel, initrd)
 		}
+		retry = true
+		return ops, nil
+	}, cr)
+	if e != nil {
+		msg := fmt.Sprintf("Key %s deletion: %v\n", keys[0], e)
+		herr := base.NewHMSError("Storage", msg)
+		herr.AddProblem(base.NewProblemDetailsStatus(msg, http.StatusInternalServerError))
+		return herr
 	}
+	_ = imageCache.Delete(keys[0])
 	return err
 }
 
@@ -340,6 +460,12 @@ func extractParamName(x hmetcd.Kvi_KV) (ret string) {
 }
 
 func StoreNew(bp bssTypes.BootParams) (error, string) {
+	_, referralToken, err := storeNewParams(bp, changeRequest{})
+	return err, referralToken
+}
+
+// As storeParams, but only storing to new hosts and images.
+func storeNewParams(bp bssTypes.BootParams, cr changeRequest) ([]bssTypes.HostResult, string, error) {
 	item := ""
 	// Go through the entire struct.  We must be storing to new hosts or this
 	// request must fail.
@@ -384,56 +510,74 @@ func StoreNew(bp bssTypes.BootParams) (error, string) {
 		}
 	}
 	if item != "" {
-		return fmt.Errorf("Already exists: %s", item), ""
-	} else {
-		return Store(bp)
+		return nil, "", fmt.Errorf("Already exists: %s", item)
 	}
+	return storeParams(bp, cr)
 }
 
 func Store(bp bssTypes.BootParams) (error, string) {
+	_, referralToken, err := storeParams(bp, changeRequest{})
+	return err, referralToken
+}
+
+// Store boot parameters, returning the result for each host.  Either all of
+// the hosts are stored or, if any fails, none are.
+// Any If-Match ETags are checked, and the history recorded, by applyTargets.
+func storeParams(bp bssTypes.BootParams, cr changeRequest) ([]bssTypes.HostResult, string, error) {
 	debugf("Store(%v)\n", bp)
 
-	var kernel_id, initrd_id string
-	if bp.Kernel != "" {
-		kernel_id = imageStore(bp.Kernel, kernelImageType)
-		if kernel_id == "" {
-			return fmt.Errorf("Cannot store image path %s", bp.Kernel), ""
-		}
+	if err := checkParams(bp.Params); err != nil {
+		return nil, "", err
 	}
-	if bp.Initrd != "" {
-		initrd_id = imageStore(bp.Initrd, initrdImageType)
-		if initrd_id == "" {
-			return fmt.Errorf("Cannot store image path %s", bp.Initrd), ""
+	checks, err := checkImagePaths(bp.Kernel, bp.Initrd)
+	defer recordImageChecks(checks)
+	if err != nil {
+		return nil, "", err
+	}
+	kernelOp, initrdOp, err := imageOps(bp.Kernel, bp.Initrd)
+	if err != nil {
+		return nil, "", err
+	}
+	kernel_id, initrd_id := kernelOp.key, initrdOp.key
+
+	if bp.Profile != "" {
+		if err := checkProfileRef(bp.Profile); err != nil {
+			return nil, "", err
 		}
 	}
+	variants, images, vchecks, err := storeArchVariants(bp.ArchVariants)
+	defer recordImageChecks(vchecks)
+	if err != nil {
+		return nil, "", err
+	}
+	images = appendImageOps(images, kernelOp, initrdOp)
 
 	referralToken := uuid.New().String()
-	bd := BootDataStore{bp.Params, kernel_id, initrd_id, bp.CloudInit, referralToken}
-	var err error
+	bd := BootDataStore{bp.Params, kernel_id, initrd_id, bp.CloudInit, referralToken, bp.Profile,
+		mergeArchVariants(nil, variants)}
+	var targets []paramTarget
+	target := func(name, host string) {
+		targets = append(targets, paramTarget{name, bssTypes.HostStored, storeOp(paramsPfx+host, bd)})
+	}
+	var results []bssTypes.HostResult
 	switch {
 	case len(bp.Hosts) > 0:
 		for _, h := range bp.Hosts {
-			err = storeData(paramsPfx+h, bd)
-			if err != nil {
-				break
+			if err := checkRoleArches(h, bd); err != nil {
+				return nil, "", err
 			}
+			target(h, h)
 		}
 	case len(bp.Macs) > 0:
 		// Deal with MAC addresses
 		for _, m := range bp.Macs {
 			comp, ok := FindSMCompByMAC(m)
 			if ok {
-				err = storeData(paramsPfx+comp.ID, bd)
-				if err != nil {
-					break
-				}
+				target(m, comp.ID)
 			} else {
 				// If the State Manager doesn't know about
 				// it, store based on the MAC address.
-				err = storeData(paramsPfx+m, bd)
-				if err != nil {
-					break
-				}
+				target(m, m)
 			}
 		}
 	case len(bp.Nids) > 0:
@@ -441,63 +585,145 @@ func Store(bp bssTypes.BootParams) (error, string) {
 		for _, n := range bp.Nids {
 			comp, ok := FindSMCompByNid(int(n))
 			if ok {
-				err = storeData(paramsPfx+comp.ID, bd)
-				if err != nil {
-					break
-				}
+				target(strconv.Itoa(int(n)), comp.ID)
 			} else {
 				// If the State Manager doesn't know about
 				// it, store based on the NID.
-				err = storeData(paramsPfx+nidName(int(n)), bd)
-				if err != nil {
-					break
-				}
+				target(strconv.Itoa(int(n)), nidName(int(n)))
 			}
 		}
 	case kernel_id != "":
-		idata := ImageData{bp.Kernel, bp.Params}
+		idata := imageWithParams(kernelOp, bp.Params)
 		debugf("Ready to store data: %s, %v\n", kernel_id, idata)
-		err = storeData(kernel_id, idata)
+		err = storeEntry(kernel_id, idata, cr)
 		referralToken = "" // referralToken was not needed
 	case initrd_id != "":
-		err = storeData(initrd_id, ImageData{bp.Initrd, bp.Params})
+		err = storeEntry(initrd_id, imageWithParams(initrdOp, bp.Params), cr)
 		referralToken = "" // referralToken was not needed
 	default:
 		herr := base.NewHMSError("Storage", "Nothing to Store")
 		herr.AddProblem(base.NewProblemDetailsStatus("Nothing to Store", http.StatusBadRequest))
 		referralToken = "" // referralToken was not needed
 	}
+	if len(targets) > 0 {
+		results, err = applyTargets(targets, images, cr)
+	}
 	debugf("Store referralToken: %s\n", referralToken)
-	return err, referralToken
+	return results, referralToken, err
+}
+
+func paramsError(msg string) error {
+	herr := base.NewHMSError("Storage", msg)
+	herr.AddProblem(base.NewProblemDetailsStatus(msg, http.StatusBadRequest))
+	return herr
+}
+
+// Make sure a kernel command line can be parsed.
+func checkParams(params string) error {
+	if _, err := cmdline.Parse(params); err != nil {
+		return paramsError(fmt.Sprintf("Invalid params: %s", err))
+	}
+	return nil
+}
+
+// Apply a ParamsPatch to a kernel command line.
+func patchParams(params string, pp *bssTypes.ParamsPatch) (string, error) {
+	cl, err := cmdline.Parse(params)
+	if err != nil {
+		return params, paramsError(fmt.Sprintf("Invalid params: %s", err))
+	}
+	words := make([]string, 0, len(pp.Remove)+len(pp.Set)+len(pp.Add))
+	words = append(append(append(words, pp.Remove...), pp.Set...), pp.Add...)
+	for _, w := range words {
+		if f, e := cmdline.Fields(w); e != nil || len(f) != 1 {
+			return params, paramsError(fmt.Sprintf("Invalid params-patch entry '%s'", w))
+		}
+	}
+	for _, w := range pp.Remove {
+		p := cmdline.ParseParam(w)
+		if p.HasValue {
+			cl.RemoveParam(p)
+		} else {
+			cl.Remove(p.Key)
+		}
+	}
+	for _, w := range pp.Set {
+		cl.Set(cmdline.ParseParam(w))
+	}
+	for _, w := range pp.Add {
+		cl.Add(cmdline.ParseParam(w))
+	}
+	return cl.String(), nil
 }
 
 // The update function will update entries but not NULL out existing entries.
 func Update(bp bssTypes.BootParams) error {
+	_, err := updateParams(bp, changeRequest{})
+	return err
+}
+
+// Update boot parameters, returning the result for each host.  Either all of
+// the hosts are updated or, if any fails, none are.
+// Any If-Match ETags are checked, and the history recorded, by applyTargets.
+func updateParams(bp bssTypes.BootParams, cr changeRequest) ([]bssTypes.HostResult, error) {
 	debugf("Update(%v)\n", bp)
-	var kernel_id, initrd_id string
 	var err error
-	if bp.Kernel != "" {
-		kernel_id = imageStore(bp.Kernel, kernelImageType)
+	if err = checkParams(bp.Params); err != nil {
+		return nil, err
+	}
+	if bp.ParamsPatch != nil {
+		if _, err = patchParams("", bp.ParamsPatch); err != nil {
+			return nil, err
+		}
+	}
+	if bp.Profile != "" {
+		if err = checkProfileRef(bp.Profile); err != nil {
+			return nil, err
+		}
+	}
+	checks, err := checkImagePaths(bp.Kernel, bp.Initrd)
+	defer recordImageChecks(checks)
+	if err != nil {
+		return nil, err
+	}
+	variants, images, vchecks, err := storeArchVariants(bp.ArchVariants)
+	defer recordImageChecks(vchecks)
+	if err != nil {
+		return nil, err
 	}
-	if bp.Initrd != "" {
-		initrd_id = imageStore(bp.Initrd, initrdImageType)
+	kernelOp, initrdOp, err := imageOps(bp.Kernel, bp.Initrd)
+	if err != nil {
+		return nil, err
 	}
-	checkHost := func(hostMap *map[string]BootDataStore, h string) error {
-		_, ok := (*hostMap)[h]
+	images = appendImageOps(images, kernelOp, initrdOp)
+	kernel_id, initrd_id := kernelOp.key, initrdOp.key
+	// The host entries to update, and the names they were requested by
+	var hosts []string
+	names := make(map[string]string)
+	hostMap := make(map[string]BootDataStore)
+	var results []bssTypes.HostResult
+	checkHost := func(name, h string) error {
+		_, ok := hostMap[h]
 		if !ok {
 			bd, err := lookupHost(h)
 			if err != nil {
 				return err
 			}
-			(*hostMap)[h] = bd
+			hostMap[h] = bd
+			hosts = append(hosts, h)
+			names[h] = name
 		}
 		return nil
 	}
-	hostMap := make(map[string]BootDataStore)
+	notFound := func(name string, e error) {
+		results = append(results, bssTypes.HostResult{Host: name, Status: bssTypes.HostNotFound, Error: e.Error()})
+		if err == nil {
+			err = e
+		}
+	}
 	for _, h := range bp.Hosts {
-		err = checkHost(&hostMap, h)
-		if err != nil {
-			return err
+		if e := checkHost(h, h); e != nil {
+			notFound(h, e)
 		}
 	}
 	for _, m := range bp.Macs {
@@ -505,35 +731,52 @@ func Update(bp bssTypes.BootParams) error {
 		if ok {
 			// We've mapped the mac address to a host name,
 			// let's see if this host name has boot data.
-			err = checkHost(&hostMap, comp.ID)
-			if err != nil {
-				err = checkHost(&hostMap, m)
+			e := checkHost(m, comp.ID)
+			if e != nil {
+				e = checkHost(m, m)
 			}
-			if err != nil {
-				return err
+			if e != nil {
+				notFound(m, e)
 			}
 		}
 	}
 	for _, n := range bp.Nids {
 		comp, ok := FindSMCompByNid(int(n))
 		if ok {
-			err = checkHost(&hostMap, comp.ID)
-			if err != nil {
-				err = checkHost(&hostMap, nidName(int(n)))
+			name := strconv.Itoa(int(n))
+			e := checkHost(name, comp.ID)
+			if e != nil {
+				e = checkHost(name, nidName(int(n)))
 			}
-			if err != nil {
-				return err
+			if e != nil {
+				notFound(name, e)
 			}
 		}
 	}
+	if err != nil {
+		// Nothing is changed if any of the hosts do not exist
+		for _, h := range hosts {
+			results = append(results, bssTypes.HostResult{Host: names[h], Status: bssTypes.HostNotApplied})
+		}
+		return results, err
+	}
 
 	switch {
 	case len(hostMap) > 0:
-		for h, bd := range hostMap {
+		var targets []paramTarget
+		for _, h := range hosts {
+			bd := hostMap[h]
 			updated := false
-			if bp.Params != "" && bp.Params != bd.Params {
+			params := bd.Params
+			if bp.Params != "" {
+				params = bp.Params
+			}
+			if bp.ParamsPatch != nil {
+				params, _ = patchParams(params, bp.ParamsPatch)
+			}
+			if params != bd.Params {
 				updated = true
-				bd.Params = bp.Params
+				bd.Params = params
 			}
 			if bp.Kernel != "" && kernel_id != bd.Kernel {
 				updated = true
@@ -543,26 +786,43 @@ func Update(bp bssTypes.BootParams) error {
 				updated = true
 				bd.Initrd = initrd_id
 			}
+			if bp.Profile != "" && bp.Profile != bd.Profile {
+				updated = true
+				bd.Profile = bp.Profile
+			}
 			if updateCloudInit(&bd.CloudInit, bp.CloudInit) {
 				updated = true
 			}
+			if len(variants) > 0 {
+				merged := mergeArchVariants(bd.ArchVariants, variants)
+				if !reflect.DeepEqual(merged, bd.ArchVariants) {
+					updated = true
+					bd.ArchVariants = merged
+				}
+			}
 			if updated {
-				err = storeData(paramsPfx+h, bd)
+				if err := checkRoleArches(h, bd); err != nil {
+					return nil, err
+				}
+				targets = append(targets, paramTarget{names[h], bssTypes.HostUpdated, storeOp(paramsPfx+h, bd)})
+			} else {
+				targets = append(targets, paramTarget{names[h], bssTypes.HostUnchanged, kvOp{key: paramsPfx + h, check: true}})
 			}
 		}
+		results, err = applyTargets(targets, images, cr)
 	case kernel_id != "":
 		// If no hosts were specified, then we should update the
 		// parameters associated with the kernel image.
-		idata := ImageData{bp.Kernel, bp.Params}
+		idata := imageWithParams(kernelOp, bp.Params)
 		debugf("Ready to store data: %s, %v\n", kernel_id, idata)
-		err = storeData(kernel_id, idata)
+		err = storeEntry(kernel_id, idata, cr)
 	case initrd_id != "":
-		err = storeData(initrd_id, ImageData{bp.Initrd, bp.Params})
+		err = storeEntry(initrd_id, imageWithParams(initrdOp, bp.Params), cr)
 	default:
 		// No changes required so we are done.
-		return nil
+		return nil, nil
 	}
-	return err
+	return results, err
 }
 
 func updateCloudData(existing *bssTypes.CloudDataType, merge bssTypes.CloudDataType, dataType string) bool {
@@ -831,7 +1091,7 @@ func lookup(name, altName, role, defaultTag string) BootData {
 
 	var bd BootData
 	if err == nil {
-		bd = bdConvert(bds)
+		bd = bdConvert(withProfile(bds))
 	}
 	return bd
 }
@@ -839,6 +1099,8 @@ func lookup(name, altName, role, defaultTag string) BootData {
 func bdConvertUsingImageCache(bds BootDataStore, kernelImages map[string]ImageData, initrdImages map[string]ImageData) (ret BootData) {
 	ret.Params = bds.Params
 	ret.CloudInit = bds.CloudInit
+	ret.Profile = bds.Profile
+	ret.ArchVariants = archVariantsConvert(bds.ArchVariants)
 	if bds.Kernel != "" {
 		if value, ok := kernelImages[bds.Kernel]; ok {
 			ret.Kernel = value
@@ -868,6 +1130,8 @@ func bdConvert(bds BootDataStore) (ret BootData) {
 	ret.Params = bds.Params
 	ret.CloudInit = bds.CloudInit
 	ret.ReferralToken = bds.ReferralToken
+	ret.Profile = bds.Profile
+	ret.ArchVariants = archVariantsConvert(bds.ArchVariants)
 	if bds.Kernel != "" {
 		imdata, err := getImage(bds.Kernel, "")
 		if err == nil {
@@ -883,13 +1147,28 @@ func bdConvert(bds BootDataStore) (ret BootData) {
 	return ret
 }
 
+// Look up the boot data of a node.  With parameter layering the data of all
+// of the layers which apply to the node is composed, otherwise lookup() picks
+// the most specific entry.
+func lookupNode(name, altName string, comp SMComponent) BootData {
+	if paramLayering {
+		bds, _, ok := composeLayers(nodeLayers(name, altName, comp))
+		if !ok {
+			debugf("Boot data for %s not available\n", name)
+			return BootData{}
+		}
+		return bdConvert(bds)
+	}
+	return lookup(name, altName, comp.Role, DefaultTag)
+}
+
 func LookupByRole(role string) (BootData, error) {
 	var bd BootData
 	bds, err := lookupHost(role)
 	if err != nil {
 		return bd, err
 	}
-	bd = bdConvert(bds)
+	bd = bdConvert(withProfile(bds))
 	return bd, err
 }
 
@@ -920,35 +1199,29 @@ func ToBootData(value string, kernelImages map[string]ImageData, initrdImages ma
 func LookupByName(name string) (BootData, SMComponent) {
 	comp_name := name
 	comp, ok := FindSMCompByName(name)
-	role := ""
 	if ok {
 		comp_name = comp.ID
-		role = comp.Role
 	}
-	return lookup(comp_name, name, role, DefaultTag), comp
+	return lookupNode(comp_name, name, comp), comp
 }
 
 func LookupByMAC(mac string) (BootData, SMComponent) {
 	comp_name := mac
 	comp, ok := FindSMCompByMAC(mac)
-	role := ""
 	if ok {
 		comp_name = comp.ID
-		role = comp.Role
 	}
-	return lookup(comp_name, mac, role, DefaultTag), comp
+	return lookupNode(comp_name, mac, comp), comp
 }
 
 func LookupByNid(nid int) (BootData, SMComponent) {
 	nid_str := nidName(nid)
 	comp_name := nid_str
 	comp, ok := FindSMCompByNid(nid)
-	role := ""
 	if ok {
 		comp_name = comp.ID
-		role = comp.Role
 	}
-	return lookup(comp_name, nid_str, role, DefaultTag), comp
+	return lookupNode(comp_name, nid_str, comp), comp
 }
 
 func dumpDataStore() {
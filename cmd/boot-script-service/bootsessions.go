@@ -0,0 +1,407 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+/*
+ * Boot sessions
+ *
+ * A boot session follows a node from its first boot script request until it
+ * is booted:
+ *
+ *   requested -> kernel-served -> cloud-init -> phoned-home -> booted
+ *
+ * A node starts a session with a boot script request without retry=, which
+ * only chained requests have.  It is served its kernel and initrd, fetches
+ * its cloud-init meta-data and user-data, and phones home, and the session is
+ * booted when HSM reports the node Ready.  Sessions are keyed by the referral
+ * token of the node's boot parameters and the node.  Only the latest session
+ * of each node is kept, and it is indexed by the node.
+ *
+ * A node which starts booting again before it phones home continues its
+ * session with one more attempt, and after BSS_BOOT_LOOP_THRESHOLD attempts
+ * it is stuck in a boot loop.  A node which hasn't phoned home is also stuck
+ * if it made no progress for BSS_BOOT_STUCK_TIMEOUT seconds.
+ *
+ * Requests queue their changes to sessions for bootSessionWorker(), so they
+ * don't wait for etcd.  Every BSS instance changes sessions, so a change is
+ * guarded by the session it read and made again if another changed it.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+const (
+	bootSessionsPfx     = "/boot-sessions/"      // /<token>/<xname>
+	bootSessionNodesPfx = "/boot-session-nodes/" // /<xname>, the token of its latest session
+	noReferralToken     = "-"                    // Parameters without a referral token
+)
+
+var (
+	bootLoopThreshold = 3
+	bootStuckTimeout  = 1800 // Seconds
+)
+
+// Changes to sessions queued for bootSessionWorker().  A change is dropped if
+// the queue is full.
+const bootSessionQueueLen = 4096
+
+type bootSessionEvent struct {
+	xname string
+	apply func(bs *bssTypes.BootSession, exists bool) bool
+	done  chan struct{} // Closed once the events before it are made
+}
+
+var bootSessionEvents = make(chan bootSessionEvent, bootSessionQueueLen)
+
+// Times a change to a session is made again when another BSS changed it.
+const bootSessionRetries = 5
+
+var bootStateOrder = map[string]int{
+	bssTypes.BootRequested:    0,
+	bssTypes.BootKernelServed: 1,
+	bssTypes.BootCloudInit:    2,
+	bssTypes.BootPhonedHome:   3,
+	bssTypes.BootBooted:       4,
+}
+
+func bootSessionKey(token, xname string) string {
+	return bootSessionsPfx + token + "/" + xname
+}
+
+func getBootSessionKey(key string) (bssTypes.BootSession, bool, error) {
+	var bs bssTypes.BootSession
+	val, exists, err := kvstore.Get(key)
+	if err != nil || !exists {
+		return bs, false, err
+	}
+	if err = json.Unmarshal([]byte(val), &bs); err != nil {
+		return bs, false, fmt.Errorf("Bad boot session %s: %s", key, err)
+	}
+	return bs, true, nil
+}
+
+// The latest boot session of a node.
+func getNodeBootSession(xname string) (bssTypes.BootSession, bool, error) {
+	token, exists, err := kvstore.Get(bootSessionNodesPfx + xname)
+	if err != nil || !exists {
+		return bssTypes.BootSession{}, false, err
+	}
+	return getBootSessionKey(bootSessionKey(token, xname))
+}
+
+// Change the latest session of a node.  The change is guarded by the
+// index of the node, which every change writes, and is made again if another
+// request changed the session in the meantime.  Apply returns false to leave
+// the session unchanged.
+func updateBootSession(xname string, apply func(bs *bssTypes.BootSession, exists bool) bool) error {
+	for attempt := 0; ; attempt++ {
+		indexKey := bootSessionNodesPfx + xname
+		token, indexRev, indexExists, err := getRev(indexKey)
+		if err != nil {
+			return err
+		}
+		var bs bssTypes.BootSession
+		var val string
+		var rev int64
+		exists := false
+		if indexExists {
+			if val, rev, exists, err = getRev(bootSessionKey(token, xname)); err != nil {
+				return err
+			}
+			if exists {
+				if err = json.Unmarshal([]byte(val), &bs); err != nil {
+					return fmt.Errorf("Bad boot session of %s: %s", xname, err)
+				}
+			}
+		}
+		if !apply(&bs, exists) {
+			return nil
+		}
+
+		bs.Stuck, bs.StuckReason = false, ""
+		key := bootSessionKey(bs.ReferralToken, xname)
+		ops := []kvOp{guardOp(kvOp{key: indexKey, value: bs.ReferralToken}, token, indexRev, indexExists)}
+		if exists && bs.ReferralToken == token {
+			ops = append(ops, guardOp(storeOp(key, bs), val, rev, true))
+		} else {
+			ops = append(ops, storeOp(key, bs))
+			if exists {
+				// Only the latest session of a node is kept
+				ops = append(ops, guardOp(kvOp{key: bootSessionKey(token, xname), delete: true}, val, rev, true))
+			}
+		}
+		_, err = applyBatch(ops)
+		if !errors.Is(err, errConflict) || attempt >= bootSessionRetries {
+			return err
+		}
+		debugf("Boot session of %s changed by another request, trying again", xname)
+	}
+}
+
+// Queue a change to the latest session of a node.
+func queueBootSession(xname string, apply func(bs *bssTypes.BootSession, exists bool) bool) {
+	select {
+	case bootSessionEvents <- bootSessionEvent{xname: xname, apply: apply}:
+	default:
+		log.Printf("WARNING: Boot session queue full, dropping a change to the session of %s", xname)
+	}
+}
+
+// Make the changes queued to sessions.
+func bootSessionWorker() {
+	for ev := range bootSessionEvents {
+		makeBootSessionChange(ev)
+	}
+}
+
+func makeBootSessionChange(ev bootSessionEvent) {
+	if ev.done != nil {
+		close(ev.done)
+		return
+	}
+	if err := updateBootSession(ev.xname, ev.apply); err != nil {
+		log.Printf("Failed to store the boot session of %s: %s", ev.xname, err)
+	}
+}
+
+// Wait for the changes queued so far to be made, up to a timeout.
+func flushBootSessions(timeout time.Duration) bool {
+	done := make(chan struct{})
+	select {
+	case bootSessionEvents <- bootSessionEvent{done: done}:
+	case <-time.After(timeout):
+		return false
+	}
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func bootSessionToken(token string) string {
+	if token == "" {
+		return noReferralToken
+	}
+	return token
+}
+
+func setBootState(bs *bssTypes.BootSession, state, now string) {
+	bs.State = state
+	bs.States[state] = now
+	bs.Updated = now
+}
+
+// Record a boot script request of a node.  Kernel and initrd are the images
+// of the boot script served, or empty if the node was told to chain back.
+func bootSessionRequested(xname, token string, retry int, kernel, initrd string) {
+	now := time.Now().Format(time.RFC3339)
+	token = bootSessionToken(token)
+	queueBootSession(xname, func(bs *bssTypes.BootSession, exists bool) bool {
+		switch {
+		case !exists || bs.ReferralToken != token ||
+			(retry == 0 && bootStateOrder[bs.State] >= bootStateOrder[bssTypes.BootPhonedHome]):
+			// A new boot, or new boot parameters
+			*bs = bssTypes.BootSession{Xname: xname, ReferralToken: token, Started: now,
+				States: map[string]string{}, Attempts: 1}
+			setBootState(bs, bssTypes.BootRequested, now)
+		case retry == 0:
+			// Booting again before phoning home
+			bs.Attempts++
+			bs.Started, bs.States = now, map[string]string{}
+			bs.Kernel, bs.Initrd, bs.MetaData, bs.UserData, bs.Retries = "", "", "", "", 0
+			setBootState(bs, bssTypes.BootRequested, now)
+			if bootLoopThreshold > 0 && bs.Attempts >= bootLoopThreshold {
+				log.Printf("WARNING: %s may be in a boot loop, %d boots without phoning home", xname, bs.Attempts)
+			}
+		default:
+			bs.Updated = now
+		}
+		if retry > bs.Retries {
+			bs.Retries = retry
+		}
+		if kernel != "" || initrd != "" {
+			bs.Kernel, bs.Initrd = kernel, initrd
+			setBootState(bs, bssTypes.BootKernelServed, now)
+		}
+		return true
+	})
+}
+
+// Move the latest boot session of a node to a later state.  Cloud-init
+// fetches also record the time of the fetch.  Nothing is done if the node
+// has no session, or is already past the state.
+func bootSessionProgress(xname, state, fetch string) {
+	now := time.Now().Format(time.RFC3339)
+	queueBootSession(xname, func(bs *bssTypes.BootSession, exists bool) bool {
+		if !exists {
+			return false
+		}
+		changed := false
+		switch fetch {
+		case bssTypes.EventMetaData:
+			bs.MetaData, changed = now, true
+		case bssTypes.EventUserData:
+			bs.UserData, changed = now, true
+		}
+		if bootStateOrder[state] > bootStateOrder[bs.State] {
+			// Only a node which got its kernel is booted when HSM says it is
+			// Ready, since it may have been Ready before this boot.
+			if state != bssTypes.BootBooted || bs.State != bssTypes.BootRequested {
+				setBootState(bs, state, now)
+				changed = true
+			}
+		}
+		if changed {
+			bs.Updated = now
+		}
+		return changed
+	})
+}
+
+// Fill in whether a session is stuck.
+func checkBootSession(bs *bssTypes.BootSession, now time.Time) {
+	bs.Stuck, bs.StuckReason = false, ""
+	if bootStateOrder[bs.State] >= bootStateOrder[bssTypes.BootPhonedHome] {
+		return
+	}
+	if bootLoopThreshold > 0 && bs.Attempts >= bootLoopThreshold {
+		bs.Stuck = true
+		bs.StuckReason = fmt.Sprintf("boot loop, %d boots without phoning home", bs.Attempts)
+		return
+	}
+	updated, err := time.Parse(time.RFC3339, bs.Updated)
+	if err == nil && bootStuckTimeout > 0 && now.Sub(updated) > time.Duration(bootStuckTimeout)*time.Second {
+		bs.Stuck = true
+		bs.StuckReason = fmt.Sprintf("no progress since %s at %s", bs.State, bs.Updated)
+	}
+}
+
+func getBootSessions() ([]bssTypes.BootSession, error) {
+	kvl, err := kvstore.GetRange(bootSessionsPfx+keyMin, bootSessionsPfx+keyMax)
+	if err != nil {
+		return nil, err
+	}
+	var sessions []bssTypes.BootSession
+	for _, kv := range kvl {
+		var bs bssTypes.BootSession
+		if err := json.Unmarshal([]byte(kv.Value), &bs); err != nil {
+			log.Printf("Bad boot session %s: %s", kv.Key, err)
+			continue
+		}
+		sessions = append(sessions, bs)
+	}
+	return sessions, nil
+}
+
+// List boot sessions, or get the latest session of a node.  The list may be
+// filtered by xname=, referral-token=, state= and stuck=.
+func bootSessionsGetAPI(w http.ResponseWriter, r *http.Request, xname string) {
+	debugf("bootSessionsGetAPI(): Received request %v\n", r.URL)
+	now := time.Now()
+	if xname != "" {
+		bs, exists, err := getNodeBootSession(base.NormalizeHMSCompID(xname))
+		switch {
+		case err != nil:
+			base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+				fmt.Sprintf("Failed to retrieve the boot session of %s: %s", xname, err))
+		case !exists:
+			base.SendProblemDetailsGeneric(w, http.StatusNotFound,
+				fmt.Sprintf("%s has no boot session", xname))
+		default:
+			checkBootSession(&bs, now)
+			sendJSON(w, http.StatusOK, bs)
+		}
+		return
+	}
+
+	q := r.URL.Query()
+	var stuck *bool
+	if s := q.Get("stuck"); s != "" {
+		b := strings.EqualFold(s, "true")
+		stuck = &b
+	}
+	xnames := filterSet(q["xname"], base.NormalizeHMSCompID)
+	tokens := filterSet(q["referral-token"], strings.ToLower)
+	states := filterSet(q["state"], strings.ToLower)
+	for s := range states {
+		if _, ok := bootStateOrder[s]; !ok {
+			base.SendProblemDetailsGeneric(w, http.StatusBadRequest, fmt.Sprintf("Unknown boot state %s", s))
+			return
+		}
+	}
+	sessions, err := getBootSessions()
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+			fmt.Sprintf("Failed to retrieve boot sessions: %s", err))
+		return
+	}
+	list := []bssTypes.BootSession{}
+	for _, bs := range sessions {
+		checkBootSession(&bs, now)
+		if (xnames == nil || xnames[bs.Xname]) && (tokens == nil || tokens[bs.ReferralToken]) &&
+			(states == nil || states[bs.State]) && (stuck == nil || *stuck == bs.Stuck) {
+			list = append(list, bs)
+		}
+	}
+	sendJSON(w, http.StatusOK, list)
+}
+
+// Delete the sessions of a node, which clears a boot loop once it is fixed.
+func bootSessionsDeleteAPI(w http.ResponseWriter, r *http.Request, xname string) {
+	debugf("bootSessionsDeleteAPI(): Received request %v\n", r.URL)
+	xname = base.NormalizeHMSCompID(xname)
+	sessions, err := getBootSessions()
+	if err == nil {
+		if _, exists, _ := kvstore.Get(bootSessionNodesPfx + xname); !exists {
+			base.SendProblemDetailsGeneric(w, http.StatusNotFound,
+				fmt.Sprintf("%s has no boot session", xname))
+			return
+		}
+		for _, bs := range sessions {
+			if bs.Xname == xname && err == nil {
+				err = kvstore.Delete(bootSessionKey(bs.ReferralToken, xname))
+			}
+		}
+	}
+	if err == nil {
+		err = kvstore.Delete(bootSessionNodesPfx + xname)
+	}
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+			fmt.Sprintf("Failed to delete the boot sessions of %s: %s", xname, err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,263 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+// Make the queued changes to boot sessions, as bootSessionWorker() does.
+// The worker doesn't run in tests, since the mem KV store can't be read and
+// written at the same time.
+func waitBootSessions(t *testing.T) {
+	for {
+		select {
+		case ev := <-bootSessionEvents:
+			makeBootSessionChange(ev)
+		default:
+			return
+		}
+	}
+}
+
+func deleteBootSessions(t *testing.T, xname string) {
+	waitBootSessions(t)
+	serveRequest(t, bootSessions, http.MethodDelete, "/bootsessions/"+xname, nil)
+}
+
+func TestBootSessions(t *testing.T) {
+	const node = "x0c0s5b0n0"
+	store := func(kernel string) string {
+		err, token := Store(bssTypes.BootParams{Hosts: []string{node}, Params: "quiet", Kernel: kernel})
+		if err != nil {
+			t.Fatalf("Store() failed: %v", err)
+		}
+		return token
+	}
+	token := store("http://images/kernel")
+	defer func() {
+		deleteHost(node)
+		removeImage("http://images/kernel", kernelImageType, changeRequest{})
+		removeImage("http://images/new/kernel", kernelImageType, changeRequest{})
+	}()
+	defer deleteBootSessions(t, node)
+	defer func() {
+		for _, k := range hsmChangeKeys() {
+			kvstore.Delete(k)
+		}
+	}()
+
+	boot := func(retry string) {
+		t.Helper()
+		rr := serveRequest(t, bootScript, http.MethodGet, "/bootscript?name="+node+retry, nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GET bootscript returned %d: %s", rr.Code, rr.Body)
+		}
+	}
+	session := func() bssTypes.BootSession {
+		t.Helper()
+		waitBootSessions(t)
+		rr := serveRequest(t, bootSessions, http.MethodGet, "/bootsessions/"+node, nil)
+		var bs bssTypes.BootSession
+		if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &bs) != nil {
+			t.Fatalf("GET session returned %d: %s", rr.Code, rr.Body)
+		}
+		return bs
+	}
+
+	boot("")
+	boot("&retry=2")
+	bs := session()
+	if bs.ReferralToken != token || bs.State != bssTypes.BootKernelServed || bs.Kernel != "http://images/kernel" ||
+		bs.Attempts != 1 || bs.Retries != 2 || bs.States[bssTypes.BootRequested] == "" || bs.Stuck {
+		t.Errorf("Unexpected session %+v", bs)
+	}
+
+	// The node starts booting again before phoning home, twice
+	bootSessionProgress(node, bssTypes.BootCloudInit, bssTypes.EventMetaData)
+	boot("")
+	boot("")
+	bs = session()
+	if bs.Attempts != 3 || bs.State != bssTypes.BootKernelServed || bs.MetaData != "" || !bs.Stuck ||
+		!strings.Contains(bs.StuckReason, "boot loop") {
+		t.Errorf("Boot loop not detected: %+v", bs)
+	}
+	rr := serveRequest(t, bootSessions, http.MethodGet, "/bootsessions?stuck=true&xname="+node, nil)
+	var list []bssTypes.BootSession
+	if json.Unmarshal(rr.Body.Bytes(), &list); len(list) != 1 || list[0].Xname != node {
+		t.Errorf("GET ?stuck=true returned %s", rr.Body)
+	}
+
+	bootSessionProgress(node, bssTypes.BootCloudInit, bssTypes.EventUserData)
+	bootSessionProgress(node, bssTypes.BootPhonedHome, "")
+	bootSessionProgress(node, bssTypes.BootCloudInit, bssTypes.EventMetaData)
+	rr = serveRequest(t, scn, http.MethodPost, "/scn", `{"Components":["`+node+`"],"State":"Ready"}`)
+	bs = session()
+	if rr.Code != http.StatusNoContent || bs.State != bssTypes.BootBooted || bs.Stuck || bs.UserData == "" ||
+		bs.MetaData == "" || bs.States[bssTypes.BootPhonedHome] == "" {
+		t.Errorf("Session not booted: %+v", bs)
+	}
+
+	// A new boot after booting, and then with new parameters
+	boot("")
+	if bs = session(); bs.Attempts != 1 || bs.State != bssTypes.BootKernelServed || bs.UserData != "" {
+		t.Errorf("New boot did not start a new session: %+v", bs)
+	}
+	newToken := store("http://images/new/kernel")
+	boot("")
+	if bs = session(); bs.ReferralToken != newToken || bs.Kernel != "http://images/new/kernel" {
+		t.Errorf("New parameters did not start a new session: %+v", bs)
+	}
+	if _, exists, _ := getBootSessionKey(bootSessionKey(token, node)); exists {
+		t.Errorf("Session of the old parameters was kept")
+	}
+	rr = serveRequest(t, bootSessions, http.MethodGet, "/bootsessions?referral-token="+newToken, nil)
+	if json.Unmarshal(rr.Body.Bytes(), &list); len(list) != 1 || list[0].ReferralToken != newToken {
+		t.Errorf("GET ?referral-token= returned %s", rr.Body)
+	}
+
+	if rr = serveRequest(t, bootSessions, http.MethodDelete, "/bootsessions/"+node, nil); rr.Code != http.StatusNoContent {
+		t.Errorf("DELETE returned %d: %s", rr.Code, rr.Body)
+	}
+	if rr = serveRequest(t, bootSessions, http.MethodGet, "/bootsessions/"+node, nil); rr.Code != http.StatusNotFound {
+		t.Errorf("GET after DELETE returned %d", rr.Code)
+	}
+	if rr = serveRequest(t, bootSessions, http.MethodGet, "/bootsessions?state=stuck", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("GET with an unknown state returned %d", rr.Code)
+	}
+}
+
+// Nodes which don't phone home are booted once HSM reports them Ready, which
+// needs the subscription to include it.
+func TestBootSessionReady(t *testing.T) {
+	const node = "x0c0s5b0n0"
+	if err, _ := Store(bssTypes.BootParams{Hosts: []string{node}, Params: "quiet", Kernel: "http://images/kernel"}); err != nil {
+		t.Fatalf("Store() failed: %v", err)
+	}
+	cleanupImages(t, kernelImageType, "http://images/kernel")
+	defer deleteHost(node)
+	defer deleteBootSessions(t, node)
+	defer func() {
+		for _, k := range hsmChangeKeys() {
+			kvstore.Delete(k)
+		}
+	}()
+
+	nfd := &fakeNFD{subs: map[string]ScnSubscribe{}}
+	srv := httptest.NewServer(nfd)
+	defer srv.Close()
+	saved := notifier
+	notifier = newNotifier("bss-test", srv.URL+"/hmi/v1/subscribe", "http://bss/boot/v1/scn", "")
+	go notifier.run()
+	defer func() {
+		notifier.shutdown(5 * time.Second)
+		notifier = saved
+	}()
+	notifier.setSubscription(scnSubscription([]SMComponent{{Component: base.Component{ID: node}}}, nil))
+	waitSCNStatus(t, notifier, "the subscription", func(st bssTypes.SCNStatus) bool {
+		return st.State == bssTypes.SCNSubscribed
+	})
+	sub, _ := nfd.subscription()
+	ready := false
+	for _, st := range sub.States {
+		ready = ready || st == "ready"
+	}
+	if !ready {
+		t.Fatalf("Subscribed to %v, which doesn't include ready", sub.States)
+	}
+
+	rr := serveRequest(t, bootScript, http.MethodGet, "/bootscript?name="+node, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET bootscript returned %d: %s", rr.Code, rr.Body)
+	}
+	rr = serveRequest(t, scn, http.MethodPost, "/scn", `{"Components":["`+node+`"],"State":"Ready"}`)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("POST scn returned %d: %s", rr.Code, rr.Body)
+	}
+	waitBootSessions(t)
+	var bs bssTypes.BootSession
+	rr = serveRequest(t, bootSessions, http.MethodGet, "/bootsessions/"+node, nil)
+	if json.Unmarshal(rr.Body.Bytes(), &bs); bs.State != bssTypes.BootBooted || bs.Stuck {
+		t.Errorf("Session not booted by the Ready notification: %s", rr.Body)
+	}
+}
+
+// A change made by another BSS while a session is changed isn't lost.
+func TestUpdateBootSessionConflict(t *testing.T) {
+	const node = "x8c9s0b0n0"
+	defer deleteBootSessions(t, node)
+	start := func(bs *bssTypes.BootSession, exists bool) bool {
+		*bs = bssTypes.BootSession{Xname: node, ReferralToken: "tok", States: map[string]string{}, Attempts: 1}
+		return true
+	}
+	if err := updateBootSession(node, start); err != nil {
+		t.Fatalf("updateBootSession() failed: %v", err)
+	}
+
+	calls := 0
+	err := updateBootSession(node, func(bs *bssTypes.BootSession, exists bool) bool {
+		calls++
+		if calls == 1 {
+			// Another BSS records the meta-data fetch in the meantime
+			updateBootSession(node, func(other *bssTypes.BootSession, exists bool) bool {
+				other.MetaData = "then"
+				return true
+			})
+		}
+		bs.UserData = "now"
+		return true
+	})
+	bs, _, _ := getNodeBootSession(node)
+	if err != nil || calls != 2 || bs.MetaData != "then" || bs.UserData != "now" {
+		t.Errorf("Session %+v after %d calls, %v", bs, calls, err)
+	}
+}
+
+func TestCheckBootSession(t *testing.T) {
+	now := time.Now()
+	updated := now.Add(-time.Duration(bootStuckTimeout+60) * time.Second).Format(time.RFC3339)
+	tests := []struct {
+		bs    bssTypes.BootSession
+		stuck bool
+	}{
+		{bssTypes.BootSession{State: bssTypes.BootKernelServed, Attempts: 1, Updated: now.Format(time.RFC3339)}, false},
+		{bssTypes.BootSession{State: bssTypes.BootCloudInit, Attempts: 1, Updated: updated}, true},
+		{bssTypes.BootSession{State: bssTypes.BootPhonedHome, Attempts: 1, Updated: updated}, false},
+		{bssTypes.BootSession{State: bssTypes.BootRequested, Attempts: bootLoopThreshold, Updated: now.Format(time.RFC3339)}, true},
+		{bssTypes.BootSession{State: bssTypes.BootBooted, Attempts: bootLoopThreshold, Updated: updated}, false},
+	}
+	for _, tt := range tests {
+		bs := tt.bs
+		if checkBootSession(&bs, now); bs.Stuck != tt.stuck || (bs.Stuck && bs.StuckReason == "") {
+			t.Errorf("checkBootSession(%+v) stuck %v: %s, expected %v", tt.bs, bs.Stuck, bs.StuckReason, tt.stuck)
+		}
+	}
+}
@@ -56,6 +56,45 @@ func TestMain(m *testing.M) {
 	os.Exit(excode)
 }
 
+// Remove the images a test stores once it has finished, along with any
+// references to them, so that the test can run again.
+func cleanupImages(t *testing.T, imtype string, paths ...string) {
+	t.Cleanup(func() {
+		for _, p := range paths {
+			removeImage(p, imtype, changeRequest{})
+		}
+	})
+}
+
+// Delete the boot parameters of a host once a test is done with them.  The
+// change is not recorded in the history.
+func deleteHost(h string) {
+	kvstore.Delete(paramsPfx + h)
+}
+
+// Serve a request for testBaseURL+path with a handler.  A string body is
+// sent as is, anything else but nil is encoded as JSON.
+func serveRequest(t testing.TB, handler http.HandlerFunc, method, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	switch b := body.(type) {
+	case nil:
+	case string:
+		buf.WriteString(b)
+	default:
+		if err := json.NewEncoder(&buf).Encode(b); err != nil {
+			t.Fatal("Cannot encode the request body:", err)
+		}
+	}
+	req, err := http.NewRequest(method, testBaseURL+path, &buf)
+	if err != nil {
+		t.Fatal("Cannot create http request:", err)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
 func TestFindSM(t *testing.T) {
 	tables := []struct {
 		host string
@@ -200,3 +239,35 @@ func TestStoreAndLookup(t *testing.T) {
 			len(tables), len(bplist))
 	}
 }
+
+func TestUpdateParamsPatch(t *testing.T) {
+	bp := bssTypes.BootParams{Hosts: []string{"x9c0s5b0n0"}, Params: "console=tty0 quiet console=ttyS0 -- single"}
+	if err, _ := Store(bp); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	defer deleteHost("x9c0s5b0n0")
+
+	patch := bssTypes.BootParams{
+		Hosts: []string{"x9c0s5b0n0"},
+		ParamsPatch: &bssTypes.ParamsPatch{
+			Remove: []string{"quiet", "console=tty0"},
+			Set:    []string{"console=ttyS1,115200"},
+			Add:    []string{"root='live:LABEL=my root'"},
+		},
+	}
+	if err := Update(patch); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	want := "console=ttyS1,115200 root='live:LABEL=my root' -- single"
+	if bds, _ := lookupHost("x9c0s5b0n0"); bds.Params != want {
+		t.Errorf("Patched params '%s', expected '%s'", bds.Params, want)
+	}
+
+	patch.ParamsPatch = &bssTypes.ParamsPatch{Add: []string{"a b"}}
+	if err := Update(patch); err == nil {
+		t.Errorf("Expected a patch entry of two words to fail")
+	}
+	if err, _ := Store(bssTypes.BootParams{Hosts: []string{"x9c0s5b0n0"}, Params: "root='live"}); err == nil {
+		t.Errorf("Expected params with an unterminated quote to fail")
+	}
+}
@@ -205,6 +205,10 @@ func metaDataGetAPI(w http.ResponseWriter, r *http.Request) {
 		// No query, return all data
 		json.NewEncoder(w).Encode(mergedData)
 	}
+	publishNodeEvent(bssTypes.BootEvent{Type: bssTypes.EventMetaData, Xname: xname, IP: remoteaddr}, SMComponent{})
+	if found {
+		bootSessionProgress(xname, bssTypes.BootCloudInit, bssTypes.EventMetaData)
+	}
 
 	w.WriteHeader(httpStatus)
 	return
@@ -275,6 +279,10 @@ func userDataGetAPI(w http.ResponseWriter, r *http.Request) {
 
 	// Record the fact this was asked for.
 	updateEndpointAccessed(xname, bssTypes.EndpointTypeUserData)
+	publishNodeEvent(bssTypes.BootEvent{Type: bssTypes.EventUserData, Xname: xname, IP: remoteaddr}, SMComponent{})
+	if found {
+		bootSessionProgress(xname, bssTypes.BootCloudInit, bssTypes.EventUserData)
+	}
 
 	return
 }
@@ -343,7 +351,7 @@ func phoneHomePostAPI(w http.ResponseWriter, r *http.Request) {
 	bp.Hosts = hosts
 	bp.CloudInit = bootdata.CloudInit
 
-	if err = Update(bp); err != nil {
+	if _, err = updateParams(bp, changeRequest{who: xname + " phone-home"}); err != nil {
 		LogBootParameters(fmt.Sprintf("/phone-home FAILED: %s", err.Error()), args)
 		base.SendProblemDetailsGeneric(w, http.StatusNotFound,
 			fmt.Sprintf("Not Found: %s", err))
@@ -351,6 +359,8 @@ func phoneHomePostAPI(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("POST /phone-home, xname: %s ip: %s", xname, remoteaddr)
+	publishNodeEvent(bssTypes.BootEvent{Type: bssTypes.EventPhoneHome, Xname: xname, IP: remoteaddr}, SMComponent{})
+	bootSessionProgress(xname, bssTypes.BootPhonedHome, "")
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	w.WriteHeader(http.StatusOK)
 	out, _ := json.Marshal(bp)
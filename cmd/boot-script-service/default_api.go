@@ -54,6 +54,7 @@ import (
 
 	base "github.com/Cray-HPE/hms-base"
 	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+	"github.com/Cray-HPE/hms-bss/pkg/cmdline"
 	hms_s3 "github.com/Cray-HPE/hms-s3"
 )
 
@@ -88,6 +89,7 @@ type scriptParams struct {
 	xname         string
 	nid           string
 	referralToken string
+	mac           string
 }
 
 // Note that we allow an empty string if the env variable is defined as such.
@@ -188,6 +190,8 @@ func BootparametersGetAll(w http.ResponseWriter, r *http.Request) {
 		results = append(results, bp)
 	}
 	var names []string
+	ol, _ := cachedOverrides()
+	now := time.Now()
 	if kvl, e := getTags(); e == nil {
 		for _, x := range kvl {
 			name := extractParamName(x)
@@ -202,6 +206,10 @@ func BootparametersGetAll(w http.ResponseWriter, r *http.Request) {
 				bp.Kernel = bd.Kernel.Path
 				bp.Initrd = bd.Initrd.Path
 				bp.CloudInit = bd.CloudInit
+				bp.Profile = bd.Profile
+				bp.ArchVariants = archVariantsAPI(bd.ArchVariants)
+				bp.ETag = entryETag(x.Value)
+				bp.Override = entryOverride(ol, name, now)
 				results = append(results, bp)
 			}
 		}
@@ -285,6 +293,8 @@ func BootparametersGet(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
+	ol, _ := cachedOverrides()
+	now := time.Now()
 	var unfoundHosts []string
 	for _, v := range args.Hosts {
 		bd, err := LookupBootData(v)
@@ -295,6 +305,10 @@ func BootparametersGet(w http.ResponseWriter, r *http.Request) {
 			bp.Kernel = bd.Kernel.Path
 			bp.Initrd = bd.Initrd.Path
 			bp.CloudInit = bd.CloudInit
+			bp.Profile = bd.Profile
+			bp.ArchVariants = archVariantsAPI(bd.ArchVariants)
+			bp.ETag = paramsETag(v)
+			bp.Override = entryOverride(ol, v, now)
 			results = append(results, bp)
 		} else {
 			unfoundHosts = append(unfoundHosts, v)
@@ -303,54 +317,50 @@ func BootparametersGet(w http.ResponseWriter, r *http.Request) {
 	args.Hosts = unfoundHosts
 
 	if len(args.Hosts) > 0 || len(args.Macs) > 0 || len(args.Nids) > 0 {
-
-		nameValues := GetNamesAndValues()
+		// Look up the entries of the components with the requested FQDNs,
+		// MACs and NIDs.  The remaining hosts have no entry of their own,
+		// so only an FQDN can still match one.
+		var names []string
+		seen := make(map[string]bool)
+		add := func(comp SMComponent, ok bool) {
+			if ok && !seen[comp.ID] {
+				seen[comp.ID] = true
+				names = append(names, comp.ID)
+			}
+		}
+		for _, v := range args.Hosts {
+			add(FindSMCompByName(v))
+		}
+		for _, m := range args.Macs {
+			add(FindSMCompByMAC(m))
+		}
+		for _, n := range args.Nids {
+			add(FindSMCompByNid(int(n)))
+		}
 
 		kernelImages := make(map[string]ImageData)
 		initrdImages := make(map[string]ImageData)
-		for name, value := range nameValues {
-			smc := LookupComponentByName(name)
+		for _, name := range names {
+			value, exists, e := kvstore.Get(paramsPfx + name)
+			if e != nil || !exists {
+				continue
+			}
 			bd, parseErr := ToBootData(value, kernelImages, initrdImages)
 			if parseErr != nil {
 				log.Printf("Failed to parse etcd value for %s: %v\n", name, parseErr)
 			}
-
-			debugf("Found %s: %v | %v\n", name, bd, smc)
+			debugf("Found %s: %v\n", name, bd)
 			var bp bssTypes.BootParams
-			ok := false
-			for _, v := range args.Hosts {
-				if v == smc.ID || v == smc.Fqdn || v == name {
-					ok = true
-					break
-				}
-			}
-			if !ok {
-			Outer:
-				for _, v := range args.Macs {
-					for _, m := range smc.Mac {
-						if strings.EqualFold(v, m) {
-							ok = true
-							break Outer
-						}
-					}
-				}
-			}
-			if !ok {
-				for _, v := range args.Nids {
-					if nid, err := smc.NID.Int64(); err == nil && int64(v) == nid {
-						ok = true
-						break
-					}
-				}
-			}
-			if ok {
-				bp.Hosts = append(bp.Hosts, name)
-				bp.Params = bd.Params
-				bp.Kernel = bd.Kernel.Path
-				bp.Initrd = bd.Initrd.Path
-				bp.CloudInit = bd.CloudInit
-				results = append(results, bp)
-			}
+			bp.Hosts = append(bp.Hosts, name)
+			bp.Params = bd.Params
+			bp.Kernel = bd.Kernel.Path
+			bp.Initrd = bd.Initrd.Path
+			bp.CloudInit = bd.CloudInit
+			bp.Profile = bd.Profile
+			bp.ArchVariants = archVariantsAPI(bd.ArchVariants)
+			bp.ETag = entryETag(value)
+			bp.Override = entryOverride(ol, name, now)
+			results = append(results, bp)
 		}
 	}
 	if results == nil {
@@ -387,6 +397,9 @@ func BootparametersGet(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	if len(results) == 1 {
+		setETag(w, results[0].ETag)
+	}
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	w.WriteHeader(http.StatusOK)
 	err = json.NewEncoder(w).Encode(results)
@@ -416,18 +429,21 @@ func BootparametersPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	debugf("Received boot parameters: %v\n", args)
-	err, referralToken := StoreNew(args)
+	results, referralToken, err := storeNewParams(args, changeRequest{who: requestUser(r)})
 	if err == nil {
 		LogBootParameters("/bootparameters POST", args)
-		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		if referralToken != "" {
 			w.Header().Set("BSS-Referral-Token", referralToken)
 		}
-		w.WriteHeader(http.StatusCreated)
+		sendHostResults(w, http.StatusCreated, results)
 	} else {
 		LogBootParameters(fmt.Sprintf("/bootparameters POST FAILED: %s", err.Error()), args)
-		base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
-			fmt.Sprintf("Bad Request: %s", err))
+		if results != nil {
+			sendHostResultsProblem(w, err, http.StatusBadRequest, results)
+		} else {
+			base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+				fmt.Sprintf("Bad Request: %s", err))
+		}
 	}
 }
 
@@ -443,19 +459,21 @@ func BootparametersPut(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	debugf("Received boot parameters: %v\n", args)
-	err, referralToken := Store(args)
+	results, referralToken, err := storeParams(args, changeRequest{who: requestUser(r), ifMatch: ifMatchETags(r)})
 	if err == nil {
 		LogBootParameters("/bootparameters PUT", args)
-		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		if referralToken != "" {
 			w.Header().Set("BSS-Referral-Token", referralToken)
 		}
-		w.WriteHeader(http.StatusOK)
+		if len(results) == 1 {
+			setETag(w, results[0].ETag)
+		}
+		sendHostResults(w, http.StatusOK, results)
 	} else {
-		LogBootParameters(fmt.Sprintf("/bootparameters PATCH FAILED: %s", err.Error()), args)
+		LogBootParameters(fmt.Sprintf("/bootparameters PUT FAILED: %s", err.Error()), args)
 		herr, ok := base.GetHMSError(err)
 		if ok && herr.GetProblem() != nil {
-			base.SendProblemDetails(w, herr.GetProblem(), 0)
+			sendHostResultsProblem(w, err, 0, results)
 		} else {
 			base.SendProblemDetailsGeneric(w, http.StatusBadRequest, "No data")
 		}
@@ -474,15 +492,21 @@ func BootparametersPatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	debugf("Received boot parameters: %v\n", args)
-	err = Update(args)
+	results, err := updateParams(args, changeRequest{who: requestUser(r), ifMatch: ifMatchETags(r)})
 	if err != nil {
 		LogBootParameters(fmt.Sprintf("/bootparameters PATCH FAILED: %s", err.Error()), args)
-		base.SendProblemDetailsGeneric(w, http.StatusNotFound,
-			fmt.Sprintf("Not Found: %s", err))
+		herr, ok := base.GetHMSError(err)
+		if ok && herr.GetProblem() != nil && herr.GetProblem().Status != http.StatusNotFound {
+			sendHostResultsProblem(w, err, 0, results)
+		} else {
+			sendHostResultsProblem(w, fmt.Errorf("Not Found: %s", err), http.StatusNotFound, results)
+		}
 	} else {
 		LogBootParameters("/bootparameters PATCH", args)
-		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-		w.WriteHeader(http.StatusOK)
+		if len(results) == 1 {
+			setETag(w, results[0].ETag)
+		}
+		sendHostResults(w, http.StatusOK, results)
 	}
 }
 
@@ -497,16 +521,19 @@ func BootparametersDelete(w http.ResponseWriter, r *http.Request) {
 			fmt.Sprintf("Bad Request: %s", err))
 		return
 	}
-	if err == nil {
-		err = Remove(args)
-	}
+	results, err := removeParams(args, changeRequest{who: requestUser(r), ifMatch: ifMatchETags(r)})
 	if err != nil {
 		LogBootParameters(fmt.Sprintf("/bootparameters DELETE FAILED: %s", err.Error()), args)
-		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, err.Error())
+		if herr, ok := base.GetHMSError(err); ok && herr.GetProblem() != nil &&
+			herr.GetProblem().Status == http.StatusPreconditionFailed {
+			sendHostResultsProblem(w, err, 0, results)
+		} else {
+			// Deleting a host which does not exist is a bad request
+			sendHostResultsProblem(w, fmt.Errorf("%s", err), http.StatusBadRequest, results)
+		}
 	} else {
 		LogBootParameters("/bootparameters DELETE", args)
-		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-		w.WriteHeader(http.StatusOK)
+		sendHostResults(w, http.StatusOK, results)
 	}
 }
 
@@ -524,31 +551,6 @@ func getIntParam(r *http.Request, param string, def int64) (int64, error) {
 	return ret, err
 }
 
-// Function paramExists checks for a specific boot parameter to see if it exists
-// in the current boot parameters.
-func paramExists(params, pname string) bool {
-	for _, s := range strings.Split(params, " ") {
-		if strings.HasPrefix(s, pname) {
-			return true
-		}
-	}
-	return false
-}
-
-// Function checkParam() scans the current parameter string looking for a
-// parameter pname.  If this parameter does not exist, the it is added to the
-// parameter string with the value pval.  If the parameter is already found in
-// the parameter string, then it is unchanged.  The resultant parameter string
-// is returned.
-func checkParam(params, pname, pval string) string {
-	debugf("checkParam(\"%s\", \"%s\", \"%s\")\n", params, pname, pval)
-	if pval != "" && !paramExists(params, pname) {
-		params += " " + pname + pval
-	}
-	debugf("checkParam returning \"%s\"\n", params)
-	return params
-}
-
 type paramValRetreiver func() (string, error)
 
 func paramSubstitute(params, pvar string, getVal paramValRetreiver) (string, error) {
@@ -574,11 +576,14 @@ func paramSubstitute(params, pvar string, getVal paramValRetreiver) (string, err
 // BootData and additional parameters provided.  The resultant script is
 // returned as a string.  If an error occurs, a null string is returned along
 // with the error.
-func buildBootScript(bd BootData, sp scriptParams, chain, role, subRole, descr string) (string, error) {
-	debugf("buildBootScript(%v, %v, %v, %v, %v, %v)\n", bd, sp, chain, role, subRole, descr)
+func buildBootScript(bd BootData, sp scriptParams, rdr bootRenderer, chain, role, subRole, descr string) (string, error) {
+	debugf("buildBootScript(%v, %v, %v, %v, %v, %v, %v)\n", bd, sp, rdr.name(), chain, role, subRole, descr)
 	if bd.Kernel.Path == "" {
 		return "", fmt.Errorf("%s: this host not configured for booting.", descr)
 	}
+	if err := checkBootImages(bd); err != nil {
+		return "", fmt.Errorf("%s: %s", descr, err)
+	}
 
 	params := bd.Params
 	if bd.Kernel.Params != "" {
@@ -589,16 +594,24 @@ func buildBootScript(bd BootData, sp scriptParams, chain, role, subRole, descr s
 	}
 
 	// Check for special boot parameters.
-	params = checkParam(params, "xname=", sp.xname)
-	params = checkParam(params, "nid=", sp.nid)
-	if sp.referralToken != "" {
-		params = checkParam(params, "bss_referral_token=", sp.referralToken)
+	cl, perr := cmdline.Parse(params)
+	if perr != nil {
+		log.Printf("%s: %s", descr, perr)
 	}
+	cl.SetDefault("xname", sp.xname)
+	cl.SetDefault("nid", sp.nid)
+	cl.SetDefault("bss_referral_token", sp.referralToken)
 
 	// Inject the cloud init address info into the kernel params. If the target
 	// image does not have cloud-init enabled this wont hurt anything.
 	// If it does, it tells it to come back to us for the cloud-init meta-data
-	params = checkParam(params, "ds=", fmt.Sprintf("nocloud-net;s=%s/", advertiseAddress))
+	cl.SetDefault("ds", fmt.Sprintf("nocloud-net;s=%s/", advertiseAddress))
+
+	if bd.Initrd.Path != "" {
+		// The renderer supplies its own initrd argument, if one is needed
+		cl.Remove("initrd")
+	}
+	params = cl.String()
 
 	var err error
 	params, err = paramSubstitute(params, joinTokenVarName,
@@ -614,47 +627,40 @@ func buildBootScript(bd BootData, sp scriptParams, chain, role, subRole, descr s
 		err = nil
 	}
 
-	script := "#!ipxe\n"
-	if bd.Initrd.Path != "" {
-		start := strings.Index(params, "initrd")
-		if start != -1 {
-			end := start
-			for string(params[end]) != " " {
-				end++
-			}
-			params = params[:start] + params[end:]
-		}
-		params = "initrd=initrd " + params
+	d := bootScriptData{
+		Params:        params,
+		Xname:         sp.xname,
+		Nid:           sp.nid,
+		Mac:           sp.mac,
+		Role:          role,
+		SubRole:       subRole,
+		ReferralToken: sp.referralToken,
+		Arch:          rdr.archVar(),
+		ChainURL:      chain,
+		Delay:         retryDelay,
 	}
-	u := bd.Kernel.Path
-	u, err = checkURL(u)
-	if err == nil {
-		script += "kernel --name kernel " + u + " " + strings.Trim(params, " ")
-		script += " || goto boot_retry\n"
-		if bd.Initrd.Path != "" {
-			u, err = checkURL(bd.Initrd.Path)
-			if err == nil {
-				script += "initrd --name initrd " + u + " || goto boot_retry\n"
-			}
+	d.Kernel, err = imageURL(bd.Kernel.Path)
+	if err != nil {
+		return "", err
+	}
+	if bd.Initrd.Path != "" {
+		d.Initrd, err = imageURL(bd.Initrd.Path)
+		if err != nil {
+			return "", err
 		}
-		script += "boot || goto boot_retry\n:boot_retry\n"
-		// We could vary the length of the sleep based on retry count or some
-		// other criteria.
-		// For now, just sleep a bit
-		script += fmt.Sprintf("sleep %d\n", retryDelay) + chain + "\n"
 	}
-	return script, err
+	return rdr.boot(d), nil
 }
 
 // Function unknownBootScript() constructs the boot script for an unknown host
 // or unknown MAC address.  This is done based on the system architecture.  If
 // the architecture is unknown, the returned script is simply a chained request
 // which will allow the requesting node to return the architecture.
-func unknownBootScript(arch, mac, name string, nid int, ts int64, role string, subRole string, descr string) (string, bool, error) {
+func unknownBootScript(rdr bootRenderer, format, arch, mac, name string, nid int, ts int64, role string, subRole string, descr string) (string, bool, error) {
 	debugf("unknownBootScript(%s)", arch)
 	var script string
 	var err error
-	chain := "chain " + chainProto + "://" + ipxeServer + gwURI + "/boot/v1/bootscript"
+	chain := chainProto + "://" + ipxeServer + gwURI + "/boot/v1/bootscript"
 	if mac != "" {
 		chain += "?mac=" + mac
 	} else if name != "" {
@@ -662,14 +668,16 @@ func unknownBootScript(arch, mac, name string, nid int, ts int64, role string, s
 	} else if nid >= 0 {
 		chain += fmt.Sprintf("?nid=%d", nid)
 	} else {
-		chain += "?mac=${net/net0}" // FIXME: What should this be????
+		chain += "?mac=" + rdr.macVar() // FIXME: What should this be????
+	}
+	chain += fmt.Sprintf("&arch=%s&ts=%d", rdr.archVar(), ts)
+	if format != "" {
+		chain += "&format=" + format
 	}
-	chain += fmt.Sprintf("&arch=${buildarch}&ts=%d", ts)
 	debugf("ts: %d, smTimeStamp: %d", ts, smTimeStamp)
 	retrievingState := checkState(arch == "")
 	if retrievingState {
 		// Either request the architecture or delay for HSM retrieval
-		script = "#!ipxe\n"
 		if retrievingState {
 			// Our state was out of date and is in the process of being updated.
 			// In order to prevent iPXE from the requester timing out, we will
@@ -678,17 +686,19 @@ func unknownBootScript(arch, mac, name string, nid int, ts int64, role string, s
 			// data.  If retrieving state takes longer than our delay, when the
 			// next request comes in, it will wait for the lock to clear, at
 			// which point the updated state will be there.
-			script += fmt.Sprintf("sleep %d\n", hsmRetrievalDelay)
+			script = rdr.chain(bootScriptData{Mac: mac, Xname: name, Role: role, SubRole: subRole,
+				Arch: rdr.archVar(), ChainURL: chain, Delay: hsmRetrievalDelay})
 		} else if ukeys, e := unknownKeys(); e != nil || len(ukeys) == 0 {
 			err = fmt.Errorf("%s: no configuration available for unknown hosts", descr)
 			log.Printf("%s: no configuration available for unknown hosts", descr)
 		} else {
 			log.Printf("%s: requesting architecture of unknown host", descr)
+			script = rdr.chain(bootScriptData{Mac: mac, Xname: name, Role: role, SubRole: subRole,
+				Arch: rdr.archVar(), ChainURL: chain})
 		}
-		script += chain + "\n"
 	} else {
 		bd := lookup(unknownPrefix+arch, "", "", "")
-		script, err = buildBootScript(bd, scriptParams{}, chain, role, subRole, descr)
+		script, err = buildBootScript(bd, scriptParams{mac: mac}, rdr, chain, role, subRole, descr)
 	}
 	return script, retrievingState, err
 }
@@ -727,6 +737,7 @@ func BootscriptGet(w http.ResponseWriter, r *http.Request) {
 	mac := strings.Join(r.Form["mac"], "")
 	name := strings.Join(r.Form["name"], "")
 	arch := strings.Join(r.Form["arch"], "")
+	format := strings.Join(r.Form["format"], "")
 
 	tmp_nid, _ := getIntParam(r, "nid", -1)
 	tmp_retry, _ := getIntParam(r, "retry", 0)
@@ -763,17 +774,41 @@ func BootscriptGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Entries with architecture variants need the architecture of the node
+	bootArch := nodeArch(arch, comp)
+	bd = selectArch(bd, bootArch)
+
+	own := bd
+	override, overridden := activeOverride(comp.ID, comp.Role)
+	if overridden {
+		bd = applyOverride(bd, override)
+		descr += fmt.Sprintf(" with override %s", override.Name)
+	}
+
 	debugf("bd: %v\n", bd)
 	debugf("comp: %v\n", comp)
 
+	rdr, err := selectRenderer(format, comp.Role, comp.SubRole)
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, err.Error())
+		log.Printf("BSS request failed for %s: %s", descr, err.Error())
+		return
+	}
+	if format != "" {
+		// Keep asking for the requested format when chaining back to us
+		format = rdr.name()
+	}
+	rdr = templateFor(rdr, comp.ID, comp.Role)
+
 	var script string
-	var err error
 
 	// Check if this is a node in the discovery process.  We assume this if the
 	// node is not yet known, or if the node is not configured for booting.  In
 	// either of these cases, we want to boot the discovery kernel.
-	unknown := comp.ID == "" || !comp.EndpointEnabled || bd.Kernel.Path == ""
+	unknown := comp.ID == "" || !comp.EndpointEnabled || (bd.Kernel.Path == "" && len(bd.ArchVariants) == 0)
 	retreivingState := false
+	event := bssTypes.BootEvent{Xname: comp.ID, Arch: bootArch, Retry: retry, IP: findRemoteAddr(r)}
+	bootSession := false
 	if unknown {
 		debugf("Unknown: comp: %v", comp)
 		if name == "" {
@@ -798,9 +833,11 @@ func BootscriptGet(w http.ResponseWriter, r *http.Request) {
 		if arch != "" {
 			descr += " architecture " + arch
 		}
-		script, retreivingState, err = unknownBootScript(arch, mac, name, nid, ts, comp.Role, comp.SubRole, descr)
+		script, retreivingState, err = unknownBootScript(rdr, format, arch, mac, name, nid, ts, comp.Role, comp.SubRole, descr)
 		if err != nil {
 			debugf("unknownBootScript returned error: %s", err.Error())
+		} else {
+			event.Type = bssTypes.EventDiscovery
 		}
 	}
 	if !unknown || (unknown && err != nil && comp.ID != "") {
@@ -809,33 +846,67 @@ func BootscriptGet(w http.ResponseWriter, r *http.Request) {
 		// a known component, we will then attempt to provide a non-discovery
 		// bootscript.
 		err = blacklist(comp)
-		if err == nil {
+		if err != nil {
+			event.Type = bssTypes.EventBlacklisted
+		} else {
+			bootSession = true
 			if mac == "" && comp.Mac != nil {
 				mac = comp.Mac[0]
 			}
-			sp := scriptParams{comp.ID, comp.NID.String(), bd.ReferralToken}
-			chain := "chain " + chainProto + "://" + ipxeServer + gwURI + r.URL.Path
+			sp := scriptParams{comp.ID, comp.NID.String(), bd.ReferralToken, mac}
+			chain := chainProto + "://" + ipxeServer + gwURI + r.URL.Path
 			if mac != "" {
 				chain += "?mac=" + mac
 			} else {
 				chain += "?name=" + comp.ID
 			}
 			chain += fmt.Sprintf("&retry=%d", retry+1)
+			if format != "" {
+				chain += "&format=" + format
+			}
+			if bootArch != "" {
+				chain += "&arch=" + bootArch
+			}
 			retreivingState = checkState(false)
-			if retreivingState {
+			if len(bd.ArchVariants) > 0 && !retreivingState {
+				log.Printf("%s: requesting architecture for its boot variant", descr)
+				script = rdr.chain(bootScriptData{Xname: comp.ID, Nid: comp.NID.String(), Mac: mac,
+					Role: comp.Role, SubRole: comp.SubRole, Arch: rdr.archVar(),
+					ChainURL: chain + "&arch=" + rdr.archVar()})
+			} else if retreivingState {
 				// We want to respond with a delayed chain response so that the
 				// node will retry in a bit after we have updated our state info
-				script = "#!ipxe\nsleep 10\n" + chain + "\n"
+				script = rdr.chain(bootScriptData{Xname: comp.ID, Nid: comp.NID.String(), Mac: mac,
+					Role: comp.Role, SubRole: comp.SubRole, Arch: rdr.archVar(), ChainURL: chain, Delay: 10})
 			} else {
-				script, err = buildBootScript(bd, sp, chain, comp.Role, comp.SubRole, descr)
+				if overridden && override.OneShot && !consumeOverride(override.Name, comp.ID) {
+					// Another request used the override first
+					bd = own
+				}
+				script, err = buildBootScript(bd, sp, rdr, chain, comp.Role, comp.SubRole, descr)
+				event.Type = bssTypes.EventBootscript
+				event.Kernel, event.Initrd = bd.Kernel.Path, bd.Initrd.Path
 			}
 		}
 	}
 	if err == nil {
-		w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+		w.Header().Set("Content-Type", rdr.contentType())
 		w.WriteHeader(http.StatusOK)
 		_, err = fmt.Fprintf(w, "%s\n", script)
 		if err == nil {
+			if event.Xname == "" {
+				event.Xname = name
+			}
+			event.MAC = mac
+			if retreivingState {
+				event.Type = bssTypes.EventDelayed
+			}
+			if event.Type != "" {
+				publishNodeEvent(event, comp)
+			}
+			if bootSession {
+				bootSessionRequested(comp.ID, bd.ReferralToken, retry, event.Kernel, event.Initrd)
+			}
 			if retreivingState {
 				log.Printf("BSS request delayed for %s while updating state", descr)
 			} else {
@@ -849,6 +920,10 @@ func BootscriptGet(w http.ResponseWriter, r *http.Request) {
 		}
 	} else {
 		base.SendProblemDetailsGeneric(w, http.StatusNotFound, err.Error())
+		if event.Type == bssTypes.EventBlacklisted {
+			event.MAC, event.Message = mac, err.Error()
+			publishNodeEvent(event, comp)
+		}
 		if strings.HasPrefix(err.Error(), descr) {
 			log.Printf("BSS request failed: %s", err.Error())
 		} else {
@@ -928,12 +1003,8 @@ func HostsPost(w http.ResponseWriter, r *http.Request) {
 }
 
 func DumpstateGet(w http.ResponseWriter, r *http.Request) {
-	type State struct {
-		Components []SMComponent         `json:"Components"`
-		Params     []bssTypes.BootParams `json:"Params"`
-	}
 	debugf("DumpstateGet(): Received request %v\n", r.URL)
-	var results State
+	var results bssTypes.DumpState
 	state := getState()
 	results.Components = state.Components
 	for _, image := range GetKernelInfo() {
@@ -963,6 +1034,8 @@ func DumpstateGet(w http.ResponseWriter, r *http.Request) {
 				bp.Params = bd.Params
 				bp.Kernel = bd.Kernel.Path
 				bp.Initrd = bd.Initrd.Path
+				bp.Profile = bd.Profile
+				bp.ArchVariants = archVariantsAPI(bd.ArchVariants)
 				results.Params = append(results.Params, bp)
 			}
 		}
@@ -0,0 +1,110 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+/*
+ * ETags of boot parameter entries
+ *
+ * The ETag of an entry is a hash of its value in the KV store, so it changes
+ * whenever the entry does, including a new referral token.  GET returns the
+ * ETag of each host entry, and PUT, PATCH and DELETE fail with 412 if the
+ * entries they would change no longer match the request's If-Match header.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+func entryETag(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// The ETag of a host's own boot parameters entry, or "" if it has none.
+func paramsETag(host string) string {
+	val, exists, err := kvstore.Get(paramsPfx + host)
+	if err != nil || !exists {
+		return ""
+	}
+	return entryETag(val)
+}
+
+// The ETags of an If-Match header, or nil if there are none.  Weak ETags are
+// compared as if they were strong.
+func ifMatchETags(r *http.Request) []string {
+	var ret []string
+	for _, h := range r.Header.Values("If-Match") {
+		for _, t := range strings.Split(h, ",") {
+			t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+			if t != "" {
+				ret = append(ret, t)
+			}
+		}
+	}
+	return ret
+}
+
+// Check an entry against If-Match ETags.  "*" matches any entry which exists.
+func etagMatches(ifMatch []string, value string, exists bool) bool {
+	if !exists {
+		return false
+	}
+	etag := entryETag(value)
+	for _, t := range ifMatch {
+		if t == "*" || t == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Check the entries of a request against If-Match ETags, and guard their
+// writes with the values checked.  The key of the first entry which does not
+// match is returned with errConflict.
+func guardTargets(targets []paramTarget, ifMatch []string) (string, error) {
+	if len(ifMatch) == 0 {
+		return "", nil
+	}
+	for i := range targets {
+		op := &targets[i].op
+		val, exists, err := kvstore.Get(op.key)
+		if err != nil {
+			return op.key, err
+		}
+		if !etagMatches(ifMatch, val, exists) {
+			return op.key, errConflict
+		}
+		op.guarded, op.prev, op.prevExists = true, val, exists
+	}
+	return "", nil
+}
+
+// Set the ETag header of a response for a single entry.
+func setETag(w http.ResponseWriter, etag string) {
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+}
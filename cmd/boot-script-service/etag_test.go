@@ -0,0 +1,113 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+	hmetcd "github.com/Cray-HPE/hms-hmetcd"
+)
+
+func TestETagIfMatch(t *testing.T) {
+	send := func(method, query, ifMatch, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, testBaseURL+"/bootparameters"+query, bytes.NewBufferString(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(bootParameters).ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := send(http.MethodPut, "", "", `{"hosts":["x8c3s0b0n0"],"params":"quiet"}`)
+	defer deleteHost("x8c3s0b0n0")
+	etag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || etag == "" {
+		t.Fatalf("PUT returned %d with ETag %q", rr.Code, etag)
+	}
+
+	rr = send(http.MethodGet, "?name=x8c3s0b0n0", "", "")
+	var bps []bssTypes.BootParams
+	json.Unmarshal(rr.Body.Bytes(), &bps)
+	if rr.Header().Get("ETag") != etag || len(bps) != 1 || bps[0].ETag != etag {
+		t.Fatalf("GET returned ETag %q and %+v, expected %s", rr.Header().Get("ETag"), bps, etag)
+	}
+
+	rr = send(http.MethodPatch, "", `W/"0000", `+etag, `{"hosts":["x8c3s0b0n0"],"params":"debug"}`)
+	newETag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || newETag == "" || newETag == etag {
+		t.Fatalf("PATCH with a matching ETag returned %d with ETag %q", rr.Code, newETag)
+	}
+
+	// The old ETag no longer matches
+	rr = send(http.MethodPatch, "", etag, `{"hosts":["x8c3s0b0n0"],"params":"quiet"}`)
+	var res bssTypes.HostResults
+	json.Unmarshal(rr.Body.Bytes(), &res)
+	if rr.Code != http.StatusPreconditionFailed || len(res.Results) != 1 ||
+		res.Results[0].Status != bssTypes.HostPreconditionFailed {
+		t.Errorf("PATCH with a stale ETag returned %d: %s", rr.Code, rr.Body)
+	}
+	if rr = send(http.MethodDelete, "", etag, `{"hosts":["x8c3s0b0n0"]}`); rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("DELETE with a stale ETag returned %d", rr.Code)
+	}
+	if bds, _ := lookupHost("x8c3s0b0n0"); bds.Params != "debug" {
+		t.Errorf("Entry was changed by requests with a stale ETag: %s", bds.Params)
+	}
+
+	// An entry which does not exist never matches
+	if rr = send(http.MethodPut, "", "*", `{"hosts":["x8c3s1b0n0"],"params":"quiet"}`); rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("PUT of a new entry with If-Match returned %d", rr.Code)
+		deleteHost("x8c3s1b0n0")
+	}
+	if rr = send(http.MethodDelete, "", "*", `{"hosts":["x8c3s0b0n0"]}`); rr.Code != http.StatusOK {
+		t.Errorf("DELETE with If-Match * returned %d", rr.Code)
+	}
+}
+
+func TestGuardedBatch(t *testing.T) {
+	storeData(paramsPfx+"x8c4s0b0n0", BootDataStore{Params: "quiet"})
+	defer deleteHost("x8c4s0b0n0")
+	for i, kv := range []hmetcd.Kvi{kvstore, &failingTxnKvi{failingKvi: failingKvi{Kvi: kvstore}}} {
+		restore := withKvstore(kv)
+		targets := []paramTarget{{"x8c4s0b0n0", bssTypes.HostUpdated, storeOp(paramsPfx+"x8c4s0b0n0", BootDataStore{Params: "debug"})}}
+		if _, err := guardTargets(targets, []string{"*"}); err != nil {
+			t.Fatalf("guardTargets failed: %v", err)
+		}
+		// Another request changes the entry after it was checked
+		other := fmt.Sprintf("other=%d", i)
+		storeData(paramsPfx+"x8c4s0b0n0", BootDataStore{Params: other})
+		if _, err := applyBatch([]kvOp{targets[0].op}); err != errConflict {
+			t.Errorf("Guarded batch returned %v, expected a conflict", err)
+		}
+		restore()
+		if bds, _ := lookupHost("x8c4s0b0n0"); bds.Params != other {
+			t.Errorf("Guarded batch overwrote a newer entry: %s", bds.Params)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+/*
+ * Boot activity events
+ *
+ * BSS publishes an event when a node is served its boot script or the
+ * discovery kernel, is told to retry while BSS refreshes its state, is
+ * refused because of its role, fetches its cloud-init meta-data or user-data,
+ * or phones home.
+ *
+ * Events are streamed as Server-Sent Events by /boot/v1/events, and POSTed as
+ * JSON to each webhook of BSS_EVENT_WEBHOOKS.  Both can be filtered by xname,
+ * role and event type: the stream with xname=, role= and type= parameters,
+ * and a webhook with the same parameters in the fragment of its URL, e.g.
+ *
+ *   http://tool/hook#type=phone-home,delayed&role=Compute
+ *
+ * A subscriber which doesn't keep up loses events rather than slowing down
+ * boots.  The most recent events are kept so that a stream which reconnects
+ * with Last-Event-ID gets those it missed.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+var (
+	eventBuffer       = 256 // Events queued for each subscriber
+	eventKeepalive    = 30 * time.Second
+	eventWebhooks     []string
+	eventWebhookRetry = 2 * time.Second
+)
+
+var eventTypes = []string{bssTypes.EventBootscript, bssTypes.EventDiscovery, bssTypes.EventDelayed,
+	bssTypes.EventBlacklisted, bssTypes.EventMetaData, bssTypes.EventUserData, bssTypes.EventPhoneHome}
+
+// Which events a subscriber wants.  An empty set matches anything.
+type eventFilter struct {
+	xnames map[string]bool
+	roles  map[string]bool
+	types  map[string]bool
+}
+
+func filterSet(values []string, normalize func(string) string) map[string]bool {
+	var set map[string]bool
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				if set == nil {
+					set = map[string]bool{}
+				}
+				set[normalize(s)] = true
+			}
+		}
+	}
+	return set
+}
+
+// The filter of xname=, role= and type= parameters, which may be repeated or
+// have comma separated lists.
+func parseEventFilter(q url.Values) (eventFilter, error) {
+	f := eventFilter{
+		xnames: filterSet(q["xname"], base.NormalizeHMSCompID),
+		roles:  filterSet(q["role"], strings.ToLower),
+		types:  filterSet(q["type"], strings.ToLower),
+	}
+	for t := range f.types {
+		known := false
+		for _, et := range eventTypes {
+			known = known || t == et
+		}
+		if !known {
+			return f, fmt.Errorf("Unknown event type %s, expected one of %s", t, strings.Join(eventTypes, ", "))
+		}
+	}
+	return f, nil
+}
+
+func (f eventFilter) match(e bssTypes.BootEvent) bool {
+	return (f.xnames == nil || f.xnames[e.Xname]) &&
+		(f.roles == nil || f.roles[strings.ToLower(e.Role)]) &&
+		(f.types == nil || f.types[e.Type])
+}
+
+type eventSubscriber struct {
+	filter eventFilter
+	ch     chan bssTypes.BootEvent
+}
+
+type eventBus struct {
+	mu     sync.Mutex
+	nextID uint64
+	subs   map[*eventSubscriber]bool
+	recent []bssTypes.BootEvent // Ring of the last events
+	next   int                  // Where the next event goes in recent
+}
+
+func newEventBus(keep int) *eventBus {
+	return &eventBus{nextID: 1, subs: map[*eventSubscriber]bool{}, recent: make([]bssTypes.BootEvent, 0, keep)}
+}
+
+var events = newEventBus(1024)
+
+// Publish an event to the subscribers which want it, filling in its ID and
+// time.
+func (b *eventBus) publish(e bssTypes.BootEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e.ID = b.nextID
+	b.nextID++
+	if e.Time == "" {
+		e.Time = time.Now().Format(time.RFC3339)
+	}
+	if len(b.recent) < cap(b.recent) {
+		b.recent = append(b.recent, e)
+	} else if cap(b.recent) > 0 {
+		b.recent[b.next] = e
+		b.next = (b.next + 1) % cap(b.recent)
+	}
+	for s := range b.subs {
+		if !s.filter.match(e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			// The subscriber isn't keeping up
+		}
+	}
+}
+
+// Subscribe to the events matching a filter.  The events after lastID which
+// are still kept are queued first.
+func (b *eventBus) subscribe(f eventFilter, lastID uint64) *eventSubscriber {
+	s := &eventSubscriber{filter: f, ch: make(chan bssTypes.BootEvent, eventBuffer)}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if lastID > 0 {
+		n := len(b.recent)
+		for i := 0; i < n; i++ {
+			e := b.recent[(b.next+i)%n]
+			if e.ID > lastID && f.match(e) && len(s.ch) < cap(s.ch) {
+				s.ch <- e
+			}
+		}
+	}
+	b.subs[s] = true
+	return s
+}
+
+// Stop sending events to a subscriber, closing its channel.
+func (b *eventBus) unsubscribe(s *eventSubscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs[s] {
+		delete(b.subs, s)
+		close(s.ch)
+	}
+}
+
+// Unsubscribe everything, which ends the event streams so that the server
+// can shut down.
+func (b *eventBus) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		delete(b.subs, s)
+		close(s.ch)
+	}
+}
+
+func (b *eventBus) subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// Publish an event of a node, filling in what is known of the node.
+func publishNodeEvent(e bssTypes.BootEvent, comp SMComponent) {
+	if e.Xname == "" {
+		e.Xname = comp.ID
+	}
+	if e.Xname != "" && comp.ID == "" {
+		comp, _ = FindSMCompByName(e.Xname)
+	}
+	if e.Role == "" {
+		e.Role, e.SubRole = comp.Role, comp.SubRole
+	}
+	if e.NID == "" && comp.NID.String() != "" {
+		e.NID = comp.NID.String()
+	}
+	events.publish(e)
+}
+
+// Stream events as Server-Sent Events until the client goes away.
+func eventsGetAPI(w http.ResponseWriter, r *http.Request) {
+	debugf("eventsGetAPI(): Received request %v\n", r.URL)
+	f, err := parseEventFilter(r.URL.Query())
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+	lastID, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+	sub := events.subscribe(f, lastID)
+	defer events.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	keepalive := time.NewTicker(eventKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprintf(w, ": keepalive\n\n")
+		case e, ok := <-sub.ch:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(e)
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// A webhook which events are POSTed to.
+type webhook struct {
+	url    string
+	sub    *eventSubscriber
+	client *http.Client
+}
+
+// Parse a webhook, with its filter in the fragment of the URL.
+func newWebhook(spec string) (*webhook, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%s is not an http or https URL", spec)
+	}
+	q, err := url.ParseQuery(u.Fragment)
+	if err != nil {
+		return nil, fmt.Errorf("Bad filter in %s: %s", spec, err)
+	}
+	f, err := parseEventFilter(q)
+	if err != nil {
+		return nil, err
+	}
+	u.Fragment = ""
+	return &webhook{
+		url:    u.String(),
+		sub:    events.subscribe(f, 0),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (wh *webhook) post(e bssTypes.BootEvent) error {
+	data, _ := json.Marshal(e)
+	req, err := http.NewRequest(http.MethodPost, wh.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	base.SetHTTPUserAgent(req, serviceName)
+	rsp, err := wh.client.Do(req)
+	if err != nil {
+		return err
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode >= 300 {
+		return fmt.Errorf("POST %s returned %s", wh.url, rsp.Status)
+	}
+	return nil
+}
+
+// POST each event to the webhook, trying once more if it fails, until the
+// webhook is unsubscribed.
+func (wh *webhook) run() {
+	for e := range wh.sub.ch {
+		err := wh.post(e)
+		if err != nil {
+			time.Sleep(eventWebhookRetry)
+			err = wh.post(e)
+		}
+		if err != nil {
+			log.Printf("WARNING: Event %d not sent to webhook: %s", e.ID, err)
+		}
+	}
+}
+
+// Start the webhooks of BSS_EVENT_WEBHOOKS.
+func startWebhooks(specs []string) error {
+	for _, spec := range specs {
+		wh, err := newWebhook(spec)
+		if err != nil {
+			return err
+		}
+		log.Printf("Sending events to %s", wh.url)
+		go wh.run()
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+func nextEvent(t *testing.T, ch chan bssTypes.BootEvent) bssTypes.BootEvent {
+	t.Helper()
+	select {
+	case e := <-ch:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for an event")
+	}
+	return bssTypes.BootEvent{}
+}
+
+func TestEventFilter(t *testing.T) {
+	q, _ := url.ParseQuery("xname=x0c0s01b0n0,x0c0s2b0n0&role=compute&type=phone-home&type=Delayed")
+	f, err := parseEventFilter(q)
+	if err != nil {
+		t.Fatalf("parseEventFilter() failed: %v", err)
+	}
+	tests := []struct {
+		e    bssTypes.BootEvent
+		want bool
+	}{
+		{bssTypes.BootEvent{Type: bssTypes.EventPhoneHome, Xname: "x0c0s1b0n0", Role: "Compute"}, true},
+		{bssTypes.BootEvent{Type: bssTypes.EventDelayed, Xname: "x0c0s2b0n0", Role: "Compute"}, true},
+		{bssTypes.BootEvent{Type: bssTypes.EventBootscript, Xname: "x0c0s2b0n0", Role: "Compute"}, false},
+		{bssTypes.BootEvent{Type: bssTypes.EventPhoneHome, Xname: "x0c0s3b0n0", Role: "Compute"}, false},
+		{bssTypes.BootEvent{Type: bssTypes.EventPhoneHome, Xname: "x0c0s1b0n0", Role: "Management"}, false},
+	}
+	for _, tt := range tests {
+		if got := f.match(tt.e); got != tt.want {
+			t.Errorf("match(%+v) = %v, expected %v", tt.e, got, tt.want)
+		}
+	}
+	if !(eventFilter{}).match(tests[2].e) {
+		t.Errorf("An empty filter should match anything")
+	}
+	if _, err := parseEventFilter(url.Values{"type": {"booted"}}); err == nil {
+		t.Errorf("parseEventFilter() accepted an unknown type")
+	}
+}
+
+func TestEventBus(t *testing.T) {
+	b := newEventBus(3)
+	for i := 0; i < 5; i++ {
+		b.publish(bssTypes.BootEvent{Type: bssTypes.EventUserData, Xname: "x0c0s1b0n0"})
+	}
+	sub := b.subscribe(eventFilter{}, 1)
+	if len(sub.ch) != 3 {
+		t.Fatalf("%d events replayed, expected the 3 kept", len(sub.ch))
+	}
+	for _, id := range []uint64{3, 4, 5} {
+		if e := <-sub.ch; e.ID != id || e.Time == "" {
+			t.Errorf("Replayed event %+v, expected ID %d", e, id)
+		}
+	}
+
+	saved := eventBuffer
+	eventBuffer = 2
+	slow := b.subscribe(eventFilter{types: map[string]bool{bssTypes.EventPhoneHome: true}}, 0)
+	eventBuffer = saved
+	for i := 0; i < 4; i++ {
+		b.publish(bssTypes.BootEvent{Type: bssTypes.EventPhoneHome})
+	}
+	b.publish(bssTypes.BootEvent{Type: bssTypes.EventMetaData})
+	if len(slow.ch) != 2 || len(sub.ch) != 5 {
+		t.Errorf("Subscribers have %d and %d events, expected 2 and 5", len(slow.ch), len(sub.ch))
+	}
+
+	b.unsubscribe(sub)
+	b.close()
+	if b.subscribers() != 0 {
+		t.Errorf("%d subscribers after close", b.subscribers())
+	}
+	for range slow.ch {
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(eventStream))
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	before := events.subscribers()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"?type=phone-home", nil)
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK || rsp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("GET returned %d %s", rsp.StatusCode, rsp.Header.Get("Content-Type"))
+	}
+	for events.subscribers() == before {
+		time.Sleep(10 * time.Millisecond)
+	}
+	events.publish(bssTypes.BootEvent{Type: bssTypes.EventBootscript, Xname: "x0c0s1b0n0"})
+	events.publish(bssTypes.BootEvent{Type: bssTypes.EventPhoneHome, Xname: "x0c0s1b0n0"})
+
+	var lines []string
+	scanner := bufio.NewScanner(rsp.Body)
+	for scanner.Scan() && scanner.Text() != "" {
+		lines = append(lines, scanner.Text())
+	}
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "id: ") || lines[1] != "event: phone-home" {
+		t.Fatalf("Unexpected event %q", lines)
+	}
+	var e bssTypes.BootEvent
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &e); err != nil || e.Xname != "x0c0s1b0n0" {
+		t.Errorf("Unexpected event data %s: %v", lines[2], err)
+	}
+
+	rsp, _ = http.Get(srv.URL + "?type=rebooted")
+	if rsp.StatusCode != http.StatusBadRequest {
+		t.Errorf("GET with an unknown type returned %d", rsp.StatusCode)
+	}
+	rsp.Body.Close()
+}
+
+func TestEventWebhook(t *testing.T) {
+	var mu sync.Mutex
+	var got []bssTypes.BootEvent
+	fails := 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if fails > 0 {
+			fails--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var e bssTypes.BootEvent
+		json.NewDecoder(r.Body).Decode(&e)
+		got = append(got, e)
+	}))
+	defer srv.Close()
+	saved := eventWebhookRetry
+	eventWebhookRetry = 10 * time.Millisecond
+	defer func() { eventWebhookRetry = saved }()
+
+	if _, err := newWebhook("ftp://tool/hook"); err == nil {
+		t.Errorf("newWebhook() accepted an ftp URL")
+	}
+	wh, err := newWebhook(srv.URL + "/hook#xname=x0c0s1b0n0")
+	if err != nil {
+		t.Fatalf("newWebhook() failed: %v", err)
+	}
+	if wh.url != srv.URL+"/hook" {
+		t.Errorf("Webhook URL %s", wh.url)
+	}
+	done := make(chan struct{})
+	go func() {
+		wh.run()
+		close(done)
+	}()
+	events.publish(bssTypes.BootEvent{Type: bssTypes.EventUserData, Xname: "x0c0s2b0n0"})
+	events.publish(bssTypes.BootEvent{Type: bssTypes.EventUserData, Xname: "x0c0s1b0n0"})
+	events.publish(bssTypes.BootEvent{Type: bssTypes.EventPhoneHome, Xname: "x0c0s1b0n0"})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(got)
+		mu.Unlock()
+		if n == 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	events.unsubscribe(wh.sub)
+	<-done
+	if len(got) != 2 || got[0].Type != bssTypes.EventUserData || got[1].Type != bssTypes.EventPhoneHome {
+		t.Errorf("Webhook received %+v", got)
+	}
+}
+
+func TestBootscriptEvents(t *testing.T) {
+	Store(bssTypes.BootParams{Hosts: []string{"x0c0s2b0n0"}, Params: "quiet", Kernel: "http://images/kernel"})
+	defer deleteHost("x0c0s2b0n0")
+	sub := events.subscribe(eventFilter{xnames: map[string]bool{"x0c0s2b0n0": true}}, 0)
+	defer events.unsubscribe(sub)
+
+	rr := serveRequest(t, bootScript, http.MethodGet, "/bootscript?name=x0c0s2b0n0&retry=2", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET bootscript returned %d: %s", rr.Code, rr.Body)
+	}
+	e := nextEvent(t, sub.ch)
+	comp, _ := FindSMCompByName("x0c0s2b0n0")
+	if e.Type != bssTypes.EventBootscript || e.Kernel != "http://images/kernel" || e.Retry != 2 ||
+		e.Role != comp.Role || e.NID != comp.NID.String() {
+		t.Errorf("Unexpected event %+v", e)
+	}
+}
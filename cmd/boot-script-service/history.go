@@ -0,0 +1,357 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+/*
+ * Change history of boot parameters, boot profiles and images
+ *
+ * Each change to an entry appends a revision under /history/<entry key>/,
+ * in the same batch as the change itself, recording who made it, when, the
+ * fields which changed and the entry as it was left.  The first change to an
+ * entry which already existed also records it as it was, as revision 1.
+ * Only the newest BSS_HISTORY_LIMIT revisions of each entry are kept, and
+ * setting it to 0 turns the history off.
+ *
+ * A rollback writes the value of an earlier revision back to the entry, and
+ * is recorded as a revision of its own.
+ *
+ * Each write is guarded by the value its history was worked out from, and
+ * each new revision must not exist yet, so that when two replicas change an
+ * entry at once one of the batches fails and is worked out again, rather
+ * than both writing the same revision.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+const historyPfx = "/history"
+
+var historyLimit = 20
+
+// Attempts at a batch of writes whose history was changed by another request
+const historyRetries = 5
+
+// Who made a change, and the conditions it was made under.
+type changeRequest struct {
+	who      string
+	ifMatch  []string
+	rollback int // Revision restored, for a rollback
+}
+
+// The user a request was made by, from the claims of its bearer token, which
+// the API gateway has already verified.  Requests without a token are known
+// by their address.
+func requestUser(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if strings.HasPrefix(auth, "Bearer ") {
+		parts := strings.Split(strings.TrimPrefix(auth, "Bearer "), ".")
+		var claims struct {
+			PreferredUsername string `json:"preferred_username"`
+			Name              string `json:"name"`
+			Sub               string `json:"sub"`
+		}
+		if len(parts) == 3 {
+			if data, err := base64.RawURLEncoding.DecodeString(parts[1]); err == nil &&
+				json.Unmarshal(data, &claims) == nil {
+				for _, u := range []string{claims.PreferredUsername, claims.Name, claims.Sub} {
+					if u != "" {
+						return u
+					}
+				}
+			}
+		}
+	}
+	return findRemoteAddr(r)
+}
+
+func historyKey(key string, rev int) string {
+	return fmt.Sprintf("%s%s/%010d", historyPfx, key, rev)
+}
+
+// The revisions of an entry, oldest first.
+func getRevisions(key string) ([]bssTypes.Revision, error) {
+	pfx := historyPfx + key + "/"
+	kvl, err := kvstore.GetRange(pfx+keyMin, pfx+keyMax)
+	if err != nil {
+		return nil, err
+	}
+	revs := make([]bssTypes.Revision, 0, len(kvl))
+	for _, x := range kvl {
+		var rev bssTypes.Revision
+		if e := json.Unmarshal([]byte(x.Value), &rev); e != nil {
+			log.Printf("Skipping unreadable revision %s: %s", x.Key, e)
+			continue
+		}
+		revs = append(revs, rev)
+	}
+	sort.Slice(revs, func(i, j int) bool { return revs[i].Revision < revs[j].Revision })
+	return revs, nil
+}
+
+// The top level fields which differ between two JSON objects.
+func jsonDiff(old, new string) []bssTypes.FieldChange {
+	var o, n map[string]json.RawMessage
+	json.Unmarshal([]byte(old), &o)
+	json.Unmarshal([]byte(new), &n)
+	var fields []string
+	for f := range o {
+		fields = append(fields, f)
+	}
+	for f := range n {
+		if _, ok := o[f]; !ok {
+			fields = append(fields, f)
+		}
+	}
+	sort.Strings(fields)
+	var ret []bssTypes.FieldChange
+	for _, f := range fields {
+		if !bytes.Equal(o[f], n[f]) {
+			ret = append(ret, bssTypes.FieldChange{Field: f, Old: o[f], New: n[f]})
+		}
+	}
+	return ret
+}
+
+// Add the history of a batch of writes to it.  The writes are guarded by the
+// values read, and the new revisions by their not existing yet.
+func withHistory(ops []kvOp, cr changeRequest) ([]kvOp, error) {
+	if historyLimit <= 0 {
+		return ops, nil
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	ret := append([]kvOp(nil), ops...)
+	for i, op := range ops {
+		if op.check || op.noHistory || strings.HasPrefix(op.key, historyPfx+"/") {
+			continue
+		}
+		prev, exists, err := kvstore.Get(op.key)
+		if err != nil {
+			return ops, err
+		}
+		if (op.delete && !exists) || (!op.delete && exists && prev == op.value) {
+			continue
+		}
+		if !op.guarded {
+			// Guarded by If-Match already otherwise
+			ret[i] = guardOp(op, prev, 0, exists)
+		}
+		revs, err := getRevisions(op.key)
+		if err != nil {
+			return ops, err
+		}
+		next := 1
+		if len(revs) > 0 {
+			next = revs[len(revs)-1].Revision + 1
+		} else if exists {
+			revs = append(revs, bssTypes.Revision{Revision: 1, Time: now, Action: "existing", Value: json.RawMessage(prev)})
+			ret = append(ret, guardOp(storeOp(historyKey(op.key, 1), revs[0]), "", 0, false))
+			next = 2
+		}
+		rev := bssTypes.Revision{Revision: next, Time: now, Who: cr.who, Rollback: cr.rollback}
+		switch {
+		case cr.rollback > 0:
+			rev.Action = "rollback"
+		case op.delete:
+			rev.Action = "deleted"
+		case !exists:
+			rev.Action = "created"
+		default:
+			rev.Action = "updated"
+		}
+		if op.delete {
+			rev.Diff = jsonDiff(prev, "")
+		} else {
+			rev.Value = json.RawMessage(op.value)
+			rev.Diff = jsonDiff(prev, op.value)
+		}
+		revs = append(revs, rev)
+		ret = append(ret, guardOp(storeOp(historyKey(op.key, next), rev), "", 0, false))
+		for i := 0; i < len(revs)-historyLimit; i++ {
+			ret = append(ret, kvOp{key: historyKey(op.key, revs[i].Revision), delete: true})
+		}
+	}
+	return ret, nil
+}
+
+// Store an entry, recording its history.
+func storeEntry(key string, v interface{}, cr changeRequest) error {
+	return applyEntryOps(key, []kvOp{storeOp(key, v)}, cr)
+}
+
+// Delete an entry, recording its history.
+func deleteEntry(key string, cr changeRequest) error {
+	return applyEntryOps(key, []kvOp{{key: key, delete: true}}, cr)
+}
+
+// Apply a batch of writes with their history, made by batch.  If another
+// request changed one of the entries in the meantime, the batch is made and
+// applied again.  The key which failed is returned along with any error, as
+// for applyBatch().
+func applyWithHistory(batch func() ([]kvOp, error), cr changeRequest) (string, error) {
+	for attempt := 1; ; attempt++ {
+		ops, err := batch()
+		if err == nil {
+			ops, err = withHistory(ops, cr)
+		}
+		if err != nil {
+			return "", err
+		}
+		failed, err := applyBatch(ops)
+		if !errors.Is(err, errConflict) || attempt >= historyRetries {
+			return failed, err
+		}
+		debugf("Retrying a batch of %d writes changed by another request", len(ops))
+	}
+}
+
+func applyEntryOps(key string, ops []kvOp, cr changeRequest) error {
+	_, err := applyWithHistory(func() ([]kvOp, error) { return ops, nil }, cr)
+	if err != nil {
+		msg := fmt.Sprintf("Key %s storage failed: %s", key, err)
+		herr := base.NewHMSError("Storage", msg)
+		herr.AddProblem(base.NewProblemDetailsStatus(msg, http.StatusInternalServerError))
+		err = herr
+	}
+	return err
+}
+
+// The key of the entry a history request is for.
+func historyEntryKey(host, profile, kernel, initrd string) (string, error) {
+	n := 0
+	for _, s := range []string{host, profile, kernel, initrd} {
+		if s != "" {
+			n++
+		}
+	}
+	if n != 1 {
+		return "", fmt.Errorf("Need one of name, profile, kernel or initrd")
+	}
+	imageKey := func(path, imtype string) string {
+		if key := imageFind(path, imtype); key != "" {
+			return key
+		}
+		// Deleted images are found by the key they would be stored under
+		return makeImageKey(imtype, path)
+	}
+	switch {
+	case host != "":
+		return paramsPfx + host, nil
+	case profile != "":
+		return profilesPfx + profile, nil
+	case kernel != "":
+		return imageKey(kernel, kernelImageType), nil
+	}
+	return imageKey(initrd, initrdImageType), nil
+}
+
+// List the revisions of an entry.
+func historyGetAPI(w http.ResponseWriter, r *http.Request) {
+	debugf("historyGetAPI(): Received request %v\n", r.URL)
+	r.ParseForm()
+	key, err := historyEntryKey(r.FormValue("name"), r.FormValue("profile"),
+		r.FormValue("kernel"), r.FormValue("initrd"))
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	revs, err := getRevisions(key)
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+			fmt.Sprintf("Failed to get the history of %s: %s", key, err))
+		return
+	}
+	if len(revs) == 0 {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound, fmt.Sprintf("No history of %s", key))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(revs); err != nil {
+		log.Printf("Yikes, I couldn't encode a JSON status response: %s\n", err)
+	}
+}
+
+// Restore an entry to a prior revision.
+func historyRollbackAPI(w http.ResponseWriter, r *http.Request) {
+	debugf("historyRollbackAPI(): Received request %v\n", r.URL)
+	var rb bssTypes.Rollback
+	if err := json.NewDecoder(r.Body).Decode(&rb); err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, fmt.Sprintf("Bad Request: %s", err))
+		return
+	}
+	key, err := historyEntryKey(rb.Host, rb.Profile, rb.Kernel, rb.Initrd)
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	revs, err := getRevisions(key)
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+			fmt.Sprintf("Failed to get the history of %s: %s", key, err))
+		return
+	}
+	var target *bssTypes.Revision
+	for i := range revs {
+		if revs[i].Revision == rb.Revision {
+			target = &revs[i]
+		}
+	}
+	if target == nil {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound,
+			fmt.Sprintf("%s has no revision %d", key, rb.Revision))
+		return
+	}
+
+	op := kvOp{key: key, value: string(target.Value)}
+	if target.Value == nil {
+		op.delete = true
+	}
+	cr := changeRequest{who: requestUser(r), rollback: rb.Revision}
+	if err = applyEntryOps(key, []kvOp{op}, cr); err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Printf("Rolled %s back to revision %d", key, rb.Revision)
+
+	revs, _ = getRevisions(key)
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if len(revs) > 0 {
+		if err := json.NewEncoder(w).Encode(revs[len(revs)-1]); err != nil {
+			log.Printf("Yikes, I couldn't encode a JSON status response: %s\n", err)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+func getHistory(t *testing.T, query string) []bssTypes.Revision {
+	t.Helper()
+	rr := serveRequest(t, history, http.MethodGet, "/history?"+query, nil)
+	var revs []bssTypes.Revision
+	if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &revs) != nil {
+		t.Fatalf("GET history?%s returned %d: %s", query, rr.Code, rr.Body)
+	}
+	return revs
+}
+
+// Delete the revisions of entries once a test has finished, so that it can
+// run again.
+func cleanupHistory(t *testing.T, keys ...string) {
+	t.Cleanup(func() {
+		for _, key := range keys {
+			pfx := historyPfx + key + "/"
+			kvl, _ := kvstore.GetRange(pfx+keyMin, pfx+keyMax)
+			for _, kv := range kvl {
+				kvstore.Delete(kv.Key)
+			}
+		}
+	})
+}
+
+func TestHistoryRollback(t *testing.T) {
+	cleanupHistory(t, paramsPfx+"x8c5s0b0n0")
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"preferred_username":"admin1"}`))
+	send := func(method, body string) {
+		req, _ := http.NewRequest(method, testBaseURL+"/bootparameters", bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer x."+claims+".y")
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(bootParameters).ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s returned %d: %s", method, rr.Code, rr.Body)
+		}
+	}
+	send(http.MethodPut, `{"hosts":["x8c5s0b0n0"],"params":"quiet"}`)
+	defer deleteHost("x8c5s0b0n0")
+	send(http.MethodPatch, `{"hosts":["x8c5s0b0n0"],"params":"debug"}`)
+	send(http.MethodDelete, `{"hosts":["x8c5s0b0n0"]}`)
+
+	revs := getHistory(t, "name=x8c5s0b0n0")
+	if len(revs) != 3 || revs[0].Action != "created" || revs[1].Action != "updated" || revs[2].Action != "deleted" {
+		t.Fatalf("Unexpected revisions: %+v", revs)
+	}
+	if revs[1].Who != "admin1" || len(revs[1].Diff) != 1 || revs[1].Diff[0].Field != "params" ||
+		string(revs[1].Diff[0].Old) != `"quiet"` || string(revs[1].Diff[0].New) != `"debug"` {
+		t.Errorf("Unexpected update revision: %+v", revs[1])
+	}
+	if revs[2].Value != nil {
+		t.Errorf("Delete revision has a value: %s", revs[2].Value)
+	}
+
+	rr := serveRequest(t, historyRollback, http.MethodPost, "/history/rollback", `{"host":"x8c5s0b0n0","revision":2}`)
+	var rev bssTypes.Revision
+	if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &rev) != nil {
+		t.Fatalf("Rollback returned %d: %s", rr.Code, rr.Body)
+	}
+	if rev.Revision != 4 || rev.Action != "rollback" || rev.Rollback != 2 {
+		t.Errorf("Unexpected rollback revision: %+v", rev)
+	}
+	if bds, err := lookupHost("x8c5s0b0n0"); err != nil || bds.Params != "debug" {
+		t.Errorf("Rollback restored %+v, %v", bds, err)
+	}
+
+	rr = serveRequest(t, historyRollback, http.MethodPost, "/history/rollback", `{"host":"x8c5s0b0n0","revision":9}`)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Rollback to a missing revision returned %d", rr.Code)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	saved := historyLimit
+	historyLimit = 3
+	defer func() { historyLimit = saved }()
+	cleanupHistory(t, paramsPfx+"x8c5s1b0n0")
+
+	// An entry which existed before its history was kept
+	storeData(paramsPfx+"x8c5s1b0n0", BootDataStore{Params: "p0"})
+	defer deleteHost("x8c5s1b0n0")
+	Update(bssTypes.BootParams{Hosts: []string{"x8c5s1b0n0"}, Params: "p1"})
+	revs := getHistory(t, "name=x8c5s1b0n0")
+	if len(revs) != 2 || revs[0].Action != "existing" || revs[1].Revision != 2 {
+		t.Fatalf("Unexpected revisions: %+v", revs)
+	}
+
+	for _, p := range []string{"p2", "p3", "p4"} {
+		Update(bssTypes.BootParams{Hosts: []string{"x8c5s1b0n0"}, Params: p})
+	}
+	revs = getHistory(t, "name=x8c5s1b0n0")
+	if len(revs) != 3 || revs[0].Revision != 3 || revs[2].Revision != 5 {
+		t.Errorf("Expected revisions 3 to 5, got %+v", revs)
+	}
+}
+
+func TestHistoryImagesProfiles(t *testing.T) {
+	kernel := "s3://boot-images/history/kernel"
+	cleanupHistory(t, makeImageKey(kernelImageType, kernel), profilesPfx+"history")
+	Store(bssTypes.BootParams{Kernel: kernel, Params: "k1"})
+	Update(bssTypes.BootParams{Kernel: kernel, Params: "k2"})
+	defer Remove(bssTypes.BootParams{Kernel: kernel})
+	revs := getHistory(t, "kernel="+url.QueryEscape(kernel))
+	if len(revs) != 2 || revs[0].Action != "created" || revs[1].Action != "updated" {
+		t.Errorf("Unexpected image revisions: %+v", revs)
+	}
+
+	storeEntry(profilesPfx+"history", BootDataStore{Params: "a"}, changeRequest{who: "test"})
+	deleteEntry(profilesPfx+"history", changeRequest{who: "test"})
+	revs = getHistory(t, "profile=history")
+	if len(revs) != 2 || revs[1].Action != "deleted" || revs[1].Who != "test" {
+		t.Errorf("Unexpected profile revisions: %+v", revs)
+	}
+
+	rr := serveRequest(t, history, http.MethodGet, "/history?name=a&profile=b", nil)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("GET history of two entries returned %d", rr.Code)
+	}
+}
+
+func TestHistoryConflict(t *testing.T) {
+	key := paramsPfx + "x8c5s2b0n0"
+	cleanupHistory(t, key)
+	defer kvstore.Delete(key)
+
+	// Another request changes the entry after this one read its history
+	ops, err := withHistory([]kvOp{storeOp(key, BootDataStore{Params: "mine"})}, changeRequest{who: "one"})
+	if err != nil {
+		t.Fatalf("withHistory failed: %s", err)
+	}
+	if err = storeEntry(key, BootDataStore{Params: "theirs"}, changeRequest{who: "two"}); err != nil {
+		t.Fatalf("storeEntry failed: %s", err)
+	}
+	if _, err = applyBatch(ops); !errors.Is(err, errConflict) {
+		t.Errorf("Batch with out of date history returned %v, expected a conflict", err)
+	}
+	revs := getHistory(t, "name=x8c5s2b0n0")
+	if len(revs) != 1 || revs[0].Who != "two" {
+		t.Errorf("Unexpected revisions: %+v", revs)
+	}
+
+	if err = storeEntry(key, BootDataStore{Params: "mine"}, changeRequest{who: "one"}); err != nil {
+		t.Fatalf("storeEntry failed: %s", err)
+	}
+	revs = getHistory(t, "name=x8c5s2b0n0")
+	if len(revs) != 2 || revs[1].Revision != 2 || revs[1].Who != "one" {
+		t.Errorf("Unexpected revisions: %+v", revs)
+	}
+}
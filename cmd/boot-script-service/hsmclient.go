@@ -0,0 +1,217 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+/*
+ * HSM client
+ *
+ * Every request to HSM has a timeout, and requests which fail with a
+ * network error, a timeout or a 5xx or 429 status are retried with
+ * exponential backoff.  Other statuses, and responses which can't be
+ * decoded, fail at once.  A fetch of the HSM state fails as a whole if any of
+ * its requests do, and the state fetched before is kept.
+ *
+ * After BSS_HSM_BREAKER_THRESHOLD requests in a row have failed, the circuit
+ * breaker opens and requests fail without being made for
+ * BSS_HSM_BREAKER_COOLDOWN seconds.  The next request is then tried, and the
+ * breaker closes if it succeeds or opens again if it fails.
+ *
+ * The counters of the client are in /boot/v1/service/hsm.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+var (
+	hsmTimeout          = 10 // Seconds per request
+	hsmRetries          = 3
+	hsmBreakerThreshold = 5
+	hsmBreakerCooldown  = 30 // Seconds
+)
+
+var errHSMBreakerOpen = errors.New("HSM circuit breaker is open")
+
+// An HSM request which failed with an unexpected status.
+type hsmStatusError struct {
+	url    string
+	status int
+}
+
+func (e hsmStatusError) Error() string {
+	return fmt.Sprintf("GET %s returned %d %s", e.url, e.status, http.StatusText(e.status))
+}
+
+type hsmClient struct {
+	client     *http.Client
+	baseURL    string
+	timeout    time.Duration
+	retries    int
+	backoff    time.Duration // Before the first retry, doubled for each
+	maxBackoff time.Duration
+	threshold  int
+	cooldown   time.Duration
+
+	mu        sync.Mutex
+	failures  int // In a row
+	openUntil time.Time
+	stats     bssTypes.HSMClientStats
+}
+
+func newHSMClient(client *http.Client, baseURL string) *hsmClient {
+	return &hsmClient{
+		client:     client,
+		baseURL:    baseURL,
+		timeout:    time.Duration(hsmTimeout) * time.Second,
+		retries:    hsmRetries,
+		backoff:    500 * time.Millisecond,
+		maxBackoff: 8 * time.Second,
+		threshold:  hsmBreakerThreshold,
+		cooldown:   time.Duration(hsmBreakerCooldown) * time.Second,
+	}
+}
+
+// The counters of the client.
+func (c *hsmClient) Stats() bssTypes.HSMClientStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := c.stats
+	switch {
+	case time.Now().Before(c.openUntil):
+		st.BreakerState = bssTypes.BreakerOpen
+	case c.threshold > 0 && c.failures >= c.threshold:
+		st.BreakerState = bssTypes.BreakerHalfOpen
+	default:
+		st.BreakerState = bssTypes.BreakerClosed
+	}
+	return st
+}
+
+func (c *hsmClient) count(counter *int64) {
+	c.mu.Lock()
+	*counter++
+	c.mu.Unlock()
+}
+
+// Fail at once if the circuit breaker is open.
+func (c *hsmClient) allow() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Now().Before(c.openUntil) {
+		c.stats.Rejected++
+		return errHSMBreakerOpen
+	}
+	return nil
+}
+
+// Record the result of a request, after any retries.
+func (c *hsmClient) result(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		c.failures = 0
+		c.openUntil = time.Time{}
+		return
+	}
+	c.failures++
+	c.stats.Failures++
+	c.stats.LastError = err.Error()
+	c.stats.LastErrorTime = time.Now().UTC().Format(time.RFC3339)
+	if c.threshold > 0 && c.failures >= c.threshold {
+		c.openUntil = time.Now().Add(c.cooldown)
+		c.stats.BreakerOpened++
+	}
+}
+
+// GET a path of the HSM API and decode the JSON response into each of vs.
+// The vs may have been partly filled in if an error is returned.
+func (c *hsmClient) getJSON(path string, vs ...interface{}) error {
+	if err := c.allow(); err != nil {
+		return err
+	}
+	delay := c.backoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		retry, err = c.get(c.baseURL+path, vs)
+		if err == nil || !retry || attempt >= c.retries {
+			break
+		}
+		c.count(&c.stats.Retries)
+		debugf("Retrying HSM request in %s: %s", delay, err)
+		time.Sleep(delay)
+		if delay *= 2; delay > c.maxBackoff {
+			delay = c.maxBackoff
+		}
+	}
+	c.result(err)
+	return err
+}
+
+// Make one request, returning whether it may be retried if it failed.
+func (c *hsmClient) get(url string, vs []interface{}) (bool, error) {
+	c.count(&c.stats.Requests)
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Close = true
+	base.SetHTTPUserAgent(req, serviceName)
+	rsp, err := c.client.Do(req)
+	if err == nil {
+		defer rsp.Body.Close()
+		if rsp.StatusCode != http.StatusOK {
+			c.count(&c.stats.StatusErrors)
+			retry := rsp.StatusCode >= 500 || rsp.StatusCode == http.StatusTooManyRequests
+			return retry, hsmStatusError{url, rsp.StatusCode}
+		}
+		var body []byte
+		if body, err = ioutil.ReadAll(rsp.Body); err == nil {
+			for _, v := range vs {
+				if err = json.Unmarshal(body, v); err != nil {
+					c.count(&c.stats.DecodeErrors)
+					return false, fmt.Errorf("GET %s returned bad data: %s", url, err)
+				}
+			}
+			return false, nil
+		}
+	}
+	var nerr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &nerr) && nerr.Timeout()) {
+		c.count(&c.stats.Timeouts)
+	}
+	return true, fmt.Errorf("GET %s failed: %s", url, err)
+}
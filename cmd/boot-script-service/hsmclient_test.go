@@ -0,0 +1,170 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+// A client of a test server which retries quickly.
+func testHSMClient(srv *httptest.Server) *hsmClient {
+	c := newHSMClient(srv.Client(), srv.URL)
+	c.backoff = time.Millisecond
+	c.maxBackoff = 4 * time.Millisecond
+	return c
+}
+
+// A test server which responds with each status in turn, then 200.
+func statusServer(t *testing.T, statuses ...int) (*httptest.Server, *int32) {
+	var n int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := atomic.AddInt32(&n, 1) - 1
+		if int(i) < len(statuses) {
+			w.WriteHeader(statuses[i])
+			return
+		}
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &n
+}
+
+func TestHSMClientRetry(t *testing.T) {
+	srv, n := statusServer(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	c := testHSMClient(srv)
+	var v struct{ OK bool }
+	if err := c.getJSON("/x", &v); err != nil || !v.OK {
+		t.Fatalf("getJSON() = %v, %+v", err, v)
+	}
+	st := c.Stats()
+	if *n != 3 || st.Requests != 3 || st.Retries != 2 || st.StatusErrors != 2 || st.Failures != 0 {
+		t.Errorf("%d requests, stats %+v", *n, st)
+	}
+
+	// Other statuses are not retried
+	srv, n = statusServer(t, http.StatusNotFound)
+	c = testHSMClient(srv)
+	if err := c.getJSON("/x", &v); err == nil {
+		t.Errorf("getJSON() of a 404 succeeded")
+	}
+	if st := c.Stats(); *n != 1 || st.Failures != 1 || st.LastError == "" {
+		t.Errorf("%d requests, stats %+v", *n, st)
+	}
+
+	// Nor are they retried forever
+	srv, n = statusServer(t, 500, 500, 500, 500, 500)
+	c = testHSMClient(srv)
+	if err := c.getJSON("/x", &v); err == nil || *n != int32(c.retries+1) {
+		t.Errorf("getJSON() = %v after %d requests", err, *n)
+	}
+}
+
+func TestHSMClientErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte(`{"ok": `))
+	}))
+	defer srv.Close()
+	c := testHSMClient(srv)
+	c.retries = 0
+	var v struct{ OK bool }
+	if err := c.getJSON("/bad", &v); err == nil {
+		t.Errorf("getJSON() of bad data succeeded")
+	}
+	c.timeout = 20 * time.Millisecond
+	if err := c.getJSON("/slow", &v); err == nil {
+		t.Errorf("getJSON() of a slow response succeeded")
+	}
+	if st := c.Stats(); st.DecodeErrors != 1 || st.Timeouts != 1 || st.Failures != 2 {
+		t.Errorf("Stats %+v", st)
+	}
+}
+
+func TestHSMClientBreaker(t *testing.T) {
+	srv, n := statusServer(t, 500, 500)
+	c := testHSMClient(srv)
+	c.retries, c.threshold, c.cooldown = 0, 2, 50*time.Millisecond
+	var v struct{ OK bool }
+	c.getJSON("/x", &v)
+	if st := c.Stats(); st.BreakerState != bssTypes.BreakerClosed {
+		t.Errorf("Breaker %s after one failure", st.BreakerState)
+	}
+	c.getJSON("/x", &v)
+	if err := c.getJSON("/x", &v); err != errHSMBreakerOpen || *n != 2 {
+		t.Errorf("getJSON() = %v with the breaker open, %d requests", err, *n)
+	}
+	if st := c.Stats(); st.BreakerState != bssTypes.BreakerOpen || st.BreakerOpened != 1 || st.Rejected != 1 {
+		t.Errorf("Stats %+v", st)
+	}
+
+	time.Sleep(c.cooldown)
+	if st := c.Stats(); st.BreakerState != bssTypes.BreakerHalfOpen {
+		t.Errorf("Breaker %s after the cooldown", st.BreakerState)
+	}
+	if err := c.getJSON("/x", &v); err != nil {
+		t.Errorf("getJSON() after the cooldown = %v", err)
+	}
+	if st := c.Stats(); st.BreakerState != bssTypes.BreakerClosed {
+		t.Errorf("Breaker %s after a success", st.BreakerState)
+	}
+}
+
+func TestFetchKeepsState(t *testing.T) {
+	for _, path := range []string{"/State/Components", "/Inventory/ComponentEndpoints", "/Inventory/EthernetInterfaces"} {
+		hsm := &fakeHSM{broken: path}
+		withFakeHSM(t, hsm)
+		atomic.StoreInt64(&smFetched, 0)
+		refreshState(-1)
+		if _, ok := FindSMCompByName("x0c0s0b0n0"); !ok || hsm.fullFetches() != 1 {
+			t.Errorf("Bad data from %s replaced the state", path)
+		}
+	}
+
+	// Endpoints which are null, or without Redfish info, are skipped
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/State/Components":
+			w.Write([]byte(`{"Components": [{"ID": "x9c9s9b0n0", "Type": "Node"}]}`))
+		case "/Inventory/ComponentEndpoints":
+			w.Write([]byte(`{"ComponentEndpoints": [null, {"ID": "x9c9s9b0n0", "ComponentEndpointType": "ComponentEndpointComputerSystem"}]}`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer srv.Close()
+	saved := smClient
+	defer func() { smClient = saved }()
+	smClient = testHSMClient(srv)
+	data := fetchHSMState([]string{"x9c9s9b0n0"})
+	if data == nil || len(data.Components) != 1 || !data.Components[0].EndpointEnabled {
+		t.Errorf("fetchHSMState() = %+v", data)
+	}
+}
@@ -0,0 +1,257 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+/*
+ * HSM state snapshots
+ *
+ * Each time the whole HSM state is fetched it is saved, gzipped, to the KV
+ * store or to the file given by BSS_HSM_SNAPSHOT.  At startup the snapshot
+ * is loaded, so that known nodes can boot before HSM answers, or while it is
+ * unreachable.  The state is reported as stale in /boot/v1/service/hsm until
+ * it has been fetched from HSM.
+ */
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+const hsmSnapshotKey = "/hsm-snapshot"
+
+// BSS_HSM_SNAPSHOT: etcd, the path of a file, or off.
+var hsmSnapshot = "etcd"
+
+// The largest snapshot saved in etcd, whose requests are limited to 1.5 MiB
+// by default.  Larger ones need to be saved to a file.
+var hsmSnapshotMaxSize = 1536*1024 - 4096
+
+type hsmSnapshotData struct {
+	Updated time.Time `json:"updated"`
+	State   *SMData   `json:"state"`
+}
+
+var (
+	// Where the state came from, see hsmStateStatus()
+	hsmStateMutex     sync.Mutex
+	smStateSource     string
+	smStateUpdated    time.Time
+	smStateComponents int
+	smFetchFailed     bool
+
+	// Serializes saves, so an older state is never saved over a newer one
+	snapshotMutex sync.Mutex
+	snapshotSaved time.Time
+	snapshotSum   [sha256.Size]byte // Of the state last saved
+	snapshotTo    string            // Where it was saved
+)
+
+func encodeHSMSnapshot(state *SMData, updated time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(hsmSnapshotData{updated, state}); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeHSMSnapshot(data []byte) (hsmSnapshotData, error) {
+	var snap hsmSnapshotData
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return snap, err
+	}
+	if err = json.NewDecoder(zr).Decode(&snap); err == nil && snap.State == nil {
+		err = errors.New("no state")
+	}
+	return snap, err
+}
+
+// Write a file, replacing it only once all has been written.
+func writeFileAtomic(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(name), ".snapshot-")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Save the state fetched from HSM at a time, unless it is the state already
+// saved.  The state must not be changed, and the caller must not hold
+// smMutex since saving may take a while.
+func saveHSMSnapshot(state *SMData, updated time.Time) {
+	if hsmSnapshot == "" || hsmSnapshot == "off" {
+		return
+	}
+	snapshotMutex.Lock()
+	defer snapshotMutex.Unlock()
+	if !updated.After(snapshotSaved) {
+		return
+	}
+	sum, err := hsmStateSum(state)
+	if err == nil && sum == snapshotSum && hsmSnapshot == snapshotTo {
+		debugf("HSM state unchanged, not saving the snapshot")
+		snapshotSaved = updated
+		return
+	}
+	var data []byte
+	if err == nil {
+		data, err = encodeHSMSnapshot(state, updated)
+	}
+	if err == nil {
+		if hsmSnapshot == "etcd" {
+			val := base64.StdEncoding.EncodeToString(data)
+			if len(val) > hsmSnapshotMaxSize {
+				err = fmt.Errorf("%d bytes is more than etcd allows, set BSS_HSM_SNAPSHOT to a file instead", len(val))
+			} else {
+				err = kvstore.Store(hsmSnapshotKey, val)
+			}
+		} else {
+			err = writeFileAtomic(hsmSnapshot, data)
+		}
+	}
+	if err != nil {
+		log.Printf("WARNING: Failed to save the HSM state snapshot of %d components: %s", len(state.Components), err)
+		return
+	}
+	snapshotSaved, snapshotSum, snapshotTo = updated, sum, hsmSnapshot
+	debugf("Saved the HSM state snapshot, %d bytes", len(data))
+}
+
+// A checksum of the state, to tell whether it changed.
+func hsmStateSum(state *SMData) ([sha256.Size]byte, error) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+// Load the saved state, if BSS has none yet.
+func loadHSMSnapshot() error {
+	var data []byte
+	var err error
+	switch hsmSnapshot {
+	case "", "off":
+		return nil
+	case "etcd":
+		val, exists, e := kvstore.Get(hsmSnapshotKey)
+		if e != nil || !exists {
+			return e
+		}
+		data, err = base64.StdEncoding.DecodeString(val)
+	default:
+		data, err = ioutil.ReadFile(hsmSnapshot)
+		if os.IsNotExist(err) {
+			return nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	snap, err := decodeHSMSnapshot(data)
+	if err != nil {
+		return err
+	}
+
+	smMutex.Lock()
+	defer smMutex.Unlock()
+	if smData != nil {
+		return nil
+	}
+	smData = snap.State
+	smDataIndex = makeSmIndex(smData)
+	hsmStateMutex.Lock()
+	smStateSource = bssTypes.HSMSourceSnapshot
+	smStateUpdated = snap.Updated
+	smStateComponents = len(smData.Components)
+	hsmStateMutex.Unlock()
+	log.Printf("Loaded the HSM state snapshot of %d components from %s",
+		len(smData.Components), snap.Updated.Format(time.RFC3339))
+	return nil
+}
+
+// Record the result of a fetch of the whole state.  The state fetched from
+// HSM is saved by the caller, see saveHSMSnapshot().
+func hsmStateFetched(state *SMData) {
+	if smClient == nil {
+		return
+	}
+	now := time.Now()
+	hsmStateMutex.Lock()
+	smFetchFailed = state == nil
+	if state != nil {
+		smStateSource = bssTypes.HSMSourceHSM
+		smStateUpdated = now
+		smStateComponents = len(state.Components)
+	}
+	hsmStateMutex.Unlock()
+}
+
+// Where the HSM state came from, for /boot/v1/service/hsm.
+func hsmStateStatus() bssTypes.HSMStateStatus {
+	hsmStateMutex.Lock()
+	defer hsmStateMutex.Unlock()
+	if smClient == nil {
+		return bssTypes.HSMStateStatus{Source: bssTypes.HSMSourceFile}
+	}
+	st := bssTypes.HSMStateStatus{
+		Source:     smStateSource,
+		Stale:      smStateSource == bssTypes.HSMSourceSnapshot || smFetchFailed,
+		Components: smStateComponents,
+	}
+	if !smStateUpdated.IsZero() {
+		st.Updated = smStateUpdated.UTC().Format(time.RFC3339)
+		st.Age = int64(time.Since(smStateUpdated).Seconds())
+	}
+	return st
+}
@@ -0,0 +1,160 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+func resetHSMStateStatus() {
+	hsmStateMutex.Lock()
+	smStateSource, smStateUpdated, smStateComponents, smFetchFailed = "", time.Time{}, 0, false
+	hsmStateMutex.Unlock()
+}
+
+func TestHSMSnapshotEncoding(t *testing.T) {
+	state := &SMData{Components: []SMComponent{{Component: base.Component{ID: "x9c9s5b0n0"}, Mac: []string{"a4:bf:01:00:09:05"}}}}
+	updated := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	data, err := encodeHSMSnapshot(state, updated)
+	if err != nil {
+		t.Fatalf("encodeHSMSnapshot() failed: %v", err)
+	}
+	snap, err := decodeHSMSnapshot(data)
+	if err != nil || !snap.Updated.Equal(updated) || len(snap.State.Components) != 1 ||
+		snap.State.Components[0].Mac[0] != "a4:bf:01:00:09:05" {
+		t.Errorf("decodeHSMSnapshot() = %+v, %v", snap, err)
+	}
+	if _, err = decodeHSMSnapshot([]byte("{}")); err == nil {
+		t.Errorf("decodeHSMSnapshot() of bad data succeeded")
+	}
+}
+
+func TestHSMSnapshot(t *testing.T) {
+	saved := hsmSnapshot
+	defer func() { hsmSnapshot = saved }()
+	for _, mode := range []string{"etcd", filepath.Join(t.TempDir(), "snapshot", "hsm.gz")} {
+		hsmSnapshot = mode
+		hsm := &fakeHSM{comps: []SMComponent{{Component: base.Component{ID: "x9c9s5b0n0", Type: "Node"}}}}
+		withFakeHSM(t, hsm)
+		refresh := func() {
+			atomic.StoreInt64(&smFetched, 0)
+			refreshState(-1)
+		}
+		refresh()
+		if st := hsmStateStatus(); st.Source != bssTypes.HSMSourceHSM || st.Stale || st.Components != 1 {
+			t.Errorf("%s: status %+v after a fetch", mode, st)
+		}
+
+		// Restart with HSM unreachable
+		smMutex.Lock()
+		smData = nil
+		smMutex.Unlock()
+		resetHSMStateStatus()
+		if err := loadHSMSnapshot(); err != nil {
+			t.Fatalf("%s: loadHSMSnapshot() failed: %v", mode, err)
+		}
+		hsm.mu.Lock()
+		hsm.broken = "/State/Components"
+		hsm.mu.Unlock()
+		refresh()
+		if _, ok := FindSMCompByName("x9c9s5b0n0"); !ok {
+			t.Errorf("%s: component not loaded from the snapshot", mode)
+		}
+		if st := hsmStateStatus(); st.Source != bssTypes.HSMSourceSnapshot || !st.Stale || st.Updated == "" {
+			t.Errorf("%s: status %+v with the snapshot", mode, st)
+		}
+
+		hsm.mu.Lock()
+		hsm.broken = ""
+		hsm.mu.Unlock()
+		refresh()
+		if st := hsmStateStatus(); st.Source != bssTypes.HSMSourceHSM || st.Stale {
+			t.Errorf("%s: status %+v after HSM answered", mode, st)
+		}
+	}
+}
+
+func TestHSMSnapshotSave(t *testing.T) {
+	savedMode, savedMax := hsmSnapshot, hsmSnapshotMaxSize
+	savedVal, savedExists, _ := kvstore.Get(hsmSnapshotKey)
+	defer func() {
+		hsmSnapshot, hsmSnapshotMaxSize = savedMode, savedMax
+		if savedExists {
+			kvstore.Store(hsmSnapshotKey, savedVal)
+		} else {
+			kvstore.Delete(hsmSnapshotKey)
+		}
+	}()
+	hsmSnapshot = "etcd"
+	state := &SMData{Components: []SMComponent{{Component: base.Component{ID: "x9c9s6b0n0"}}}}
+	stored := func() bool {
+		_, ok, _ := kvstore.Get(hsmSnapshotKey)
+		kvstore.Delete(hsmSnapshotKey)
+		return ok
+	}
+	now := time.Now()
+
+	saveHSMSnapshot(state, now)
+	if !stored() {
+		t.Fatalf("Snapshot not saved")
+	}
+	saveHSMSnapshot(&SMData{Components: state.Components}, now.Add(time.Second))
+	if stored() {
+		t.Errorf("Snapshot of an unchanged state saved")
+	}
+	changed := &SMData{Components: []SMComponent{{Component: base.Component{ID: "x9c9s6b0n1"}}}}
+	saveHSMSnapshot(changed, now.Add(2*time.Second))
+	if !stored() {
+		t.Errorf("Snapshot of a changed state not saved")
+	}
+
+	hsmSnapshotMaxSize = 16
+	saveHSMSnapshot(state, now.Add(3*time.Second))
+	if stored() {
+		t.Errorf("Snapshot too large for etcd saved")
+	}
+}
+
+func TestHSMStatusAPI(t *testing.T) {
+	hsm := &fakeHSM{comps: []SMComponent{{Component: base.Component{ID: "x9c9s5b0n0", Type: "Node"}}}}
+	withFakeHSM(t, hsm)
+	hsmStateFetched(nil)
+
+	rr := serveRequest(t, serviceStatusAPI, http.MethodGet, "/service/hsm", nil)
+	var st bssTypes.ServiceStatus
+	if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &st) != nil {
+		t.Fatalf("GET returned %d: %s", rr.Code, rr.Body)
+	}
+	if st.HSMStatus != "connected" || st.HSMClient == nil || st.HSMClient.Requests != 1 ||
+		st.HSMState == nil || !st.HSMState.Stale {
+		t.Errorf("Unexpected status: %s", rr.Body)
+	}
+}
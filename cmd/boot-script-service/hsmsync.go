@@ -0,0 +1,339 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+/*
+ * Incremental HSM state sync
+ *
+ * Each state change notification names the components which changed.  They
+ * are recorded in the KV store, so that every BSS instance sees them, and
+ * each instance refreshes only those components from HSM the next time it
+ * checks its state.  A notification without components, or with more than
+ * hsmTargetedMax of them, has every instance fetch the whole state instead,
+ * as before.
+ *
+ * The whole state is also fetched every BSS_HSM_RESYNC_INTERVAL seconds, in
+ * case a notification was missed.  Refreshes forced by requests from unknown
+ * nodes or addresses are made at most once every
+ * BSS_HSM_REFRESH_MIN_INTERVAL seconds, and requests which arrive while one
+ * is being made wait for it rather than making their own.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Cray-HPE/hms-smd/pkg/sm"
+)
+
+// Changes are stored as <hsmChangesPfx><unix nanoseconds>, with a JSON list
+// of the components as the value.
+const hsmChangesPfx = "/hsm-changes/"
+
+// How long changes are kept.  Instances check for them every
+// hsmChangesPollInterval seconds, see hsmSyncer().
+const hsmChangesTTL = time.Hour
+
+var (
+	hsmResyncInterval      = 3600 // Seconds, 0 for none
+	hsmRefreshMinInterval  = 10   // Seconds
+	hsmTargetedMax         = 500  // Components refreshed individually
+	hsmChangesPollInterval = 5    // Seconds
+
+	smFetched int64 // When the whole state was last fetched, unix nanoseconds
+
+	// Set while hsmSyncer() fetches the whole state for too many changes
+	hsmRefreshing int32
+
+	// Wakes hsmSyncer() to apply changes, coalescing until it does
+	hsmChangesWake = make(chan struct{}, 1)
+
+	// Serializes targeted refreshes
+	smSyncMutex sync.Mutex
+
+	hsmChangesMutex  sync.Mutex
+	smChangesApplied map[string]bool // Change keys already applied
+	smChangesFailed  time.Time       // When a targeted refresh last failed
+)
+
+func hsmChangeKey(t time.Time) string {
+	return fmt.Sprintf("%s%020d", hsmChangesPfx, t.UnixNano())
+}
+
+// Record the components named by a state change notification, for every
+// BSS instance to refresh.
+func recordHSMChange(comps []string) error {
+	now := time.Now()
+	if len(comps) == 0 || len(comps) > hsmTargetedMax {
+		// Have every instance fetch the whole state
+		timestamp := strconv.FormatInt(now.Unix(), 10)
+		return kvstore.Store(UpdateTimestampKey, timestamp)
+	}
+	data, _ := json.Marshal(comps)
+	if err := kvstore.Store(hsmChangeKey(now), string(data)); err != nil {
+		return err
+	}
+	pruneHSMChanges(now.Add(-hsmChangesTTL))
+	return nil
+}
+
+// Remove the changes recorded before a time.
+func pruneHSMChanges(before time.Time) {
+	kvl, err := kvstore.GetRange(hsmChangesPfx, hsmChangeKey(before))
+	if err != nil {
+		return
+	}
+	for _, kv := range kvl {
+		if kv.Key < hsmChangeKey(before) {
+			kvstore.Delete(kv.Key)
+		}
+	}
+}
+
+// The keys of the changes currently recorded.
+func hsmChangeKeys() []string {
+	if kvstore == nil {
+		return nil
+	}
+	kvl, err := kvstore.GetRange(hsmChangesPfx, hsmChangesPfx+"~")
+	if err != nil {
+		return nil
+	}
+	keys := make([]string, len(kvl))
+	for i, kv := range kvl {
+		keys[i] = kv.Key
+	}
+	return keys
+}
+
+// Have hsmSyncer() apply the changes recorded, without waiting for it.
+func wakeHSMSyncer() {
+	select {
+	case hsmChangesWake <- struct{}{}:
+	default:
+	}
+}
+
+// Mark changes as applied, when the whole state has been fetched.  Changes
+// no longer recorded are forgotten.
+func markHSMChanges(keys []string) {
+	applied := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		applied[k] = true
+	}
+	hsmChangesMutex.Lock()
+	smChangesApplied = applied
+	hsmChangesMutex.Unlock()
+}
+
+// Whether a forced refresh would be made now, or skipped by the rate limit.
+func forcedRefreshDue() bool {
+	last := atomic.LoadInt64(&smFetched)
+	return last == 0 || time.Since(time.Unix(0, last)) >= time.Duration(hsmRefreshMinInterval)*time.Second
+}
+
+// The IDs to fetch for the components named by changes.  Components which
+// are not nodes, such as BMCs, are replaced by the nodes they contain.  The
+// IDs themselves are kept, since they may be nodes new to BSS.
+func hsmChangeIDs(names map[string]bool) []string {
+	state, idx := getStateAndIndex()
+	ids := make(map[string]bool, len(names))
+	for name := range names {
+		ids[name] = true
+		if _, ok := idx.byID[name]; ok || state == nil {
+			continue
+		}
+		for _, c := range state.Components {
+			if len(c.ID) > len(name) && strings.HasPrefix(c.ID, name) &&
+				c.ID[len(name)] >= 'a' && c.ID[len(name)] <= 'z' {
+				ids[c.ID] = true
+			}
+		}
+	}
+	ret := make([]string, 0, len(ids))
+	for id := range ids {
+		ret = append(ret, id)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Replace the given components of the state with those fetched from HSM.
+// Components HSM didn't return have been removed.  The group labels of
+// components are kept, since they are only fetched with the whole state.
+func mergeHSMState(old *SMData, ids []string, fresh *SMData) *SMData {
+	changed := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		changed[id] = true
+	}
+	byID := make(map[string]int, len(fresh.Components))
+	for i, c := range fresh.Components {
+		byID[c.ID] = i
+	}
+	ret := &SMData{
+		Components: make([]SMComponent, 0, len(old.Components)+len(fresh.Components)),
+		IPAddrs:    make(map[string]sm.CompEthInterfaceV2, len(old.IPAddrs)),
+	}
+	for _, c := range old.Components {
+		if !changed[c.ID] {
+			ret.Components = append(ret.Components, c)
+			continue
+		}
+		if i, ok := byID[c.ID]; ok {
+			f := fresh.Components[i]
+			f.Groups = c.Groups
+			ret.Components = append(ret.Components, f)
+			delete(byID, c.ID)
+		}
+	}
+	for _, f := range fresh.Components {
+		if _, ok := byID[f.ID]; ok {
+			ret.Components = append(ret.Components, f)
+		}
+	}
+	for ip, e := range old.IPAddrs {
+		if !changed[e.CompID] {
+			ret.IPAddrs[ip] = e
+		}
+	}
+	for ip, e := range fresh.IPAddrs {
+		ret.IPAddrs[ip] = e
+	}
+	return ret
+}
+
+// Refresh the components named by the changes this instance hasn't applied.
+// If there are too many of them, the whole state is fetched instead and true
+// is returned.  Boot requests don't wait for this, see hsmSyncer().
+func applyHSMChanges() bool {
+	if smClient == nil {
+		return false
+	}
+	smSyncMutex.Lock()
+	defer smSyncMutex.Unlock()
+	kvl, err := kvstore.GetRange(hsmChangesPfx, hsmChangesPfx+"~")
+	if err != nil || len(kvl) == 0 {
+		return false
+	}
+	hsmChangesMutex.Lock()
+	applied, failed := smChangesApplied, smChangesFailed
+	hsmChangesMutex.Unlock()
+	if time.Since(failed) < time.Duration(hsmRefreshMinInterval)*time.Second {
+		return false
+	}
+
+	var keys []string
+	names := make(map[string]bool)
+	for _, kv := range kvl {
+		if applied[kv.Key] {
+			continue
+		}
+		keys = append(keys, kv.Key)
+		var comps []string
+		if err := json.Unmarshal([]byte(kv.Value), &comps); err != nil {
+			log.Printf("WARNING: Ignoring bad HSM change record %s: %s", kv.Key, err)
+			continue
+		}
+		for _, c := range comps {
+			names[c] = true
+		}
+	}
+	if len(keys) == 0 {
+		return false
+	}
+	ids := hsmChangeIDs(names)
+	if len(ids) > hsmTargetedMax {
+		debugf("%d components changed, fetching the whole state", len(ids))
+		atomic.StoreInt32(&hsmRefreshing, 1)
+		refreshState(time.Now().Unix())
+		atomic.StoreInt32(&hsmRefreshing, 0)
+		return true
+	}
+	if len(ids) > 0 {
+		fresh := fetchHSMState(ids)
+		if fresh == nil {
+			hsmChangesMutex.Lock()
+			smChangesFailed = time.Now()
+			hsmChangesMutex.Unlock()
+			return false
+		}
+		smMutex.Lock()
+		if smData != nil {
+			smData = mergeHSMState(smData, ids, fresh)
+			smDataIndex = makeSmIndex(smData)
+			hsmStateMutex.Lock()
+			smStateComponents = len(smData.Components)
+			hsmStateMutex.Unlock()
+		}
+		smMutex.Unlock()
+	}
+
+	current := make(map[string]bool, len(kvl))
+	for _, kv := range kvl {
+		current[kv.Key] = true
+	}
+	hsmChangesMutex.Lock()
+	next := make(map[string]bool, len(kvl))
+	for k := range smChangesApplied {
+		if current[k] {
+			next[k] = true
+		}
+	}
+	for _, k := range keys {
+		next[k] = true
+	}
+	smChangesApplied = next
+	hsmChangesMutex.Unlock()
+	return false
+}
+
+// Apply changes as they are recorded by this instance, and every
+// hsmChangesPollInterval seconds those recorded by other instances.  Fetch
+// the whole state every hsmResyncInterval seconds.
+func hsmSyncer() {
+	interval := time.Duration(hsmChangesPollInterval) * time.Second
+	if interval <= 0 {
+		interval = time.Second
+	}
+	poll := time.NewTicker(interval)
+	defer poll.Stop()
+	for {
+		select {
+		case <-hsmChangesWake:
+		case <-poll.C:
+		}
+		last := time.Unix(0, atomic.LoadInt64(&smFetched))
+		if hsmResyncInterval > 0 && time.Since(last) >= time.Duration(hsmResyncInterval)*time.Second {
+			refreshState(time.Now().Unix())
+		} else {
+			applyHSMChanges()
+		}
+	}
+}
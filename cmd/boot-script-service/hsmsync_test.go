@@ -0,0 +1,314 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-smd/pkg/sm"
+)
+
+// A fake HSM, which records the requests made to it.
+type fakeHSM struct {
+	mu       sync.Mutex
+	comps    []SMComponent
+	eth      []sm.CompEthInterfaceV2
+	delay    time.Duration
+	broken   string // Path which returns bad data
+	requests []string
+}
+
+func (f *fakeHSM) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(f.delay)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.URL.RequestURI())
+	q := r.URL.Query()
+	want := func(param, id string) bool {
+		ids, ok := q[param]
+		if !ok {
+			return true
+		}
+		for _, i := range ids {
+			if i == id {
+				return true
+			}
+		}
+		return false
+	}
+	if r.URL.Path == f.broken {
+		w.Write([]byte(`{"Components": [`))
+		return
+	}
+	var body interface{}
+	switch r.URL.Path {
+	case "/State/Components":
+		var comps SMData
+		for _, c := range f.comps {
+			if want("id", c.ID) {
+				comps.Components = append(comps.Components, c)
+			}
+		}
+		body = comps
+	case "/Inventory/ComponentEndpoints":
+		body = sm.ComponentEndpointArray{ComponentEndpoints: []*sm.ComponentEndpoint{}}
+	case "/service/values/class":
+		body = map[string]interface{}{}
+	case "/Inventory/EthernetInterfaces":
+		eth := []sm.CompEthInterfaceV2{}
+		for _, e := range f.eth {
+			if want("ComponentID", e.CompID) {
+				eth = append(eth, e)
+			}
+		}
+		body = eth
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(body)
+}
+
+// The requests made for the components of the whole state.
+func (f *fakeHSM) fullFetches() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, r := range f.requests {
+		if r == "/State/Components?type=Node" {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeHSM) takeRequests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ret := f.requests
+	f.requests = nil
+	return ret
+}
+
+// Use a fake HSM for the rest of a test.  The state is treated as fetched
+// just now, and restored when the test ends.
+func withFakeHSM(t *testing.T, f *fakeHSM) {
+	withComponents(t)
+	srv := httptest.NewServer(f)
+	savedClient, savedInventory, savedTS := smClient, smInventory, smTimeStamp
+	savedFetched := atomic.LoadInt64(&smFetched)
+	smClient, smInventory, smTimeStamp = testHSMClient(srv), hsmInventory{}, time.Now().Unix()
+	atomic.StoreInt64(&smFetched, time.Now().UnixNano())
+	markHSMChanges(hsmChangeKeys())
+	t.Cleanup(func() {
+		srv.Close()
+		smClient, smInventory, smTimeStamp = savedClient, savedInventory, savedTS
+		atomic.StoreInt64(&smFetched, savedFetched)
+		for _, k := range hsmChangeKeys() {
+			kvstore.Delete(k)
+		}
+		markHSMChanges(nil)
+		kvstore.Delete(hsmSnapshotKey)
+		snapshotMutex.Lock()
+		snapshotSaved = time.Time{}
+		snapshotMutex.Unlock()
+		resetHSMStateStatus()
+		hsmChangesMutex.Lock()
+		smChangesFailed = time.Time{}
+		hsmChangesMutex.Unlock()
+	})
+}
+
+func TestRecordHSMChange(t *testing.T) {
+	saved, exists, _ := kvstore.Get(UpdateTimestampKey)
+	defer func() {
+		if exists {
+			kvstore.Store(UpdateTimestampKey, saved)
+		} else {
+			kvstore.Delete(UpdateTimestampKey)
+		}
+		for _, k := range hsmChangeKeys() {
+			kvstore.Delete(k)
+		}
+	}()
+
+	kvstore.Delete(UpdateTimestampKey)
+	if err := recordHSMChange(nil); err != nil {
+		t.Fatalf("recordHSMChange(nil) failed: %v", err)
+	}
+	if _, ok, _ := kvstore.Get(UpdateTimestampKey); !ok || len(hsmChangeKeys()) != 0 {
+		t.Errorf("A change without components should store only the update timestamp")
+	}
+
+	old := hsmChangeKey(time.Now().Add(-2 * hsmChangesTTL))
+	kvstore.Store(old, `["x1c0s0b0n0"]`)
+	if err := recordHSMChange([]string{"x1c0s1b0n0"}); err != nil {
+		t.Fatalf("recordHSMChange() failed: %v", err)
+	}
+	keys := hsmChangeKeys()
+	if len(keys) != 1 || keys[0] == old {
+		t.Fatalf("Changes %v, expected only the new one", keys)
+	}
+	if v, _, _ := kvstore.Get(keys[0]); v != `["x1c0s1b0n0"]` {
+		t.Errorf("Change recorded as %s", v)
+	}
+}
+
+func TestTargetedRefresh(t *testing.T) {
+	node := func(id string, nid int, state string) SMComponent {
+		return SMComponent{Component: base.Component{ID: id, NID: json.Number(strconv.Itoa(nid)),
+			State: state, Role: "Compute"}, EndpointEnabled: true}
+	}
+	withComponents(t, node("x9c9s0b0n0", 9900, "Ready"), node("x9c9s1b0n0", 9901, "Ready"))
+	hsm := &fakeHSM{
+		comps: []SMComponent{node("x9c9s0b0n0", 9900, "Standby"), node("x9c9s2b0n0", 9902, "Ready")},
+		eth: []sm.CompEthInterfaceV2{{ID: "a4bf01000902", MACAddr: "a4:bf:01:00:09:02", CompID: "x9c9s2b0n0",
+			IPAddrs: []sm.IPAddressMapping{{IPAddr: "10.9.9.2"}}}},
+	}
+	withFakeHSM(t, hsm)
+
+	if err := recordHSMChange([]string{"x9c9s0b0n0", "x9c9s1b0n0", "x9c9s2b0n0"}); err != nil {
+		t.Fatalf("recordHSMChange() failed: %v", err)
+	}
+	// Boot requests leave the changes to hsmSyncer()
+	if checkState(false) {
+		t.Errorf("checkState() should not wait for the changes")
+	}
+	if reqs := hsm.takeRequests(); len(reqs) != 0 {
+		t.Fatalf("checkState() applied the changes: %v", reqs)
+	}
+	if applyHSMChanges() {
+		t.Errorf("applyHSMChanges() should refresh the changed components")
+	}
+	reqs := hsm.takeRequests()
+	if len(reqs) != 3 {
+		t.Fatalf("Requests %v, expected 3", reqs)
+	}
+	for _, r := range reqs {
+		if !strings.Contains(r, "x9c9s1b0n0") || strings.Contains(r, "x0c0s0b0n0") {
+			t.Errorf("Request %s is not for the changed components", r)
+		}
+	}
+	if c, ok := FindSMCompByName("x9c9s0b0n0"); !ok || c.State != "Standby" {
+		t.Errorf("x9c9s0b0n0 not updated: %+v", c)
+	}
+	if _, ok := FindSMCompByName("x9c9s1b0n0"); ok {
+		t.Errorf("x9c9s1b0n0 not removed")
+	}
+	if c, ok := FindSMCompByNid(9902); !ok || c.ID != "x9c9s2b0n0" {
+		t.Errorf("x9c9s2b0n0 not added: %+v", c)
+	}
+	if c, ok := FindSMCompByMAC("a4:bf:01:00:09:02"); !ok || c.ID != "x9c9s2b0n0" {
+		t.Errorf("x9c9s2b0n0 not indexed by MAC: %+v", c)
+	}
+	if id, ok := FindXnameByIP("10.9.9.2"); !ok || id != "x9c9s2b0n0" {
+		t.Errorf("FindXnameByIP(10.9.9.2) = %s, %v", id, ok)
+	}
+	if _, ok := FindSMCompByName("x0c0s0b0n0"); !ok {
+		t.Errorf("Unchanged components should be kept")
+	}
+
+	// The changes have been applied
+	applyHSMChanges()
+	if reqs := hsm.takeRequests(); len(reqs) != 0 {
+		t.Errorf("Changes applied again: %v", reqs)
+	}
+
+	// A BMC is replaced by its nodes
+	recordHSMChange([]string{"x9c9s0b0"})
+	applyHSMChanges()
+	reqs = hsm.takeRequests()
+	if len(reqs) == 0 || !strings.Contains(reqs[0], "id=x9c9s0b0n0") {
+		t.Errorf("Requests %v, expected x9c9s0b0n0", reqs)
+	}
+}
+
+func TestForcedRefreshRateLimit(t *testing.T) {
+	hsm := &fakeHSM{delay: 20 * time.Millisecond}
+	withFakeHSM(t, hsm)
+	atomic.StoreInt64(&smFetched, 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			FindXnameByIP("10.9.9.99")
+		}()
+	}
+	wg.Wait()
+	if n := hsm.fullFetches(); n != 1 {
+		t.Errorf("%d fetches of the whole state for unknown addresses, expected 1", n)
+	}
+	if !checkState(true) {
+		t.Errorf("checkState(true) should have the requester ask again when the refresh is skipped")
+	}
+	if n := hsm.fullFetches(); n != 1 {
+		t.Errorf("%d fetches of the whole state after checkState(true), expected 1", n)
+	}
+
+	// An unknown node is still asked for its architecture
+	rr := serveRequest(t, bootScript, http.MethodGet, "/bootscript?mac=de:ad:be:ef:00:01", nil)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "arch=") {
+		t.Errorf("GET bootscript for an unknown MAC returned %d: %s", rr.Code, rr.Body)
+	}
+	if n := hsm.fullFetches(); n != 1 {
+		t.Errorf("%d fetches of the whole state after the unknown MAC, expected 1", n)
+	}
+}
+
+func TestLookupDuringRefresh(t *testing.T) {
+	hsm := &fakeHSM{delay: 300 * time.Millisecond}
+	withFakeHSM(t, hsm)
+	atomic.StoreInt64(&smFetched, 0)
+	state, _ := getStateAndIndex()
+	name := state.Components[0].ID
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		refreshState(-1)
+	}()
+	// Give the refresh time to start fetching
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	if _, ok := FindSMCompByName(name); !ok {
+		t.Errorf("%s not found during a refresh", name)
+	}
+	if d := time.Since(start); d > 150*time.Millisecond {
+		t.Errorf("Lookup during a refresh took %s", d)
+	}
+	<-done
+	if n := hsm.fullFetches(); n != 1 {
+		t.Errorf("%d fetches of the whole state, expected 1", n)
+	}
+}
@@ -0,0 +1,559 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+/*
+ * Image catalog
+ *
+ * Kernel and initrd images are stored under /kernel/ and /initrd/ keys made
+ * from a hash of their path.  Along with the path and any boot parameters of
+ * the image itself, an image may have catalog metadata: a name, version,
+ * architecture, size, sha256 checksum, creation time and labels.
+ *
+ * Each boot parameters or boot profile entry which references an image has
+ * a key under /image-refs/<image key>/<entry key>, written in the same batch
+ * as the entry by applyBatch, so that the users of an image can be found
+ * without reading every entry.  The index is checked against the entries
+ * when the service starts and before garbage collection.
+ *
+ * An image which is in use can only be deleted with force, which also clears
+ * the references to it.  Garbage collection deletes the images which nothing
+ * references.
+ */
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+const imageRefsPfx = "/image-refs"
+
+func imageRefKey(imageKey, entryKey string) string {
+	return imageRefsPfx + imageKey + entryKey
+}
+
+// Split an image reference key into the image and entry keys.
+func splitImageRef(key string) (string, string) {
+	parts := strings.SplitN(strings.TrimPrefix(key, imageRefsPfx), "/", 4)
+	if len(parts) < 4 {
+		return "", ""
+	}
+	return "/" + parts[1] + "/" + parts[2], "/" + parts[3]
+}
+
+func isImageReferrer(key string) bool {
+	return strings.HasPrefix(key, paramsPfx) || strings.HasPrefix(key, profilesPfx)
+}
+
+// The keys of the images a boot parameters or boot profile entry references.
+func referencedImages(value string) []string {
+	var bds BootDataStore
+	if value == "" || json.Unmarshal([]byte(value), &bds) != nil {
+		return nil
+	}
+	keys := []string{bds.Kernel, bds.Initrd}
+	for _, v := range bds.ArchVariants {
+		keys = append(keys, v.Kernel, v.Initrd)
+	}
+	var ret []string
+	seen := map[string]bool{"": true}
+	for _, k := range keys {
+		if !seen[k] {
+			seen[k] = true
+			ret = append(ret, k)
+		}
+	}
+	return ret
+}
+
+// Add the changes to the image reference index made by a batch of writes.
+func withImageRefs(ops []kvOp) ([]kvOp, error) {
+	ret := ops
+	for _, op := range ops {
+		if op.check || !isImageReferrer(op.key) {
+			continue
+		}
+		prev, exists, err := kvstore.Get(op.key)
+		if err != nil {
+			return ops, err
+		}
+		old := map[string]bool{}
+		if exists {
+			for _, im := range referencedImages(prev) {
+				old[im] = true
+			}
+		}
+		if !op.delete {
+			for _, im := range referencedImages(op.value) {
+				if old[im] {
+					delete(old, im)
+				} else {
+					ret = append(ret, kvOp{key: imageRefKey(im, op.key), value: op.key})
+				}
+			}
+		}
+		for im := range old {
+			ret = append(ret, kvOp{key: imageRefKey(im, op.key), delete: true})
+		}
+	}
+	return ret, nil
+}
+
+// All image references, by image key.
+func getImageRefs() (map[string][]string, error) {
+	kvl, err := kvstore.GetRange(imageRefsPfx+"/"+keyMin, imageRefsPfx+"/"+keyMax)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string][]string)
+	for _, kv := range kvl {
+		if im, entry := splitImageRef(kv.Key); im != "" {
+			ret[im] = append(ret[im], entry)
+		}
+	}
+	return ret, nil
+}
+
+// The keys of the entries which reference an image.
+func imageRefs(imageKey string) ([]string, error) {
+	pfx := imageRefsPfx + imageKey + "/"
+	kvl, err := kvstore.GetRange(pfx+keyMin, pfx+keyMax)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, 0, len(kvl))
+	for _, kv := range kvl {
+		_, entry := splitImageRef(kv.Key)
+		ret = append(ret, entry)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// Make the image reference index match the entries, returning the number of
+// references added or removed.
+func rebuildImageRefs() (int, error) {
+	want := make(map[string]string)
+	for _, pfx := range []string{paramsPfx, profilesPfx} {
+		kvl, err := kvstore.GetRange(pfx+keyMin, pfx+keyMax)
+		if err != nil {
+			return 0, err
+		}
+		for _, kv := range kvl {
+			for _, im := range referencedImages(kv.Value) {
+				want[imageRefKey(im, kv.Key)] = kv.Key
+			}
+		}
+	}
+	have, err := kvstore.GetRange(imageRefsPfx+"/"+keyMin, imageRefsPfx+"/"+keyMax)
+	if err != nil {
+		return 0, err
+	}
+	var ops []kvOp
+	for _, kv := range have {
+		if _, ok := want[kv.Key]; ok {
+			delete(want, kv.Key)
+		} else {
+			ops = append(ops, kvOp{key: kv.Key, delete: true})
+		}
+	}
+	for k, v := range want {
+		ops = append(ops, kvOp{key: k, value: v})
+	}
+	if len(ops) == 0 {
+		return 0, nil
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].key < ops[j].key })
+	_, err = applyBatch(ops)
+	return len(ops), err
+}
+
+func imageConvert(key string, imdata ImageData) bssTypes.Image {
+	parts := strings.SplitN(strings.TrimPrefix(key, "/"), "/", 2)
+	img := bssTypes.Image{
+		Type:    parts[0],
+		Path:    imdata.Path,
+		Params:  imdata.Params,
+		Name:    imdata.Name,
+		Version: imdata.Version,
+		Arch:    imdata.Arch,
+		Size:    imdata.Size,
+		Sha256:  imdata.Sha256,
+		Created: imdata.Created,
+		Labels:  imdata.Labels,
+
+		Validation: imdata.Validation,
+	}
+	if len(parts) > 1 {
+		img.ID = parts[1]
+	}
+	return img
+}
+
+func validImageType(imtype string) bool {
+	return imtype == kernelImageType || imtype == initrdImageType
+}
+
+// The hosts and roles which boot with an image, either directly or through a
+// boot profile.
+func imageHosts(refs []string) ([]string, error) {
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		if strings.HasPrefix(ref, profilesPfx) {
+			hosts, err := profileHosts(strings.TrimPrefix(ref, profilesPfx))
+			if err != nil {
+				return nil, err
+			}
+			for _, h := range hosts {
+				seen[h] = true
+			}
+		} else {
+			seen[strings.TrimPrefix(ref, paramsPfx)] = true
+		}
+	}
+	ret := make([]string, 0, len(seen))
+	for h := range seen {
+		ret = append(ret, h)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// List the images of the catalog, with their reference counts.
+func getCatalog(imtype string) ([]bssTypes.Image, error) {
+	refs, err := getImageRefs()
+	if err != nil {
+		return nil, err
+	}
+	types := []string{kernelImageType, initrdImageType}
+	if imtype != "" {
+		types = []string{imtype}
+	}
+	ret := []bssTypes.Image{}
+	for _, t := range types {
+		kvl, err := getImages(t)
+		if err != nil {
+			return nil, err
+		}
+		for _, kv := range kvl {
+			var imdata ImageData
+			if e := json.Unmarshal([]byte(kv.Value), &imdata); e != nil {
+				log.Printf("Skipping bad image %s: %s", kv.Key, e)
+				continue
+			}
+			img := imageConvert(kv.Key, imdata)
+			img.RefCount = len(refs[kv.Key])
+			ret = append(ret, img)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Type != ret[j].Type {
+			return ret[i].Type > ret[j].Type
+		}
+		return ret[i].Path < ret[j].Path
+	})
+	return ret, nil
+}
+
+// Check the catalog metadata of an image.
+func checkImage(img bssTypes.Image) error {
+	if img.Size < 0 {
+		return fmt.Errorf("size may not be negative")
+	}
+	if img.Sha256 != "" {
+		if b, err := hex.DecodeString(img.Sha256); err != nil || len(b) != 32 {
+			return fmt.Errorf("sha256 must be 64 hexadecimal digits")
+		}
+	}
+	return nil
+}
+
+func imageMatches(img bssTypes.Image, r *http.Request) bool {
+	if v := r.FormValue("name"); v != "" && img.Name != v {
+		return false
+	}
+	if v := r.FormValue("arch"); v != "" && img.Arch != v {
+		return false
+	}
+	if v := r.FormValue("path"); v != "" && img.Path != v {
+		return false
+	}
+	for _, l := range r.Form["label"] {
+		kv := strings.SplitN(l, "=", 2)
+		v, ok := img.Labels[kv[0]]
+		if !ok || (len(kv) == 2 && v != kv[1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// List images, or get one along with the entries and hosts which use it.
+func imagesGetAPI(w http.ResponseWriter, r *http.Request, imtype, id string) {
+	debugf("imagesGetAPI(): Received request %v\n", r.URL)
+	r.ParseForm()
+	if imtype == "" {
+		imtype = r.FormValue("type")
+	}
+	if imtype != "" && !validImageType(imtype) {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+			fmt.Sprintf("Image type must be %s or %s", kernelImageType, initrdImageType))
+		return
+	}
+	if id == "" {
+		il, err := getCatalog(imtype)
+		if err != nil {
+			base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+				fmt.Sprintf("Failed to retrieve images: %s", err))
+			return
+		}
+		ret := il[:0]
+		for _, img := range il {
+			if imageMatches(img, r) {
+				ret = append(ret, img)
+			}
+		}
+		sendJSON(w, http.StatusOK, ret)
+		return
+	}
+
+	key := makeKey(imtype, id)
+	val, exists, err := kvstore.Get(key)
+	var imdata ImageData
+	if err == nil && exists {
+		err = json.Unmarshal([]byte(val), &imdata)
+	}
+	var refs, hosts []string
+	if err == nil && exists {
+		if refs, err = imageRefs(key); err == nil {
+			hosts, err = imageHosts(refs)
+		}
+	}
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+			fmt.Sprintf("Failed to retrieve image %s: %s", key, err))
+		return
+	}
+	if !exists {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound,
+			fmt.Sprintf("Image %s does not exist", key))
+		return
+	}
+	img := imageConvert(key, imdata)
+	img.RefCount = len(refs)
+	img.References = refs
+	img.Hosts = hosts
+	sendJSON(w, http.StatusOK, img)
+}
+
+// Register an image with POST, or replace the catalog metadata of an image
+// with PUT.  The path, boot parameters and creation time of an image are kept
+// by PUT.
+func imagesStoreAPI(w http.ResponseWriter, r *http.Request, imtype, id string) {
+	debugf("imagesStoreAPI(): Received request %v\n", r.URL)
+	var img bssTypes.Image
+	body, err := ioutil.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(body, &img)
+	}
+	if err == nil {
+		err = checkImage(img)
+	}
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+			fmt.Sprintf("Bad Request: %s", err))
+		return
+	}
+	if imtype == "" {
+		imtype = img.Type
+	}
+	if !validImageType(imtype) || (img.Type != "" && img.Type != imtype) {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+			fmt.Sprintf("Image type must be %s or %s", kernelImageType, initrdImageType))
+		return
+	}
+
+	kvMutex.Lock()
+	defer kvMutex.Unlock()
+	var key string
+	var imdata ImageData
+	if id == "" {
+		if img.Path == "" {
+			base.SendProblemDetailsGeneric(w, http.StatusBadRequest, "Bad Request: path is required")
+			return
+		}
+		kvl, err := getImages(imtype)
+		if err == nil {
+			if k, _ := imageLookup(img.Path, imtype, kvl); k != "" {
+				base.SendProblemDetailsGeneric(w, http.StatusConflict,
+					fmt.Sprintf("Image %s already exists as %s", img.Path, k))
+				return
+			}
+		}
+		key = makeImageKey(imtype, img.Path)
+		imdata = ImageData{Path: img.Path, Params: img.Params, Created: time.Now().UTC().Format(time.RFC3339)}
+	} else {
+		key = makeKey(imtype, id)
+		if imdata, err = getImage(key, ""); err != nil {
+			base.SendProblemDetailsGeneric(w, http.StatusNotFound,
+				fmt.Sprintf("Image %s does not exist", key))
+			return
+		}
+		if img.Path != "" && img.Path != imdata.Path {
+			base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+				fmt.Sprintf("Image path %s does not match %s", img.Path, imdata.Path))
+			return
+		}
+	}
+	imdata.Name = img.Name
+	imdata.Version = img.Version
+	imdata.Arch = img.Arch
+	imdata.Size = img.Size
+	if !strings.EqualFold(imdata.Sha256, img.Sha256) {
+		// The last validation was against the old checksum
+		imdata.Validation = nil
+	}
+	imdata.Sha256 = strings.ToLower(img.Sha256)
+	imdata.Labels = img.Labels
+	if err = storeEntry(key, imdata, changeRequest{who: requestUser(r)}); err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Printf("Stored image %s for %s", key, imdata.Path)
+	ret := imageConvert(key, imdata)
+	if refs, err := imageRefs(key); err == nil {
+		ret.RefCount = len(refs)
+	}
+	status := http.StatusOK
+	if id == "" {
+		status = http.StatusCreated
+	}
+	sendJSON(w, status, ret)
+}
+
+// Delete an image.  An image which is in use is only deleted with force=true,
+// which also removes it from the entries which reference it.
+func imagesDeleteAPI(w http.ResponseWriter, r *http.Request, imtype, id string) {
+	debugf("imagesDeleteAPI(): Received request %v\n", r.URL)
+	r.ParseForm()
+	force, _ := strconv.ParseBool(r.FormValue("force"))
+	key := makeKey(imtype, id)
+	imdata, err := getImage(key, "")
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound,
+			fmt.Sprintf("Image %s does not exist", key))
+		return
+	}
+	refs, err := imageRefs(key)
+	if err == nil && len(refs) > 0 && !force {
+		base.SendProblemDetailsGeneric(w, http.StatusConflict,
+			fmt.Sprintf("Image %s is in use by %s", key, strings.Join(refs, ",")))
+		return
+	}
+	if err == nil {
+		err = removeImage(imdata.Path, imtype, changeRequest{who: requestUser(r)})
+	}
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+			fmt.Sprintf("Failed to delete image %s: %s", key, err))
+		return
+	}
+	log.Printf("Deleted image %s for %s, clearing %d references", key, imdata.Path, len(refs))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Delete the images which nothing references and which were created before
+// the cutoff, returning them.  Nothing is deleted for a dry run.  A new image
+// is written in the same batch as the entry which references it, so that it
+// is never seen unreferenced, and a batch which references an existing image
+// fails if it was deleted in the meantime.
+func collectImages(cutoff time.Time, dryRun bool, cr changeRequest) ([]bssTypes.Image, error) {
+	kvMutex.Lock()
+	defer kvMutex.Unlock()
+	if n, err := rebuildImageRefs(); err != nil {
+		return nil, err
+	} else if n > 0 {
+		log.Printf("Repaired %d image references", n)
+	}
+	il, err := getCatalog("")
+	if err != nil {
+		return nil, err
+	}
+	ret := []bssTypes.Image{}
+	var ops []kvOp
+	for _, img := range il {
+		if img.RefCount > 0 {
+			continue
+		}
+		if t, e := time.Parse(time.RFC3339, img.Created); e == nil && t.After(cutoff) {
+			continue
+		}
+		ret = append(ret, img)
+		ops = append(ops, kvOp{key: makeKey(img.Type, img.ID), delete: true})
+	}
+	if dryRun || len(ops) == 0 {
+		return ret, nil
+	}
+	_, err = applyWithHistory(func() ([]kvOp, error) { return ops, nil }, cr)
+	return ret, err
+}
+
+// Garbage collect unreferenced images.  With older-than, images created
+// within that duration are kept, so that newly registered images are not
+// deleted before they are used.
+func imagesGCAPI(w http.ResponseWriter, r *http.Request) {
+	debugf("imagesGCAPI(): Received request %v\n", r.URL)
+	r.ParseForm()
+	dryRun, _ := strconv.ParseBool(r.FormValue("dry-run"))
+	cutoff := time.Now()
+	if v := r.FormValue("older-than"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+				fmt.Sprintf("Bad Request: older-than: %s", err))
+			return
+		}
+		cutoff = cutoff.Add(-d)
+	}
+	il, err := collectImages(cutoff, dryRun, changeRequest{who: requestUser(r)})
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+			fmt.Sprintf("Image garbage collection failed: %s", err))
+		return
+	}
+	if !dryRun {
+		log.Printf("Garbage collected %d images", len(il))
+	}
+	sendJSON(w, http.StatusOK, il)
+}
@@ -0,0 +1,194 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+func getCatalogImage(t *testing.T, imtype, path string) bssTypes.Image {
+	var img bssTypes.Image
+	id := strings.TrimPrefix(makeImageKey(imtype, path), "/"+imtype+"/")
+	rr := serveRequest(t, images, http.MethodGet, "/images/"+imtype+"/"+id, nil)
+	if rr.Code == http.StatusOK {
+		json.Unmarshal(rr.Body.Bytes(), &img)
+	}
+	return img
+}
+
+func TestImageRefs(t *testing.T) {
+	k1, k2 := "http://images/refs/kernel1", "http://images/refs/kernel2"
+	Store(bssTypes.BootParams{Hosts: []string{"x9c1s0b0n0", "x9c1s1b0n0"}, Kernel: k1, Params: "quiet"})
+	defer deleteHost("x9c1s0b0n0")
+	defer deleteHost("x9c1s1b0n0")
+	if rr := serveRequest(t, profiles, http.MethodPut, "/profiles/refs", bssTypes.BootProfile{Kernel: k1}); rr.Code != http.StatusOK {
+		t.Fatalf("PUT profile returned %d: %s", rr.Code, rr.Body)
+	}
+	defer serveRequest(t, profiles, http.MethodDelete, "/profiles/refs", nil)
+	Store(bssTypes.BootParams{Hosts: []string{"x9c1s2b0n0"}, Profile: "refs"})
+	defer deleteHost("x9c1s2b0n0")
+
+	img := getCatalogImage(t, kernelImageType, k1)
+	wantRefs := []string{paramsPfx + "x9c1s0b0n0", paramsPfx + "x9c1s1b0n0", profilesPfx + "refs"}
+	if img.RefCount != 3 || !reflect.DeepEqual(img.References, wantRefs) {
+		t.Errorf("References %v (%d), expected %v", img.References, img.RefCount, wantRefs)
+	}
+	if want := []string{"x9c1s0b0n0", "x9c1s1b0n0", "x9c1s2b0n0"}; !reflect.DeepEqual(img.Hosts, want) {
+		t.Errorf("Hosts %v, expected %v", img.Hosts, want)
+	}
+
+	// Changing an entry's kernel moves its reference
+	Store(bssTypes.BootParams{Hosts: []string{"x9c1s1b0n0"}, Kernel: k2, Params: "quiet"})
+	if img = getCatalogImage(t, kernelImageType, k1); img.RefCount != 2 {
+		t.Errorf("Old kernel has %d references: %v", img.RefCount, img.References)
+	}
+	if img = getCatalogImage(t, kernelImageType, k2); img.RefCount != 1 {
+		t.Errorf("New kernel has %d references: %v", img.RefCount, img.References)
+	}
+	Remove(bssTypes.BootParams{Hosts: []string{"x9c1s1b0n0"}})
+	if img = getCatalogImage(t, kernelImageType, k2); img.RefCount != 0 {
+		t.Errorf("Kernel of a removed host has %d references: %v", img.RefCount, img.References)
+	}
+
+	// A lost reference is restored by a rebuild
+	key := makeImageKey(kernelImageType, k1)
+	kvstore.Delete(imageRefKey(key, paramsPfx+"x9c1s0b0n0"))
+	if n, err := rebuildImageRefs(); err != nil || n < 1 {
+		t.Errorf("rebuildImageRefs() = %d, %v", n, err)
+	}
+	if refs, _ := imageRefs(key); len(refs) != 2 {
+		t.Errorf("References after rebuild %v", refs)
+	}
+}
+
+func TestImagesAPI(t *testing.T) {
+	sum := strings.Repeat("ab", 32)
+	reg := bssTypes.Image{Type: kernelImageType, Path: "http://images/catalog/kernel", Name: "cos",
+		Version: "2.5", Arch: "x86_64", Size: 1024, Sha256: sum, Labels: map[string]string{"release": "stable"}}
+	rr := serveRequest(t, images, http.MethodPost, "/images", reg)
+	var img bssTypes.Image
+	if rr.Code != http.StatusCreated || json.Unmarshal(rr.Body.Bytes(), &img) != nil || img.ID == "" {
+		t.Fatalf("POST returned %d: %s", rr.Code, rr.Body)
+	}
+	if img.Created == "" || img.Sha256 != sum || img.Labels["release"] != "stable" {
+		t.Errorf("Unexpected image %+v", img)
+	}
+	if rr = serveRequest(t, images, http.MethodPost, "/images", reg); rr.Code != http.StatusConflict {
+		t.Errorf("Second POST returned %d", rr.Code)
+	}
+	bad := reg
+	bad.Path, bad.Sha256 = "http://images/catalog/other", "abc"
+	if rr = serveRequest(t, images, http.MethodPost, "/images", bad); rr.Code != http.StatusBadRequest {
+		t.Errorf("POST with a bad checksum returned %d", rr.Code)
+	}
+	bad.Sha256, bad.Type = "", "rootfs"
+	if rr = serveRequest(t, images, http.MethodPost, "/images", bad); rr.Code != http.StatusBadRequest {
+		t.Errorf("POST with a bad type returned %d", rr.Code)
+	}
+
+	// PUT replaces the metadata but keeps the creation time
+	upd := bssTypes.Image{Name: "cos", Version: "2.6", Labels: map[string]string{"release": "beta"}}
+	rr = serveRequest(t, images, http.MethodPut, "/images/kernel/"+img.ID, upd)
+	var updated bssTypes.Image
+	json.Unmarshal(rr.Body.Bytes(), &updated)
+	if rr.Code != http.StatusOK || updated.Version != "2.6" || updated.Arch != "" || updated.Created != img.Created {
+		t.Errorf("PUT returned %d: %s", rr.Code, rr.Body)
+	}
+
+	var il []bssTypes.Image
+	rr = serveRequest(t, images, http.MethodGet, "/images/kernel?label=release=beta&name=cos", nil)
+	if json.Unmarshal(rr.Body.Bytes(), &il); len(il) != 1 || il[0].ID != img.ID {
+		t.Errorf("GET with filters returned %s", rr.Body)
+	}
+
+	// An image in use is only deleted with force, which clears its uses
+	Store(bssTypes.BootParams{Hosts: []string{"x9c1s3b0n0"}, Kernel: reg.Path, Params: "quiet"})
+	defer deleteHost("x9c1s3b0n0")
+	if rr = serveRequest(t, images, http.MethodDelete, "/images/kernel/"+img.ID, nil); rr.Code != http.StatusConflict {
+		t.Errorf("DELETE of an image in use returned %d", rr.Code)
+	}
+	if rr = serveRequest(t, images, http.MethodDelete, "/images/kernel/"+img.ID+"?force=true", nil); rr.Code != http.StatusNoContent {
+		t.Errorf("DELETE with force returned %d: %s", rr.Code, rr.Body)
+	}
+	if bds, _ := lookupHost("x9c1s3b0n0"); bds.Kernel != "" {
+		t.Errorf("Host still references the deleted kernel %s", bds.Kernel)
+	}
+	if rr = serveRequest(t, images, http.MethodGet, "/images/kernel/"+img.ID, nil); rr.Code != http.StatusNotFound {
+		t.Errorf("GET of a deleted image returned %d", rr.Code)
+	}
+}
+
+func TestImageGC(t *testing.T) {
+	used, unused := "http://images/gc/initrd-used", "http://images/gc/initrd-unused"
+	cleanupImages(t, initrdImageType, used, unused)
+	Store(bssTypes.BootParams{Hosts: []string{"x9c1s4b0n0"}, Initrd: used, Params: "quiet"})
+	defer deleteHost("x9c1s4b0n0")
+	if rr := serveRequest(t, images, http.MethodPost, "/images/initrd", bssTypes.Image{Path: unused}); rr.Code != http.StatusCreated {
+		t.Fatalf("POST returned %d: %s", rr.Code, rr.Body)
+	}
+
+	collected := func(query string) map[string]bool {
+		rr := serveRequest(t, images, http.MethodPost, "/images/gc"+query, nil)
+		var il []bssTypes.Image
+		if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &il) != nil {
+			t.Fatalf("POST gc%s returned %d: %s", query, rr.Code, rr.Body)
+		}
+		ret := map[string]bool{}
+		for _, img := range il {
+			ret[img.Path] = true
+		}
+		return ret
+	}
+	if c := collected("?older-than=1h"); c[unused] {
+		t.Errorf("A new image was collected")
+	}
+	if c := collected("?dry-run=true"); !c[unused] || c[used] {
+		t.Errorf("Dry run would collect %v", c)
+	}
+	if imageFind(unused, initrdImageType) == "" {
+		t.Errorf("Dry run deleted the image")
+	}
+	if c := collected(""); !c[unused] || c[used] {
+		t.Errorf("Collected %v", c)
+	}
+	if imageFind(unused, initrdImageType) != "" || imageFind(used, initrdImageType) == "" {
+		t.Errorf("Wrong images deleted")
+	}
+	if rr := serveRequest(t, images, http.MethodPost, "/images/gc?older-than=soon", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("POST gc with a bad duration returned %d", rr.Code)
+	}
+
+	// A request which references an image deleted in the meantime fails
+	op, _ := imageOp(used, initrdImageType)
+	removeImage(used, initrdImageType, changeRequest{})
+	target := paramTarget{"x9c1s4b0n0", bssTypes.HostStored, storeOp(paramsPfx+"x9c1s4b0n0", BootDataStore{Initrd: op.key})}
+	if _, err := applyTargets([]paramTarget{target}, []kvOp{op}, changeRequest{}); err == nil {
+		t.Errorf("Stored a reference to a deleted image")
+	}
+}
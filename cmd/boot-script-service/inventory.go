@@ -0,0 +1,555 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+/*
+ * Inventory providers
+ *
+ * The components BSS boots come from an inventory provider, selected by the
+ * --hsm URL:
+ *
+ *   http://... or https://...  HSM
+ *   file:/path/inventory.yaml  An inventory file, YAML, JSON or CSV
+ *   etcd:                      An inventory edited through /inventory
+ *   mem:                       A canned inventory, for testing
+ *
+ * The file and etcd inventories are for systems without HSM.  An inventory
+ * file is a list of components, or a document with a "components" list, in
+ * the form of bssTypes.InventoryComponent.  A CSV file has a header row
+ * naming the columns, with multiple MACs, IPs or groups separated by ';'.
+ * The file is checked for changes every BSS_INVENTORY_POLL_INTERVAL seconds
+ * and reloaded, and if it can't be read the inventory read before is kept.
+ *
+ * /inventory lists the components of any provider, and can add, replace and
+ * remove those of the etcd inventory.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+	"github.com/Cray-HPE/hms-smd/pkg/sm"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const inventoryPfx = "/inventory/"
+
+var inventoryPollInterval = 5 // Seconds
+
+// A source of the components BSS boots.  fetch returns nil, and no error, if
+// the provider has nothing new.
+type inventoryProvider interface {
+	name() string
+	fetch() (*SMData, error)
+}
+
+var smInventory inventoryProvider
+
+type hsmInventory struct{}
+
+func (hsmInventory) name() string { return bssTypes.HSMSourceHSM }
+
+func (hsmInventory) fetch() (*SMData, error) {
+	if data := getStateFromHSM(); data != nil {
+		return data, nil
+	}
+	return nil, errors.New("HSM did not return its state")
+}
+
+// The canned inventory is loaded once, by SmOpen().
+type memInventory struct{}
+
+func (memInventory) name() string { return bssTypes.HSMSourceMem }
+
+func (memInventory) fetch() (*SMData, error) { return nil, nil }
+
+// Check an inventory component, normalizing its fields.
+func checkInventoryComponent(c *bssTypes.InventoryComponent) error {
+	if c.ID == "" || strings.ContainsAny(c.ID, "/ \t\n") {
+		return fmt.Errorf("invalid component ID '%s'", c.ID)
+	}
+	if id := base.VerifyNormalizeCompID(c.ID); id != "" {
+		c.ID = id
+	}
+	if c.NID < 0 {
+		return fmt.Errorf("%s: invalid NID %d", c.ID, c.NID)
+	}
+	for i, m := range c.MAC {
+		c.MAC[i] = normalizeMAC(m)
+		if _, err := net.ParseMAC(c.MAC[i]); err != nil {
+			return fmt.Errorf("%s: invalid MAC '%s'", c.ID, m)
+		}
+	}
+	for _, ip := range c.IP {
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("%s: invalid IP address '%s'", c.ID, ip)
+		}
+	}
+	if c.Arch != "" {
+		c.Arch = normalizeArch(c.Arch)
+	}
+	if c.State == "" {
+		c.State = "Ready"
+	}
+	return nil
+}
+
+// The state BSS uses for the components of an inventory.
+func inventoryState(comps []bssTypes.InventoryComponent) *SMData {
+	state := &SMData{
+		Components: make([]SMComponent, 0, len(comps)),
+		IPAddrs:    make(map[string]sm.CompEthInterfaceV2),
+	}
+	for _, c := range comps {
+		sc := SMComponent{
+			Component: base.Component{ID: c.ID, Type: "Node", State: c.State,
+				Role: c.Role, SubRole: c.SubRole, Arch: c.Arch},
+			Fqdn:            c.FQDN,
+			Mac:             c.MAC,
+			EndpointEnabled: c.Enabled == nil || *c.Enabled,
+			Groups:          c.Groups,
+		}
+		if c.NID > 0 {
+			sc.NID = json.Number(strconv.Itoa(c.NID))
+		}
+		state.Components = append(state.Components, sc)
+		mac := ""
+		if len(c.MAC) > 0 {
+			mac = c.MAC[0]
+		}
+		for _, ip := range c.IP {
+			state.IPAddrs[ip] = sm.CompEthInterfaceV2{
+				ID:      strings.ReplaceAll(mac, ":", ""),
+				MACAddr: mac,
+				CompID:  c.ID,
+				IPAddrs: []sm.IPAddressMapping{{IPAddr: ip}},
+			}
+		}
+	}
+	return state
+}
+
+// The inventory components of a state, for /inventory.
+func inventoryComponents(state *SMData) []bssTypes.InventoryComponent {
+	ips := make(map[string][]string)
+	for ip, e := range state.IPAddrs {
+		ips[e.CompID] = append(ips[e.CompID], ip)
+	}
+	comps := make([]bssTypes.InventoryComponent, 0, len(state.Components))
+	for _, sc := range state.Components {
+		c := bssTypes.InventoryComponent{
+			ID:      sc.ID,
+			Role:    sc.Role,
+			SubRole: sc.SubRole,
+			Arch:    sc.Arch,
+			State:   sc.State,
+			FQDN:    sc.Fqdn,
+			MAC:     sc.Mac,
+			IP:      ips[sc.ID],
+			Groups:  sc.Groups,
+		}
+		if nid, err := sc.NID.Int64(); err == nil {
+			c.NID = int(nid)
+		}
+		if !sc.EndpointEnabled {
+			c.Enabled = new(bool)
+		}
+		sort.Strings(c.IP)
+		comps = append(comps, c)
+	}
+	return comps
+}
+
+// yaml.v2 decodes mappings with interface{} keys, which encoding/json
+// cannot handle, so convert them to string keys.
+func jsonCompatible(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[fmt.Sprint(k)] = jsonCompatible(val)
+		}
+		return m
+	case []interface{}:
+		for i := range t {
+			t[i] = jsonCompatible(t[i])
+		}
+	}
+	return v
+}
+
+// Parse an inventory file, YAML or JSON, or CSV if ext is .csv.
+func parseInventory(data []byte, ext string) ([]bssTypes.InventoryComponent, error) {
+	var comps []bssTypes.InventoryComponent
+	if strings.EqualFold(ext, ".csv") {
+		var err error
+		if comps, err = parseInventoryCSV(data); err != nil {
+			return nil, err
+		}
+	} else {
+		if !json.Valid(data) {
+			var generic interface{}
+			if err := yaml.Unmarshal(data, &generic); err != nil {
+				return nil, err
+			}
+			j, err := json.Marshal(jsonCompatible(generic))
+			if err != nil {
+				return nil, err
+			}
+			data = j
+		}
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+			if err := json.Unmarshal(data, &comps); err != nil {
+				return nil, err
+			}
+		} else {
+			var inv bssTypes.Inventory
+			if err := json.Unmarshal(data, &inv); err != nil {
+				return nil, err
+			}
+			comps = inv.Components
+		}
+	}
+	seen := make(map[string]bool, len(comps))
+	for i := range comps {
+		if err := checkInventoryComponent(&comps[i]); err != nil {
+			return nil, err
+		}
+		if seen[comps[i].ID] {
+			return nil, fmt.Errorf("%s is listed more than once", comps[i].ID)
+		}
+		seen[comps[i].ID] = true
+	}
+	return comps, nil
+}
+
+func splitInventoryList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ';' || r == ' ' })
+}
+
+func parseInventoryCSV(data []byte) ([]bssTypes.InventoryComponent, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	header := records[0]
+	for i, col := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(col))
+		switch header[i] {
+		case "id", "nid", "role", "subrole", "arch", "state", "enabled", "fqdn", "mac", "ip", "groups":
+		default:
+			return nil, fmt.Errorf("unknown column '%s'", col)
+		}
+	}
+	comps := make([]bssTypes.InventoryComponent, 0, len(records)-1)
+	for n, rec := range records[1:] {
+		var c bssTypes.InventoryComponent
+		for i, col := range header {
+			v := strings.TrimSpace(rec[i])
+			if v == "" {
+				continue
+			}
+			switch col {
+			case "id":
+				c.ID = v
+			case "nid":
+				if c.NID, err = strconv.Atoi(v); err != nil {
+					return nil, fmt.Errorf("line %d: invalid NID '%s'", n+2, v)
+				}
+			case "role":
+				c.Role = v
+			case "subrole":
+				c.SubRole = v
+			case "arch":
+				c.Arch = v
+			case "state":
+				c.State = v
+			case "enabled":
+				enabled, err := strconv.ParseBool(v)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid enabled '%s'", n+2, v)
+				}
+				c.Enabled = &enabled
+			case "fqdn":
+				c.FQDN = v
+			case "mac":
+				c.MAC = splitInventoryList(v)
+			case "ip":
+				c.IP = splitInventoryList(v)
+			case "groups":
+				c.Groups = splitInventoryList(v)
+			}
+		}
+		comps = append(comps, c)
+	}
+	return comps, nil
+}
+
+// An inventory file, reloaded when it changes.
+type fileInventory struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time // Of the file last read
+	size    int64
+}
+
+func newFileInventory(path string) *fileInventory {
+	return &fileInventory{path: path}
+}
+
+func (f *fileInventory) name() string { return bssTypes.HSMSourceFile }
+
+func (f *fileInventory) fetch() (*SMData, error) {
+	st, err := os.Stat(f.path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	// Remember the file even if it is bad, so it isn't reloaded until it
+	// changes again.
+	f.mu.Lock()
+	f.modTime, f.size = st.ModTime(), st.Size()
+	f.mu.Unlock()
+	comps, err := parseInventory(data, filepath.Ext(f.path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", f.path, err)
+	}
+	return inventoryState(comps), nil
+}
+
+// Reload the file if it has changed since it was last read, returning
+// whether it was.
+func (f *fileInventory) check() bool {
+	st, err := os.Stat(f.path)
+	if err != nil {
+		return false
+	}
+	f.mu.Lock()
+	changed := !st.ModTime().Equal(f.modTime) || st.Size() != f.size
+	f.mu.Unlock()
+	if !changed {
+		return false
+	}
+	if err = reloadInventory(); err != nil {
+		log.Printf("WARNING: Keeping the previous inventory: %s", err)
+		return false
+	}
+	log.Printf("Reloaded the inventory file %s", f.path)
+	return true
+}
+
+func (f *fileInventory) watch() {
+	for {
+		time.Sleep(time.Duration(inventoryPollInterval) * time.Second)
+		f.check()
+	}
+}
+
+// The inventory edited through /inventory.
+type etcdInventory struct{}
+
+func (etcdInventory) name() string { return bssTypes.HSMSourceEtcd }
+
+func (etcdInventory) fetch() (*SMData, error) {
+	comps, err := getInventory()
+	if err != nil {
+		return nil, err
+	}
+	return inventoryState(comps), nil
+}
+
+// The components of the etcd inventory, sorted by ID.
+func getInventory() ([]bssTypes.InventoryComponent, error) {
+	kvl, err := kvstore.GetRange(inventoryPfx, inventoryPfx+"~")
+	if err != nil {
+		return nil, err
+	}
+	comps := make([]bssTypes.InventoryComponent, 0, len(kvl))
+	for _, kv := range kvl {
+		var c bssTypes.InventoryComponent
+		if err := json.Unmarshal([]byte(kv.Value), &c); err != nil {
+			log.Printf("WARNING: Skipping bad inventory component %s: %s", kv.Key, err)
+			continue
+		}
+		comps = append(comps, c)
+	}
+	sort.Slice(comps, func(i, j int) bool { return comps[i].ID < comps[j].ID })
+	return comps, nil
+}
+
+func getInventoryComponent(id string) (bssTypes.InventoryComponent, bool, error) {
+	var c bssTypes.InventoryComponent
+	val, exists, err := kvstore.Get(inventoryPfx + id)
+	if err == nil && exists {
+		err = json.Unmarshal([]byte(val), &c)
+	}
+	return c, exists, err
+}
+
+// Replace the state with the one the inventory has now.
+func reloadInventory() error {
+	data, err := smInventory.fetch()
+	if err != nil || data == nil {
+		return err
+	}
+	smMutex.Lock()
+	smData = data
+	smDataIndex = makeSmIndex(data)
+	smTimeStamp = time.Now().Unix()
+	smMutex.Unlock()
+	return nil
+}
+
+// Have every BSS instance reload the etcd inventory after it was changed.
+func inventoryChanged() {
+	if err := recordHSMChange(nil); err != nil {
+		log.Printf("Failed to record the inventory change: %s", err)
+	}
+	if err := reloadInventory(); err != nil {
+		log.Printf("Failed to reload the inventory: %s", err)
+	}
+}
+
+func inventoryGetAPI(w http.ResponseWriter, r *http.Request, id string) {
+	debugf("inventoryGetAPI(): Received request %v\n", r.URL)
+	if id == "" {
+		inv := bssTypes.Inventory{Components: inventoryComponents(getState())}
+		if smInventory != nil {
+			inv.Source = smInventory.name()
+		}
+		sendJSON(w, http.StatusOK, inv)
+		return
+	}
+	comp, ok := FindSMCompByName(id)
+	if !ok {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound,
+			fmt.Sprintf("Component %s is not in the inventory", id))
+		return
+	}
+	state := &SMData{Components: []SMComponent{comp}, IPAddrs: make(map[string]sm.CompEthInterfaceV2)}
+	for ip, e := range getState().IPAddrs {
+		if e.CompID == comp.ID {
+			state.IPAddrs[ip] = e
+		}
+	}
+	sendJSON(w, http.StatusOK, inventoryComponents(state)[0])
+}
+
+// Store a component of the etcd inventory.  POST adds a new one, PUT adds or
+// replaces one.
+func inventoryStoreAPI(w http.ResponseWriter, r *http.Request, id string) {
+	debugf("inventoryStoreAPI(): Received request %v\n", r.URL)
+	var c bssTypes.InventoryComponent
+	body, err := ioutil.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(body, &c)
+	}
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+			fmt.Sprintf("Bad Request: %s", err))
+		return
+	}
+	if id != "" {
+		if c.ID != "" && c.ID != id {
+			base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+				fmt.Sprintf("Component ID '%s' does not match the path", c.ID))
+			return
+		}
+		c.ID = id
+	}
+	if err = checkInventoryComponent(&c); err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+			fmt.Sprintf("Invalid inventory component: %s", err))
+		return
+	}
+
+	kvMutex.Lock()
+	defer kvMutex.Unlock()
+	if r.Method == http.MethodPost {
+		if _, exists, _ := getInventoryComponent(c.ID); exists {
+			base.SendProblemDetailsGeneric(w, http.StatusConflict,
+				fmt.Sprintf("Component %s is already in the inventory", c.ID))
+			return
+		}
+	}
+	if err = storeData(inventoryPfx+c.ID, c); err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Printf("Stored inventory component %s", c.ID)
+	inventoryChanged()
+	status := http.StatusOK
+	if r.Method == http.MethodPost {
+		status = http.StatusCreated
+	}
+	sendJSON(w, status, c)
+}
+
+func inventoryDeleteAPI(w http.ResponseWriter, r *http.Request, id string) {
+	debugf("inventoryDeleteAPI(): Received request %v\n", r.URL)
+	kvMutex.Lock()
+	defer kvMutex.Unlock()
+	if n := base.VerifyNormalizeCompID(id); n != "" {
+		id = n
+	}
+	_, exists, err := getInventoryComponent(id)
+	if err == nil && !exists {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound,
+			fmt.Sprintf("Component %s is not in the inventory", id))
+		return
+	}
+	if err == nil {
+		err = kvstore.Delete(inventoryPfx + id)
+	}
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+			fmt.Sprintf("Failed to delete inventory component %s: %s", id, err))
+		return
+	}
+	log.Printf("Deleted inventory component %s", id)
+	inventoryChanged()
+	w.WriteHeader(http.StatusNoContent)
+}
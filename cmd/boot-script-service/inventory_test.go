@@ -0,0 +1,210 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+const inventoryYAML = `
+components:
+- id: X9C7S0B0N0
+  nid: 980
+  role: Compute
+  arch: aarch64
+  mac: [A4-BF-01-00-98-00]
+  ip: [10.98.0.1]
+  groups: [blue]
+- id: lab-node-1
+  enabled: false
+`
+
+func TestParseInventory(t *testing.T) {
+	comps, err := parseInventory([]byte(inventoryYAML), ".yaml")
+	if err != nil || len(comps) != 2 {
+		t.Fatalf("parseInventory(YAML) = %+v, %v", comps, err)
+	}
+	c := comps[0]
+	if c.ID != "x9c7s0b0n0" || c.NID != 980 || c.Arch != archArm64 || c.MAC[0] != "a4:bf:01:00:98:00" ||
+		c.State != "Ready" || c.Groups[0] != "blue" {
+		t.Errorf("Parsed %+v", c)
+	}
+	if comps[1].Enabled == nil || *comps[1].Enabled {
+		t.Errorf("Parsed %+v", comps[1])
+	}
+
+	comps, err = parseInventory([]byte(`[{"id": "x9c7s1b0n0", "nid": 981}]`), ".json")
+	if err != nil || len(comps) != 1 || comps[0].NID != 981 {
+		t.Errorf("parseInventory(JSON) = %+v, %v", comps, err)
+	}
+
+	csvData := "# Lab nodes\nid,nid,role,mac,ip,enabled\nx9c7s2b0n0,982,Compute,a4:bf:01:00:98:02;a4:bf:01:00:98:03,10.98.0.2,true\n"
+	comps, err = parseInventory([]byte(csvData), ".csv")
+	if err != nil || len(comps) != 1 || len(comps[0].MAC) != 2 || comps[0].IP[0] != "10.98.0.2" || !*comps[0].Enabled {
+		t.Errorf("parseInventory(CSV) = %+v, %v", comps, err)
+	}
+
+	bad := map[string]string{
+		".json": `[{"id": "x9c7s0b0n0"}, {"id": "x9c7s0b0n0"}]`,
+		".yaml": "- id: x9c7s0b0n0\n  mac: [not-a-mac]\n",
+		".yml":  "- id: x9c7s0b0n0\n  ip: [10.98]\n",
+		".csv":  "id,color\nx9c7s0b0n0,blue\n",
+	}
+	for ext, data := range bad {
+		if _, err := parseInventory([]byte(data), ext); err == nil {
+			t.Errorf("parseInventory(%q) succeeded", data)
+		}
+	}
+}
+
+func TestInventoryState(t *testing.T) {
+	comps, _ := parseInventory([]byte(inventoryYAML), ".yaml")
+	state := inventoryState(comps)
+	if len(state.Components) != 2 || state.Components[0].NID.String() != "980" ||
+		state.Components[1].EndpointEnabled || state.IPAddrs["10.98.0.1"].CompID != "x9c7s0b0n0" {
+		t.Errorf("inventoryState() = %+v", state)
+	}
+	back := inventoryComponents(state)
+	if back[0].NID != 980 || back[0].IP[0] != "10.98.0.1" || back[1].Enabled == nil {
+		t.Errorf("inventoryComponents() = %+v", back)
+	}
+}
+
+// Use an inventory provider for the rest of a test.
+func withInventory(t *testing.T, inv inventoryProvider) {
+	withComponents(t)
+	saved, savedTS := smInventory, smTimeStamp
+	smInventory = inv
+	t.Cleanup(func() {
+		smInventory, smTimeStamp = saved, savedTS
+	})
+}
+
+func TestFileInventory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.yaml")
+	ioutil.WriteFile(path, []byte(inventoryYAML), 0644)
+	f := newFileInventory(path)
+	withInventory(t, f)
+	if err := reloadInventory(); err != nil {
+		t.Fatalf("reloadInventory() failed: %v", err)
+	}
+	if c, ok := FindSMCompByMAC("a4:bf:01:00:98:00"); !ok || c.ID != "x9c7s0b0n0" {
+		t.Errorf("FindSMCompByMAC() = %+v, %v", c, ok)
+	}
+	if _, ok := FindSMCompByName("x0c0s0b0n0"); ok {
+		t.Errorf("Components not in the inventory file are still known")
+	}
+	if f.check() {
+		t.Errorf("The inventory was reloaded without changing")
+	}
+
+	ioutil.WriteFile(path, []byte(strings.Replace(inventoryYAML, "id: lab-node-1", "id: x9c7s3b0n0\n  nid: 983", 1)), 0644)
+	if !f.check() {
+		t.Errorf("The changed inventory was not reloaded")
+	}
+	if _, ok := FindSMCompByName("x9c7s3b0n0"); !ok {
+		t.Errorf("Component added to the inventory file is not known")
+	}
+
+	ioutil.WriteFile(path, []byte("components: [{id: x9c7s0b0n0, nid: -1}]\n"), 0644)
+	if f.check() {
+		t.Errorf("A bad inventory was loaded")
+	}
+	if _, ok := FindSMCompByName("x9c7s3b0n0"); !ok {
+		t.Errorf("The previous inventory was not kept")
+	}
+}
+
+func TestInventoryAPI(t *testing.T) {
+	if rr := serveRequest(t, inventory, http.MethodPut, "/inventory/x9c7s0b0n0", `{}`); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT to the mem inventory returned %d", rr.Code)
+	}
+
+	withInventory(t, etcdInventory{})
+	saved, haveTS, _ := kvstore.Get(UpdateTimestampKey)
+	defer func() {
+		if haveTS {
+			kvstore.Store(UpdateTimestampKey, saved)
+		} else {
+			kvstore.Delete(UpdateTimestampKey)
+		}
+		comps, _ := getInventory()
+		for _, c := range comps {
+			kvstore.Delete(inventoryPfx + c.ID)
+		}
+	}()
+
+	body := `{"id": "x9c7s0b0n0", "nid": 980, "mac": ["a4:bf:01:00:98:00"], "ip": ["10.98.0.1"]}`
+	if rr := serveRequest(t, inventory, http.MethodPost, "/inventory", body); rr.Code != http.StatusCreated {
+		t.Fatalf("POST returned %d: %s", rr.Code, rr.Body)
+	}
+	if rr := serveRequest(t, inventory, http.MethodPost, "/inventory", body); rr.Code != http.StatusConflict {
+		t.Errorf("POST of an existing component returned %d", rr.Code)
+	}
+	if rr := serveRequest(t, inventory, http.MethodPut, "/inventory/x9c7s1b0n0", body); rr.Code != http.StatusBadRequest {
+		t.Errorf("PUT with a mismatched ID returned %d", rr.Code)
+	}
+	if rr := serveRequest(t, inventory, http.MethodPut, "/inventory/x9c7s1b0n0", `{"nid": 981, "role": "Compute"}`); rr.Code != http.StatusOK {
+		t.Errorf("PUT returned %d: %s", rr.Code, rr.Body)
+	}
+	if _, exists, _ := kvstore.Get(UpdateTimestampKey); !exists {
+		t.Errorf("Other instances were not told of the change")
+	}
+
+	if c, ok := FindSMCompByNid(981); !ok || c.ID != "x9c7s1b0n0" || c.Role != "Compute" {
+		t.Errorf("FindSMCompByNid(981) = %+v, %v", c, ok)
+	}
+	if id, ok := FindXnameByIP("10.98.0.1"); !ok || id != "x9c7s0b0n0" {
+		t.Errorf("FindXnameByIP() = %s, %v", id, ok)
+	}
+	rr := serveRequest(t, inventory, http.MethodGet, "/inventory", nil)
+	var inv bssTypes.Inventory
+	if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &inv) != nil {
+		t.Fatalf("GET returned %d: %s", rr.Code, rr.Body)
+	}
+	if inv.Source != bssTypes.HSMSourceEtcd || len(inv.Components) != 2 {
+		t.Errorf("GET returned %s", rr.Body)
+	}
+	rr = serveRequest(t, inventory, http.MethodGet, "/inventory/x9c7s0b0n0", nil)
+	var c bssTypes.InventoryComponent
+	if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &c) != nil || len(c.IP) != 1 {
+		t.Errorf("GET of a component returned %d: %s", rr.Code, rr.Body)
+	}
+
+	if rr := serveRequest(t, inventory, http.MethodDelete, "/inventory/x9c7s0b0n0", nil); rr.Code != http.StatusNoContent {
+		t.Errorf("DELETE returned %d: %s", rr.Code, rr.Body)
+	}
+	if rr := serveRequest(t, inventory, http.MethodDelete, "/inventory/x9c7s0b0n0", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("DELETE of a missing component returned %d", rr.Code)
+	}
+	if rr := serveRequest(t, inventory, http.MethodGet, "/inventory/x9c7s0b0n0", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("GET of a deleted component returned %d", rr.Code)
+	}
+}
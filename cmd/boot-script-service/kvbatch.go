@@ -0,0 +1,382 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+/*
+ * Atomic batches of KV store writes
+ *
+ * Requests for many hosts must change all of them or none.  When the KV
+ * store is etcd, a batch which fits in one etcd transaction is applied with
+ * it.  Larger batches, and KV stores without transactions, are applied in
+ * chunks while holding the distributed lock, and if a chunk fails the keys
+ * already written are put back the way they were.  The lock only keeps
+ * chunked batches apart.  Batches applied in one transaction don't wait for
+ * it, so they may see some of the chunks of another batch, and a change they
+ * make to its keys is lost if it is rolled back.
+ *
+ * Writes of boot parameters and boot profiles also update the image
+ * reference index (see images.go) in the same batch.
+ *
+ * A write may be guarded by the value its key had when the request was
+ * checked, so that a batch fails with errConflict rather than overwrite a
+ * change made in the meantime.  With etcd, a guard read with getRev() compares
+ * the modification revision of the key instead.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+	hmetcd "github.com/Cray-HPE/hms-hmetcd"
+	"github.com/coreos/etcd/clientv3"
+)
+
+// The most operations etcd accepts in one transaction, by default.
+var kvTxnMaxOps = 128
+
+// A single write of a batch.
+type kvOp struct {
+	key       string
+	value     string
+	delete    bool
+	check     bool // Only check the guard, don't write
+	noHistory bool // Not recorded in the change history
+
+	// If guarded, the key must still have the value prev, or revision
+	// prevRev if it isn't 0, or not exist if !prevExists.
+	guarded    bool
+	prev       string
+	prevRev    int64
+	prevExists bool
+}
+
+var errConflict = errors.New("changed by another request")
+
+// A KV store which can apply several writes in one transaction.
+type kvTxnStore interface {
+	Txn(ops []kvOp) error
+}
+
+// A KV store which has the modification revisions of keys.
+type kvRevStore interface {
+	GetRev(key string) (string, int64, bool, error)
+}
+
+// Get the value of a key, and its modification revision for a guard.  The
+// revision is 0 if the KV store doesn't have them.
+func getRev(key string) (string, int64, bool, error) {
+	if rs, ok := kvstore.(kvRevStore); ok {
+		return rs.GetRev(key)
+	}
+	val, exists, err := kvstore.Get(key)
+	return val, 0, exists, err
+}
+
+// Guard a write by the value of its key, as read by getRev().
+func guardOp(op kvOp, prev string, rev int64, exists bool) kvOp {
+	op.guarded, op.prev, op.prevRev, op.prevExists = true, prev, rev, exists
+	return op
+}
+
+// The etcd KV store, with transactions.  hmetcd.Kvi only has single key
+// transactions and keeps its etcd client to itself, so a second client to the
+// same endpoint is used for them.  Close() closes both, when the service
+// shuts down.
+type etcdTxnStore struct {
+	hmetcd.Kvi
+	client *clientv3.Client
+}
+
+func newEtcdTxnStore(kv hmetcd.Kvi, endpoint string) (hmetcd.Kvi, error) {
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{endpoint},
+		DialTimeout: 10 * time.Second,
+	})
+	if err != nil {
+		return kv, err
+	}
+	return etcdTxnStore{kv, cli}, nil
+}
+
+func (e etcdTxnStore) Txn(ops []kvOp) error {
+	var cmps []clientv3.Cmp
+	var eops []clientv3.Op
+	for _, op := range ops {
+		switch {
+		case op.guarded && op.prevExists && op.prevRev > 0:
+			cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(op.key), "=", op.prevRev))
+		case op.guarded && op.prevExists:
+			cmps = append(cmps, clientv3.Compare(clientv3.Value(op.key), "=", op.prev))
+		case op.guarded:
+			cmps = append(cmps, clientv3.Compare(clientv3.CreateRevision(op.key), "=", 0))
+		}
+		switch {
+		case op.check:
+		case op.delete:
+			eops = append(eops, clientv3.OpDelete(op.key))
+		default:
+			eops = append(eops, clientv3.OpPut(op.key, op.value))
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	rsp, err := clientv3.NewKV(e.client).Txn(ctx).If(cmps...).Then(eops...).Commit()
+	if err == nil && !rsp.Succeeded {
+		err = errConflict
+	}
+	return err
+}
+
+func (e etcdTxnStore) GetRev(key string) (string, int64, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	rsp, err := e.client.Get(ctx, key)
+	if err != nil || len(rsp.Kvs) == 0 {
+		return "", 0, false, err
+	}
+	return string(rsp.Kvs[0].Value), rsp.Kvs[0].ModRevision, true, nil
+}
+
+func (e etcdTxnStore) Close() error {
+	e.client.Close()
+	return e.Kvi.Close()
+}
+
+// Apply writes, as one transaction if the KV store has them.  The number of
+// writes made is returned along with any error.
+func applyOps(ops []kvOp) (int, error) {
+	if ts, ok := kvstore.(kvTxnStore); ok {
+		if err := ts.Txn(ops); err != nil {
+			return 0, err
+		}
+		return len(ops), nil
+	}
+	for i, op := range ops {
+		var err error
+		switch {
+		case op.check:
+		case op.delete:
+			err = kvstore.Delete(op.key)
+		default:
+			err = kvstore.Store(op.key, op.value)
+		}
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(ops), nil
+}
+
+// Put keys back to the values saved before a failed batch.
+func restoreOps(prev []kvOp) {
+	for start := 0; start < len(prev); start += kvTxnMaxOps {
+		end := start + kvTxnMaxOps
+		if end > len(prev) {
+			end = len(prev)
+		}
+		if n, err := applyOps(prev[start:end]); err != nil {
+			log.Printf("ERROR: rollback failed, %s may be inconsistent: %s", prev[start+n].key, err)
+		}
+	}
+}
+
+// Apply a batch of writes, all or nothing.  If a write fails, the key which
+// failed is returned along with the error.  The key is empty when the batch
+// failed as a whole.
+func applyBatch(ops []kvOp) (string, error) {
+	// A transaction may only change each key once, so keep the last write
+	// of each key.
+	last := make(map[string]int, len(ops))
+	for i, op := range ops {
+		if j, ok := last[op.key]; !ok || !op.check || ops[j].check {
+			last[op.key] = i
+		}
+	}
+	batch := make([]kvOp, 0, len(last))
+	for i, op := range ops {
+		if last[op.key] == i {
+			batch = append(batch, op)
+		}
+	}
+	if len(batch) == 0 {
+		return "", nil
+	}
+	batch, err := withImageRefs(batch)
+	if err != nil {
+		return "", err
+	}
+	_, txn := kvstore.(kvTxnStore)
+	if txn && len(batch) <= kvTxnMaxOps {
+		_, err := applyOps(batch)
+		return "", err
+	}
+
+	if err := kvstore.DistTimedLock(10); err != nil {
+		return "", fmt.Errorf("Cannot lock the KV store: %s", err)
+	}
+	defer kvstore.DistUnlock()
+	prev := make([]kvOp, len(batch))
+	for i, op := range batch {
+		val, exists, err := kvstore.Get(op.key)
+		if err != nil {
+			return op.key, err
+		}
+		if op.guarded && (exists != op.prevExists || val != op.prev) {
+			return op.key, errConflict
+		}
+		prev[i] = kvOp{key: op.key, value: val, delete: !exists, check: op.check}
+	}
+	for start := 0; start < len(batch); start += kvTxnMaxOps {
+		end := start + kvTxnMaxOps
+		if end > len(batch) {
+			end = len(batch)
+		}
+		n, err := applyOps(batch[start:end])
+		if err != nil {
+			failed := ""
+			if !txn {
+				failed = batch[start+n].key
+			}
+			log.Printf("Batch of %d writes failed after %d, rolling back: %s", len(batch), start+n, err)
+			restoreOps(prev[:start+n])
+			return failed, err
+		}
+	}
+	return "", nil
+}
+
+// A boot parameters entry changed by a request.
+type paramTarget struct {
+	name   string // Host, MAC or NID as given in the request
+	status string // Result if the batch is applied
+	op     kvOp   // Only a check if the entry is unchanged
+}
+
+func storeOp(key string, v interface{}) kvOp {
+	data, _ := json.Marshal(v)
+	return kvOp{key: key, value: string(data)}
+}
+
+// Apply the writes for a set of boot parameter entries, all or nothing,
+// returning the result for each and recording their history.  If the request
+// has any If-Match ETags, every entry must currently match one of them.  The
+// other writes of the request, such as its images, are applied in the same
+// batch.
+func applyTargets(targets []paramTarget, other []kvOp, cr changeRequest) ([]bssTypes.HostResult, error) {
+	failed, err := guardTargets(targets, cr.ifMatch)
+	writes := 0
+	if err == nil {
+		var ops []kvOp
+		keys := make(map[string]bool, len(targets))
+		for _, t := range targets {
+			if !t.op.check {
+				writes++
+			}
+			if !t.op.check || t.op.guarded {
+				ops = append(ops, t.op)
+			}
+			keys[t.op.key] = true
+		}
+		ops = append(ops, other...)
+		failed, err = applyWithHistory(func() ([]kvOp, error) { return ops, nil }, cr)
+		if !keys[failed] {
+			failed = ""
+		}
+	}
+	results := make([]bssTypes.HostResult, len(targets))
+	for i, t := range targets {
+		results[i] = bssTypes.HostResult{Host: t.name, Status: t.status}
+		switch {
+		case err == nil:
+			if t.op.check {
+				results[i].ETag = paramsETag(strings.TrimPrefix(t.op.key, paramsPfx))
+			} else if !t.op.delete {
+				results[i].ETag = entryETag(t.op.value)
+			}
+		case errors.Is(err, errConflict) && (failed == "" || t.op.key == failed):
+			results[i].Status = bssTypes.HostPreconditionFailed
+			results[i].Error = err.Error()
+		case failed == "" || t.op.key == failed:
+			results[i].Status = bssTypes.HostFailed
+			results[i].Error = err.Error()
+		default:
+			results[i].Status = bssTypes.HostNotApplied
+		}
+	}
+	switch {
+	case errors.Is(err, errConflict):
+		msg := "An entry was changed by another request, none were changed"
+		if failed != "" {
+			msg = fmt.Sprintf("Entry %s does not match If-Match, none were changed", strings.TrimPrefix(failed, paramsPfx))
+		}
+		herr := base.NewHMSError("Storage", msg)
+		herr.AddProblem(base.NewProblemDetailsStatus(msg, http.StatusPreconditionFailed))
+		err = herr
+	case err != nil:
+		msg := fmt.Sprintf("Storage of %d entries failed, none were changed: %s", writes, err)
+		herr := base.NewHMSError("Storage", msg)
+		herr.AddProblem(base.NewProblemDetailsStatus(msg, http.StatusInternalServerError))
+		err = herr
+	}
+	return results, err
+}
+
+// Send the per-host results of a boot parameters request.
+func sendHostResults(w http.ResponseWriter, status int, results []bssTypes.HostResult) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	if results == nil {
+		return
+	}
+	if err := json.NewEncoder(w).Encode(bssTypes.HostResults{Results: results}); err != nil {
+		log.Printf("Yikes, I couldn't encode a JSON status response: %s\n", err)
+	}
+}
+
+// Send a problem details error for a boot parameters request, along with the
+// per-host results.
+func sendHostResultsProblem(w http.ResponseWriter, err error, status int, results []bssTypes.HostResult) {
+	var pd *base.ProblemDetails
+	if herr, ok := base.GetHMSError(err); ok && herr.GetProblem() != nil {
+		pd = herr.GetProblem()
+	} else {
+		pd = base.NewProblemDetailsStatus(err.Error(), status)
+	}
+	w.Header().Set("Content-Type", base.ProblemDetailContentType)
+	w.WriteHeader(pd.Status)
+	body := struct {
+		*base.ProblemDetails
+		Results []bssTypes.HostResult `json:"results,omitempty"`
+	}{pd, results}
+	if e := json.NewEncoder(w).Encode(body); e != nil {
+		log.Printf("Yikes, I couldn't encode a JSON status response: %s\n", e)
+	}
+}
@@ -0,0 +1,282 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+	hmetcd "github.com/Cray-HPE/hms-hmetcd"
+)
+
+// A KV store which fails writes to one key.
+type failingKvi struct {
+	hmetcd.Kvi
+	failKey string
+}
+
+func (f failingKvi) Store(key, value string) error {
+	if key == f.failKey {
+		return fmt.Errorf("injected failure storing %s", key)
+	}
+	return f.Kvi.Store(key, value)
+}
+
+func (f failingKvi) Delete(key string) error {
+	if key == f.failKey {
+		return fmt.Errorf("injected failure deleting %s", key)
+	}
+	return f.Kvi.Delete(key)
+}
+
+// A KV store with transactions, which fail if they include the fail key.
+type failingTxnKvi struct {
+	failingKvi
+	txns int
+}
+
+func (f *failingTxnKvi) Txn(ops []kvOp) error {
+	f.txns++
+	for _, op := range ops {
+		if op.key == f.failKey {
+			return fmt.Errorf("injected transaction failure")
+		}
+		if op.guarded {
+			val, exists, _ := f.Kvi.Get(op.key)
+			if exists != op.prevExists || val != op.prev {
+				return errConflict
+			}
+		}
+	}
+	for _, op := range ops {
+		switch {
+		case op.check:
+		case op.delete:
+			f.Kvi.Delete(op.key)
+		default:
+			f.Kvi.Store(op.key, op.value)
+		}
+	}
+	return nil
+}
+
+func withKvstore(kv hmetcd.Kvi) func() {
+	saved := kvstore
+	kvstore = kv
+	return func() { kvstore = saved }
+}
+
+func checkResults(t *testing.T, results []bssTypes.HostResult, want map[string]string) {
+	t.Helper()
+	if len(results) != len(want) {
+		t.Errorf("Results %+v, expected %v", results, want)
+		return
+	}
+	for _, r := range results {
+		if want[r.Host] != r.Status {
+			t.Errorf("Result for %s is %s, expected %s", r.Host, r.Status, want[r.Host])
+		}
+	}
+}
+
+func TestStoreAllOrNothing(t *testing.T) {
+	Store(bssTypes.BootParams{Hosts: []string{"x8c0s0b0n0"}, Params: "old"})
+	defer deleteHost("x8c0s0b0n0")
+
+	hosts := []string{"x8c0s0b0n0", "x8c0s1b0n0", "x8c0s2b0n0", "x8c0s3b0n0"}
+	fail := &failingTxnKvi{failingKvi: failingKvi{kvstore, paramsPfx + "x8c0s2b0n0"}}
+	for _, kv := range []hmetcd.Kvi{fail.failingKvi, fail} {
+		for _, max := range []int{128, 1} {
+			kvTxnMaxOps = max
+			restore := withKvstore(kv)
+			results, _, err := storeParams(bssTypes.BootParams{Hosts: hosts, Params: "new"}, changeRequest{})
+			restore()
+			if err == nil {
+				t.Fatalf("Store with an injected failure succeeded")
+			}
+			want := map[string]string{
+				"x8c0s0b0n0": bssTypes.HostNotApplied,
+				"x8c0s1b0n0": bssTypes.HostNotApplied,
+				"x8c0s2b0n0": bssTypes.HostFailed,
+				"x8c0s3b0n0": bssTypes.HostNotApplied,
+			}
+			if _, txn := kv.(kvTxnStore); txn {
+				// The whole transaction fails, not one key
+				for h := range want {
+					want[h] = bssTypes.HostFailed
+				}
+			}
+			checkResults(t, results, want)
+
+			if bds, err := lookupHost("x8c0s0b0n0"); err != nil || bds.Params != "old" {
+				t.Errorf("Existing host was not restored: %+v, %v", bds, err)
+			}
+			for _, h := range hosts[1:] {
+				if _, err := lookupHost(h); err == nil {
+					t.Errorf("Host %s was stored by a failed batch", h)
+					deleteHost(h)
+				}
+			}
+		}
+	}
+	kvTxnMaxOps = 128
+	if fail.txns != 6 {
+		t.Errorf("%d transactions were used, expected 6", fail.txns)
+	}
+
+	results, _, err := storeParams(bssTypes.BootParams{Hosts: hosts[:2], Params: "new"}, changeRequest{})
+	defer deleteHost("x8c0s1b0n0")
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	checkResults(t, results, map[string]string{"x8c0s0b0n0": bssTypes.HostStored, "x8c0s1b0n0": bssTypes.HostStored})
+}
+
+func TestUpdateRemoveAllOrNothing(t *testing.T) {
+	for _, h := range []string{"x8c1s0b0n0", "x8c1s1b0n0"} {
+		Store(bssTypes.BootParams{Hosts: []string{h}, Params: "quiet"})
+		defer deleteHost(h)
+	}
+
+	// A missing host fails the whole update before anything is written
+	results, err := updateParams(bssTypes.BootParams{Hosts: []string{"x8c1s0b0n0", "x8c1s9b0n0"}, Params: "debug"}, changeRequest{})
+	if err == nil {
+		t.Fatalf("Update of a missing host succeeded")
+	}
+	checkResults(t, results, map[string]string{"x8c1s0b0n0": bssTypes.HostNotApplied, "x8c1s9b0n0": bssTypes.HostNotFound})
+	if bds, _ := lookupHost("x8c1s0b0n0"); bds.Params != "quiet" {
+		t.Errorf("Host was updated by a failed request: %s", bds.Params)
+	}
+
+	restore := withKvstore(failingKvi{kvstore, paramsPfx + "x8c1s1b0n0"})
+	_, err = updateParams(bssTypes.BootParams{Hosts: []string{"x8c1s0b0n0", "x8c1s1b0n0"}, Params: "debug"}, changeRequest{})
+	if err == nil {
+		t.Errorf("Update with an injected failure succeeded")
+	}
+	results, err = removeParams(bssTypes.BootParams{Hosts: []string{"x8c1s0b0n0", "x8c1s1b0n0"}}, changeRequest{})
+	restore()
+	if err == nil {
+		t.Fatalf("Remove with an injected failure succeeded")
+	}
+	checkResults(t, results, map[string]string{"x8c1s0b0n0": bssTypes.HostNotApplied, "x8c1s1b0n0": bssTypes.HostFailed})
+	if bds, err := lookupHost("x8c1s0b0n0"); err != nil || bds.Params != "quiet" {
+		t.Errorf("Host was changed by failed requests: %+v, %v", bds, err)
+	}
+
+	results, err = updateParams(bssTypes.BootParams{Hosts: []string{"x8c1s0b0n0", "x8c1s1b0n0"}, Params: "quiet"}, changeRequest{})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	checkResults(t, results, map[string]string{"x8c1s0b0n0": bssTypes.HostUnchanged, "x8c1s1b0n0": bssTypes.HostUnchanged})
+}
+
+func TestImagesInBatch(t *testing.T) {
+	kernel := "s3://boot-images/batch/kernel"
+	defer removeImage(kernel, kernelImageType, changeRequest{})
+
+	// Requests which fail leave no images behind
+	restore := withKvstore(failingKvi{kvstore, paramsPfx + "x8c6s1b0n0"})
+	_, _, err := storeParams(bssTypes.BootParams{Hosts: []string{"x8c6s0b0n0", "x8c6s1b0n0"}, Kernel: kernel}, changeRequest{})
+	restore()
+	if err == nil {
+		t.Fatalf("Store with an injected failure succeeded")
+	}
+	if key := imageFind(kernel, kernelImageType); key != "" {
+		t.Errorf("Image %s was stored by a failed request", key)
+	}
+	Store(bssTypes.BootParams{Hosts: []string{"x8c6s0b0n0"}, Params: "quiet"})
+	defer deleteHost("x8c6s0b0n0")
+	_, err = updateParams(bssTypes.BootParams{Hosts: []string{"x8c6s0b0n0"}, Kernel: kernel},
+		changeRequest{ifMatch: []string{`"0000000000000000"`}})
+	if err == nil {
+		t.Fatalf("Update which does not match If-Match succeeded")
+	}
+	if key := imageFind(kernel, kernelImageType); key != "" {
+		t.Errorf("Image %s was stored by a rejected request", key)
+	}
+
+	// Removing hosts and their image is one batch
+	Store(bssTypes.BootParams{Hosts: []string{"x8c6s0b0n0", "x8c6s1b0n0"}, Kernel: kernel})
+	defer deleteHost("x8c6s1b0n0")
+	key := imageFind(kernel, kernelImageType)
+	restore = withKvstore(failingKvi{kvstore, key})
+	_, err = removeParams(bssTypes.BootParams{Hosts: []string{"x8c6s0b0n0"}, Kernel: kernel}, changeRequest{})
+	restore()
+	if err == nil {
+		t.Fatalf("Remove with an injected failure succeeded")
+	}
+	if _, err := lookupHost("x8c6s0b0n0"); err != nil {
+		t.Errorf("Host was removed by a failed request")
+	}
+	if _, err = removeParams(bssTypes.BootParams{Hosts: []string{"x8c6s0b0n0"}, Kernel: kernel}, changeRequest{}); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if bds, err := lookupHost("x8c6s1b0n0"); err != nil || bds.Kernel != "" || imageFind(kernel, kernelImageType) != "" {
+		t.Errorf("Image was not removed: %+v, %v", bds, err)
+	}
+	if _, err := lookupHost("x8c6s0b0n0"); err == nil {
+		t.Errorf("Host was not removed")
+	}
+}
+
+func TestBootparametersResults(t *testing.T) {
+	send := func(method, body string) (int, bssTypes.HostResults) {
+		rr := serveRequest(t, bootParameters, method, "/bootparameters", body)
+		var res bssTypes.HostResults
+		json.Unmarshal(rr.Body.Bytes(), &res)
+		return rr.Code, res
+	}
+
+	code, res := send(http.MethodPut, `{"hosts":["x8c2s0b0n0","x8c2s1b0n0"],"params":"quiet"}`)
+	defer deleteHost("x8c2s0b0n0")
+	defer deleteHost("x8c2s1b0n0")
+	if code != http.StatusOK {
+		t.Fatalf("PUT returned %d", code)
+	}
+	checkResults(t, res.Results, map[string]string{"x8c2s0b0n0": bssTypes.HostStored, "x8c2s1b0n0": bssTypes.HostStored})
+
+	code, res = send(http.MethodPatch, `{"hosts":["x8c2s0b0n0","x8c2s9b0n0"],"params":"debug"}`)
+	if code != http.StatusNotFound {
+		t.Errorf("PATCH of a missing host returned %d", code)
+	}
+	checkResults(t, res.Results, map[string]string{"x8c2s0b0n0": bssTypes.HostNotApplied, "x8c2s9b0n0": bssTypes.HostNotFound})
+
+	code, res = send(http.MethodDelete, `{"hosts":["x8c2s0b0n0","x8c2s9b0n0"]}`)
+	if code != http.StatusBadRequest {
+		t.Errorf("DELETE of a missing host returned %d", code)
+	}
+	checkResults(t, res.Results, map[string]string{"x8c2s0b0n0": bssTypes.HostNotApplied, "x8c2s9b0n0": bssTypes.HostNotFound})
+
+	code, res = send(http.MethodDelete, `{"hosts":["x8c2s0b0n0","x8c2s1b0n0"]}`)
+	if code != http.StatusOK {
+		t.Errorf("DELETE returned %d", code)
+	}
+	checkResults(t, res.Results, map[string]string{"x8c2s0b0n0": bssTypes.HostDeleted, "x8c2s1b0n0": bssTypes.HostDeleted})
+	if _, err := lookupHost("x8c2s0b0n0"); err == nil || !strings.Contains(err.Error(), "x8c2s0b0n0") {
+		t.Errorf("Host still exists after DELETE: %v", err)
+	}
+}
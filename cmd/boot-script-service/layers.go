@@ -0,0 +1,252 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+/*
+ * Parameter layering
+ *
+ * Without layering, a node boots with the single most specific entry found
+ * for it: the host, then its role, then Default.  With BSS_PARAM_LAYERING
+ * enabled, the kernel parameters of every entry which applies to the node are
+ * composed instead, least specific first:
+ *
+ *   Global, Default, <role>, <role>:<subrole>, Group-<label>..., <host>
+ *
+ * Group layers are used for each HSM group the node is a member of.  Each
+ * word of a layer's params is applied to the arguments of the layers before
+ * it:
+ *
+ *   key=value   replaces the arguments with the same key, or is appended
+ *   +key=value  is appended, keeping any arguments with the same key
+ *   -key        removes the arguments with that key
+ *   -key=value  removes that exact argument
+ *
+ * Repeating a key within one layer keeps all of its values, so a layer may
+ * set several console= arguments.  The kernel and initrd come from the most
+ * specific layer which sets them, the cloud-init data and referral token from
+ * the most specific layer.  The fields of architecture variants also come from
+ * the most specific layer which sets them.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+	"github.com/Cray-HPE/hms-bss/pkg/cmdline"
+)
+
+const groupLayerPfx = "Group-"
+
+var paramLayering = false
+
+type paramLayer struct {
+	name string
+	bds  BootDataStore
+}
+
+// Find the parameter layers of a node, least specific first.  Without
+// layering, only the entry lookup() would use is returned.
+func nodeLayers(name, altName string, comp SMComponent) []paramLayer {
+	var layers []paramLayer
+	add := func(n string) bool {
+		if n == "" {
+			return false
+		}
+		bds, err := lookupHost(n)
+		if err != nil {
+			return false
+		}
+		layers = append(layers, paramLayer{n, withProfile(bds)})
+		return true
+	}
+	if !paramLayering {
+		if add(name) || (altName != name && add(altName)) || add(comp.Role) {
+			return layers
+		}
+		add(DefaultTag)
+		return layers
+	}
+
+	add(GlobalTag)
+	add(DefaultTag)
+	if comp.Role != "" {
+		add(comp.Role)
+		if comp.SubRole != "" {
+			add(comp.Role + ":" + comp.SubRole)
+		}
+	}
+	for _, g := range comp.Groups {
+		add(groupLayerPfx + g)
+	}
+	if !add(name) && altName != name {
+		add(altName)
+	}
+	return layers
+}
+
+func paramKey(arg string) string {
+	return cmdline.ParseParam(arg).Key
+}
+
+// Apply one layer's params to the arguments composed so far.
+func applyLayer(args, removed []bssTypes.ParamSource, layer, params string) ([]bssTypes.ParamSource, []bssTypes.ParamSource) {
+	words, _ := cmdline.Fields(params)
+	for _, word := range words {
+		switch {
+		case len(word) > 1 && word[0] == '+':
+			args = append(args, bssTypes.ParamSource{Arg: word[1:], Layer: layer})
+		case len(word) > 1 && word[0] == '-' && word != "--":
+			target := word[1:]
+			exact := strings.Contains(target, "=")
+			kept := args[:0:0]
+			for _, a := range args {
+				if (exact && a.Arg == target) || (!exact && paramKey(a.Arg) == target) {
+					a.RemovedBy = layer
+					removed = append(removed, a)
+				} else {
+					kept = append(kept, a)
+				}
+			}
+			args = kept
+		default:
+			// Replace the arguments of earlier layers with the same key,
+			// keeping the position of the first of them.
+			key := paramKey(word)
+			kept := args[:0:0]
+			replaced := false
+			for _, a := range args {
+				if a.Layer == layer || paramKey(a.Arg) != key {
+					kept = append(kept, a)
+					continue
+				}
+				a.RemovedBy = layer
+				removed = append(removed, a)
+				if !replaced {
+					kept = append(kept, bssTypes.ParamSource{Arg: word, Layer: layer})
+					replaced = true
+				}
+			}
+			args = kept
+			if !replaced {
+				args = append(args, bssTypes.ParamSource{Arg: word, Layer: layer})
+			}
+		}
+	}
+	return args, removed
+}
+
+// Compose the parameter layers of a node.  The returned explanation has the
+// layer of every argument, but not the kernel and initrd paths.  The boolean
+// is false if there are no layers.
+func composeLayers(layers []paramLayer) (BootDataStore, bssTypes.ParamsExplanation, bool) {
+	var bds BootDataStore
+	ex := bssTypes.ParamsExplanation{Layers: []string{}, Args: []bssTypes.ParamSource{}}
+	if len(layers) == 0 {
+		return bds, ex, false
+	}
+	for _, l := range layers {
+		ex.Layers = append(ex.Layers, l.name)
+		if paramLayering {
+			ex.Args, ex.Removed = applyLayer(ex.Args, ex.Removed, l.name, l.bds.Params)
+		} else {
+			words, _ := cmdline.Fields(l.bds.Params)
+			for _, w := range words {
+				ex.Args = append(ex.Args, bssTypes.ParamSource{Arg: w, Layer: l.name})
+			}
+		}
+		if l.bds.Kernel != "" {
+			bds.Kernel = l.bds.Kernel
+			ex.KernelLayer = l.name
+		}
+		if l.bds.Initrd != "" {
+			bds.Initrd = l.bds.Initrd
+			ex.InitrdLayer = l.name
+		}
+		bds.ArchVariants = overlayArchVariants(bds.ArchVariants, l.bds.ArchVariants)
+	}
+	last := layers[len(layers)-1].bds
+	bds.CloudInit = last.CloudInit
+	bds.ReferralToken = last.ReferralToken
+	bds.Profile = last.Profile
+
+	words := make([]string, len(ex.Args))
+	for i, a := range ex.Args {
+		words[i] = a.Arg
+	}
+	bds.Params = strings.Join(words, " ")
+	ex.Params = bds.Params
+	return bds, ex, true
+}
+
+// Explain how the boot parameters of a node are composed.
+func paramsExplainGetAPI(w http.ResponseWriter, r *http.Request) {
+	debugf("paramsExplainGetAPI(): Received request %v\n", r.URL)
+	r.ParseForm()
+	name := strings.Join(r.Form["name"], "")
+	mac := strings.Join(r.Form["mac"], "")
+	nid, _ := getIntParam(r, "nid", -1)
+
+	var comp SMComponent
+	var ok bool
+	altName := ""
+	switch {
+	case name != "":
+		comp, ok = FindSMCompByName(name)
+		altName = name
+	case mac != "":
+		comp, ok = FindSMCompByMAC(mac)
+		altName = mac
+	case nid >= 0:
+		comp, ok = FindSMCompByNid(int(nid))
+		altName = nidName(int(nid))
+	default:
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, "Need a mac=, name=, or nid= parameter")
+		return
+	}
+	host := altName
+	if ok {
+		host = comp.ID
+	}
+
+	bds, ex, found := composeLayers(nodeLayers(host, altName, comp))
+	if !found {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound,
+			fmt.Sprintf("No boot parameters apply to %s", host))
+		return
+	}
+	ex.Host = host
+	bd := bdConvert(bds)
+	ex.Kernel = bd.Kernel.Path
+	ex.Initrd = bd.Initrd.Path
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(ex); err != nil {
+		log.Printf("Yikes, I couldn't encode a JSON status response: %s\n", err)
+	}
+}
@@ -0,0 +1,170 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+	"github.com/Cray-HPE/hms-bss/pkg/cmdline"
+)
+
+func TestApplyLayer(t *testing.T) {
+	tests := []struct {
+		layers []string
+		want   string
+	}{
+		{[]string{"console=ttyS0 quiet", "console=tty0"}, "console=tty0 quiet"},
+		{[]string{"console=ttyS0 quiet", "+console=tty0"}, "console=ttyS0 quiet console=tty0"},
+		{[]string{"console=ttyS0 quiet", "-quiet rd.shell"}, "console=ttyS0 rd.shell"},
+		{[]string{"a=1 b=2 a=3", "a=4"}, "a=4 b=2"},
+		{[]string{"a=1 a=2", "-a=1"}, "a=2"},
+		{[]string{"quiet", "console=tty0 console=ttyS0"}, "quiet console=tty0 console=ttyS0"},
+		{[]string{`root='live:LABEL=my root' quiet`, `root="live:LABEL=other"`}, `root="live:LABEL=other" quiet`},
+		{[]string{"init=/sbin/init -- single", "+-s"}, "init=/sbin/init -- single -s"},
+	}
+	for _, tt := range tests {
+		var args, removed []bssTypes.ParamSource
+		for i, p := range tt.layers {
+			args, removed = applyLayer(args, removed, string(rune('A'+i)), p)
+		}
+		var got []string
+		for _, a := range args {
+			got = append(got, a.Arg)
+		}
+		words, _ := cmdline.Fields(tt.want)
+		if len(got) != len(words) {
+			t.Errorf("%q: got %q, expected %q", tt.layers, got, words)
+			continue
+		}
+		for i := range got {
+			if got[i] != words[i] {
+				t.Errorf("%q: got %q, expected %q", tt.layers, got, words)
+				break
+			}
+		}
+	}
+}
+
+func TestParamLayering(t *testing.T) {
+	paramLayering = true
+	defer func() { paramLayering = false }()
+
+	// Save the Default entry other tests use
+	saved, haveDefault, _ := kvstore.Get(paramsPfx + DefaultTag)
+	defer func() {
+		if haveDefault {
+			kvstore.Store(paramsPfx+DefaultTag, saved)
+		} else {
+			deleteHost(DefaultTag)
+		}
+	}()
+
+	cleanupImages(t, kernelImageType, "http://images/default/kernel", "http://images/blue/kernel")
+	cleanupImages(t, initrdImageType, "http://images/role/initrd")
+	entries := []bssTypes.BootParams{
+		{Hosts: []string{GlobalTag}, Params: "console=ttyS0,115200 quiet"},
+		{Hosts: []string{DefaultTag}, Params: "rd.retry=10", Kernel: "http://images/default/kernel"},
+		{Hosts: []string{"LayerRole"}, Params: "rd.retry=40 ip=dhcp", Initrd: "http://images/role/initrd"},
+		{Hosts: []string{"LayerRole:Worker"}, Params: "+console=tty0"},
+		{Hosts: []string{groupLayerPfx + "blue"}, Params: "-quiet", Kernel: "http://images/blue/kernel"},
+		{Hosts: []string{"x9c0s3b0n0"}, Params: "ip=off xname=x9c0s3b0n0"},
+	}
+	for _, bp := range entries {
+		if err, _ := Store(bp); err != nil {
+			t.Fatalf("Store(%v) failed: %v", bp.Hosts, err)
+		}
+		if bp.Hosts[0] != DefaultTag {
+			defer deleteHost(bp.Hosts[0])
+		}
+	}
+
+	comp := SMComponent{Component: base.Component{ID: "x9c0s3b0n0", Role: "LayerRole", SubRole: "Worker"},
+		Groups: []string{"blue", "red"}}
+	bd := lookupNode(comp.ID, comp.ID, comp)
+	if want := "console=ttyS0,115200 rd.retry=40 ip=off console=tty0 xname=x9c0s3b0n0"; bd.Params != want {
+		t.Errorf("Composed params '%s', expected '%s'", bd.Params, want)
+	}
+	if bd.Kernel.Path != "http://images/blue/kernel" || bd.Initrd.Path != "http://images/role/initrd" {
+		t.Errorf("Wrong images: kernel %s, initrd %s", bd.Kernel.Path, bd.Initrd.Path)
+	}
+
+	// A node with no entry of its own still gets the role layers
+	other := comp
+	other.ID, other.SubRole, other.Groups = "x9c0s4b0n0", "", nil
+	if bd = lookupNode(other.ID, other.ID, other); bd.Params != "console=ttyS0,115200 quiet rd.retry=40 ip=dhcp" {
+		t.Errorf("Composed params '%s' for a node without its own entry", bd.Params)
+	}
+
+	_, ex, _ := composeLayers(nodeLayers(comp.ID, comp.ID, comp))
+	wantLayers := []string{GlobalTag, DefaultTag, "LayerRole", "LayerRole:Worker", "Group-blue", "x9c0s3b0n0"}
+	if len(ex.Layers) != len(wantLayers) {
+		t.Fatalf("Layers %v, expected %v", ex.Layers, wantLayers)
+	}
+	for i := range wantLayers {
+		if ex.Layers[i] != wantLayers[i] {
+			t.Errorf("Layers %v, expected %v", ex.Layers, wantLayers)
+			break
+		}
+	}
+	sources := map[string]string{}
+	for _, a := range ex.Args {
+		sources[a.Arg] = a.Layer
+	}
+	if sources["rd.retry=40"] != "LayerRole" || sources["console=tty0"] != "LayerRole:Worker" {
+		t.Errorf("Wrong argument sources: %v", ex.Args)
+	}
+	removedBy := map[string]string{}
+	for _, a := range ex.Removed {
+		removedBy[a.Arg] = a.RemovedBy
+	}
+	if removedBy["quiet"] != "Group-blue" || removedBy["rd.retry=10"] != "LayerRole" || removedBy["ip=dhcp"] != "x9c0s3b0n0" {
+		t.Errorf("Wrong removed arguments: %v", ex.Removed)
+	}
+	if ex.KernelLayer != "Group-blue" || ex.InitrdLayer != "LayerRole" {
+		t.Errorf("Wrong image layers: kernel %s, initrd %s", ex.KernelLayer, ex.InitrdLayer)
+	}
+}
+
+func TestParamsExplainAPI(t *testing.T) {
+	Store(bssTypes.BootParams{Hosts: []string{"x0c0s2b0n0"}, Params: "console=ttyS0 quiet"})
+	defer deleteHost("x0c0s2b0n0")
+
+	rr := serveRequest(t, paramsExplain, http.MethodGet, "/bootparameters/explain?name=x0c0s2b0n0", nil)
+	var ex bssTypes.ParamsExplanation
+	if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &ex) != nil {
+		t.Fatalf("GET returned %d: %s", rr.Code, rr.Body)
+	}
+	if ex.Host != "x0c0s2b0n0" || ex.Params != "console=ttyS0 quiet" || len(ex.Args) != 2 ||
+		ex.Args[1].Layer != "x0c0s2b0n0" {
+		t.Errorf("Unexpected explanation: %+v", ex)
+	}
+
+	rr = serveRequest(t, paramsExplain, http.MethodGet, "/bootparameters/explain", nil)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("GET without a node returned %d, expected %d", rr.Code, http.StatusBadRequest)
+	}
+}
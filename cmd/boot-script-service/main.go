@@ -39,13 +39,16 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	base "github.com/Cray-HPE/hms-base"
@@ -171,6 +174,13 @@ func kvOpen(url, opts string, retryCount, retryWait uint64) (err error) {
 		err = fmt.Errorf("ETCD connection attempts exhausted (%d).", retryCount)
 	} else {
 		log.Printf("KV service initialized connecting to %s", url)
+		if !strings.HasPrefix(url, "mem:") {
+			var terr error
+			kvstore, terr = newEtcdTxnStore(kvstore, url)
+			if terr != nil {
+				log.Printf("WARNING: ETCD transactions unavailable, multi-host updates will be chunked: %s", terr)
+			}
+		}
 	}
 	return err
 }
@@ -222,6 +232,41 @@ func main() {
 	parseEnv("BSS_RETRIEVAL_DELAY", &hsmRetrievalDelay)
 	parseEnv("SPIRE_TOKEN_URL", &spireServiceURL)
 	parseEnv("BSS_ADVERTISE_ADDRESS", &advertiseAddress)
+	var roleFormats []string
+	parseEnv("BSS_ROLE_BOOT_FORMATS", &roleFormats)
+	parseEnv("BSS_PARAM_LAYERING", &paramLayering)
+	parseEnv("BSS_HISTORY_LIMIT", &historyLimit)
+	var validationMode string
+	parseEnv("BSS_IMAGE_VALIDATION", &validationMode)
+	parseEnv("BSS_IMAGE_VALIDATION_INTERVAL", &imageValidationInterval)
+	parseEnv("BSS_ARTIFACT_DIR", &artifactDir)
+	parseEnv("BSS_ARTIFACT_S3_PROXY", &artifactS3Proxy)
+	parseEnv("BSS_ARTIFACT_CACHE_DIR", &artifactCacheDir)
+	parseEnv("BSS_ARTIFACT_CACHE_TTL", &artifactCacheTTL)
+	parseEnv("BSS_TFTP_LISTEN", &tftpListen)
+	parseEnv("BSS_HSM_RESYNC_INTERVAL", &hsmResyncInterval)
+	parseEnv("BSS_HSM_REFRESH_MIN_INTERVAL", &hsmRefreshMinInterval)
+	parseEnv("BSS_HSM_CHANGES_POLL_INTERVAL", &hsmChangesPollInterval)
+	parseEnv("BSS_HSM_TIMEOUT", &hsmTimeout)
+	parseEnv("BSS_HSM_RETRIES", &hsmRetries)
+	parseEnv("BSS_HSM_BREAKER_THRESHOLD", &hsmBreakerThreshold)
+	parseEnv("BSS_HSM_BREAKER_COOLDOWN", &hsmBreakerCooldown)
+	parseEnv("BSS_HSM_SNAPSHOT", &hsmSnapshot)
+	parseEnv("BSS_INVENTORY_POLL_INTERVAL", &inventoryPollInterval)
+	parseEnv("BSS_SCN_VERIFY_INTERVAL", &scnVerifyInterval)
+	parseEnv("BSS_SCN_RETRY_MIN", &scnRetryMin)
+	parseEnv("BSS_SCN_RETRY_MAX", &scnRetryMax)
+	var scnSubscription string
+	parseEnv("BSS_SCN_SUBSCRIPTION", &scnSubscription)
+	parseEnv("BSS_SCN_ROLES", &scnRoles)
+	parseEnv("BSS_SCN_SUBROLES", &scnSubRoles)
+	var scnAuthMode string
+	parseEnv("BSS_SCN_AUTH", &scnAuthMode)
+	parseEnv("BSS_SCN_SECRET", &scnSecret)
+	parseEnv("BSS_SCN_CLIENT_NAMES", &scnClientNames)
+	parseEnv("BSS_EVENT_WEBHOOKS", &eventWebhooks)
+	parseEnv("BSS_BOOT_LOOP_THRESHOLD", &bootLoopThreshold)
+	parseEnv("BSS_BOOT_STUCK_TIMEOUT", &bootStuckTimeout)
 
 	flag.StringVar(&httpListen, "http-listen", httpListen, "HTTP server IP + port binding")
 	flag.StringVar(&hsmBase, "hsm", hsmBase, "Hardware State Manager location as URI, e.g. [scheme]://[host[:port]]")
@@ -236,6 +281,19 @@ func main() {
 	flag.UintVar(&hsmRetrievalDelay, "hsm-retrieval-delay", hsmRetrievalDelay, "SM Retrieval delay in seconds")
 	flag.Parse()
 
+	if err := setRoleBootFormats(roleFormats); err != nil {
+		log.Fatalf("BSS_ROLE_BOOT_FORMATS: %v", err)
+	}
+	if err := setImageValidation(validationMode); err != nil {
+		log.Fatalf("BSS_IMAGE_VALIDATION: %v", err)
+	}
+	if err := setSCNStrategy(scnSubscription); err != nil {
+		log.Fatalf("BSS_SCN_SUBSCRIPTION: %v", err)
+	}
+	if err := setSCNAuth(scnAuthMode); err != nil {
+		log.Fatalf("BSS_SCN_AUTH: %v", err)
+	}
+
 	sn, snerr := base.GetServiceInstanceName()
 	if snerr == nil {
 		serviceName = sn
@@ -261,6 +319,7 @@ func main() {
 	}
 
 	notifier = newNotifier(serviceName, nfdBase+"/hmi/v1/subscribe", getNotifierURL(), svcOpts)
+	go notifier.run()
 
 	kvRetyCount, kvRetryWait, err := kvDefaultRetryConfig()
 	if err != nil {
@@ -271,10 +330,71 @@ func main() {
 	if err != nil {
 		log.Fatalf("Access to Datastore service %s with name %s failed: %v\n", datastoreBase, serviceName, err)
 	}
+	if n, err := rebuildImageRefs(); err != nil {
+		log.Printf("WARNING: Failed to check the image reference index: %s", err)
+	} else if n > 0 {
+		log.Printf("Repaired %d image references", n)
+	}
+	go bootSessionWorker()
+	if smClient != nil {
+		if err := loadHSMSnapshot(); err != nil {
+			log.Printf("WARNING: Failed to load the HSM state snapshot: %s", err)
+		}
+		// Fetch the state now rather than when the first node boots, and
+		// serve the snapshot until it has been fetched.
+		go refreshState(-1)
+		go hsmSyncer()
+	}
+	if f, ok := smInventory.(*fileInventory); ok && inventoryPollInterval > 0 {
+		go f.watch()
+	}
+	if imageValidation != imageValidationOff && imageValidationInterval > 0 {
+		go imageValidator()
+	}
+	if err := startWebhooks(eventWebhooks); err != nil {
+		log.Fatalf("BSS_EVENT_WEBHOOKS: %v", err)
+	}
+	if tftpListen != "" {
+		if artifactsEnabled() {
+			go tftpServe(tftpListen)
+		} else {
+			log.Printf("WARNING: BSS_TFTP_LISTEN is set without BSS_ARTIFACT_DIR or BSS_ARTIFACT_S3_PROXY, not serving TFTP")
+		}
+	}
 	err = spireTokenServiceInit(spireServiceURL, svcOpts)
 	if err != nil {
 		// NOTE: Should this be fatal???  Right now, we will continue.
 		log.Printf("WARNING: Spire join token service %s access failure: %s", spireServiceURL, err)
 	}
-	log.Fatal(http.ListenAndServe(httpListen, nil))
+	serve(&http.Server{Addr: httpListen})
+}
+
+// Serve requests until SIGINT or SIGTERM, then stop the hmnfd subscription
+// manager and let the requests in progress finish.
+func serve(srv *http.Server) {
+	srv.RegisterOnShutdown(events.close)
+	stopped := make(chan struct{})
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-sigs
+		log.Printf("Received %s, shutting down", sig)
+		notifier.shutdown(10 * time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("WARNING: Shutdown: %s", err)
+		}
+		close(stopped)
+	}()
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-stopped
+	if !flushBootSessions(5 * time.Second) {
+		log.Printf("WARNING: Timed out storing the boot session changes")
+	}
+	if err := kvstore.Close(); err != nil {
+		log.Printf("WARNING: Closing the KV store: %s", err)
+	}
 }
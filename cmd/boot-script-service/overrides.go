@@ -0,0 +1,413 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+/*
+ * Boot overrides
+ *
+ * An override replaces the params, kernel or initrd that its hosts boot
+ * with, on top of their boot parameters.  A one-shot override is used for a
+ * single boot of each host: before BootscriptGet serves a boot script with
+ * it, the time is recorded under /override-consumed/<name>/<host>, the way
+ * endpoint accesses are, and the host goes back to its own boot parameters.
+ * The time is only recorded if it wasn't already, so that when a host asks
+ * several replicas at once only one of them serves the override.  An
+ * override with a start or end time is only used between them.  When
+ * several overrides apply to a node, the one for its xname is used over one
+ * for its role, which is used over one for Default, and after that the one
+ * which started last.
+ *
+ * The overrides and their uses are cached for boot script requests.  Every
+ * change to them stores a new revision under overridesRevKey, so that each
+ * replica only reads them again when they have changed.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+	"github.com/google/uuid"
+)
+
+const (
+	overridesPfx        = "/overrides/"
+	overrideConsumedPfx = "/override-consumed"
+	overridesRevKey     = "/overrides-revision"
+)
+
+// The overrides, with the uses of the one-shot overrides, as of a revision of
+// overridesRevKey.
+var overrideCache struct {
+	sync.Mutex
+	loaded bool
+	rev    string
+	ol     []bssTypes.BootOverride
+}
+
+func getOverrides() ([]bssTypes.BootOverride, error) {
+	kvl, err := kvstore.GetRange(overridesPfx+keyMin, overridesPfx+keyMax)
+	if err != nil {
+		return nil, err
+	}
+	var ret []bssTypes.BootOverride
+	for _, kv := range kvl {
+		var bo bssTypes.BootOverride
+		if e := json.Unmarshal([]byte(kv.Value), &bo); e != nil {
+			log.Printf("Skipping bad boot override %s: %s", kv.Key, e)
+			continue
+		}
+		ret = append(ret, bo)
+	}
+	return ret, nil
+}
+
+func getOverride(name string) (bssTypes.BootOverride, bool, error) {
+	var bo bssTypes.BootOverride
+	val, exists, err := kvstore.Get(overridesPfx + name)
+	if err == nil && exists {
+		err = json.Unmarshal([]byte(val), &bo)
+	}
+	return bo, exists, err
+}
+
+func overrideConsumedKey(name, host string) string {
+	return fmt.Sprintf("%s/%s/%s", overrideConsumedPfx, name, host)
+}
+
+// Fill in the hosts which have consumed a one-shot override.
+func overrideConsumed(bo *bssTypes.BootOverride) {
+	pfx := fmt.Sprintf("%s/%s/", overrideConsumedPfx, bo.Name)
+	kvl, err := kvstore.GetRange(pfx+keyMin, pfx+keyMax)
+	if err != nil || len(kvl) == 0 {
+		return
+	}
+	bo.Consumed = make(map[string]string, len(kvl))
+	for _, kv := range kvl {
+		ts, _ := strconv.ParseInt(kv.Value, 10, 64)
+		bo.Consumed[strings.TrimPrefix(kv.Key, pfx)] = time.Unix(ts, 0).UTC().Format(time.RFC3339)
+	}
+}
+
+// The overrides, with the hosts which have consumed the one-shot overrides.
+// They are only read again when they have changed.  They are shared, so
+// must not be changed.
+func cachedOverrides() ([]bssTypes.BootOverride, error) {
+	rev, _, err := kvstore.Get(overridesRevKey)
+	if err != nil {
+		return nil, err
+	}
+	overrideCache.Lock()
+	defer overrideCache.Unlock()
+	if overrideCache.loaded && rev == overrideCache.rev {
+		return overrideCache.ol, nil
+	}
+	ol, err := getOverrides()
+	if err != nil {
+		return nil, err
+	}
+	for i := range ol {
+		if ol[i].OneShot {
+			overrideConsumed(&ol[i])
+		}
+	}
+	overrideCache.loaded, overrideCache.rev, overrideCache.ol = true, rev, ol
+	return ol, nil
+}
+
+// Store a new revision of the overrides.
+func overridesChanged() error {
+	return kvstore.Store(overridesRevKey, uuid.New().String())
+}
+
+// Record that a host boots with a one-shot override, unless it already has.
+// The override is only to be used if it is consumed here.
+func consumeOverride(name, host string) bool {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	key := overrideConsumedKey(name, host)
+	op := guardOp(kvOp{key: key, value: timestamp}, "", 0, false)
+	_, err := applyBatch([]kvOp{op, {key: overridesRevKey, value: uuid.New().String()}})
+	if errors.Is(err, errConflict) {
+		debugf("Boot override %s already used by %s", name, host)
+		return false
+	} else if err != nil {
+		log.Printf("Failed to store boot override use %s to key %s: %s", timestamp, key, err)
+		return false
+	}
+	return true
+}
+
+func clearConsumed(name string) error {
+	pfx := fmt.Sprintf("%s/%s/", overrideConsumedPfx, name)
+	kvl, err := kvstore.GetRange(pfx+keyMin, pfx+keyMax)
+	for _, kv := range kvl {
+		if err == nil {
+			err = kvstore.Delete(kv.Key)
+		}
+	}
+	return err
+}
+
+// Check whether an override's time window includes now.
+func overrideInWindow(bo bssTypes.BootOverride, now time.Time) bool {
+	if start, err := time.Parse(time.RFC3339, bo.Start); err == nil && now.Before(start) {
+		return false
+	}
+	if end, err := time.Parse(time.RFC3339, bo.End); err == nil && !now.Before(end) {
+		return false
+	}
+	return true
+}
+
+// Select the override a node boots with now from a list of overrides.
+func selectOverride(ol []bssTypes.BootOverride, host, role string, now time.Time) (bssTypes.BootOverride, bool) {
+	for _, name := range []string{host, role, DefaultTag} {
+		if name == "" {
+			continue
+		}
+		var found []bssTypes.BootOverride
+		for _, bo := range ol {
+			if !overrideInWindow(bo, now) {
+				continue
+			}
+			for _, h := range bo.Hosts {
+				if !strings.EqualFold(h, name) {
+					continue
+				}
+				if _, used := bo.Consumed[host]; used && bo.OneShot {
+					continue
+				}
+				found = append(found, bo)
+				break
+			}
+		}
+		if len(found) > 0 {
+			sort.SliceStable(found, func(i, j int) bool {
+				si, _ := time.Parse(time.RFC3339, found[i].Start)
+				sj, _ := time.Parse(time.RFC3339, found[j].Start)
+				return si.After(sj)
+			})
+			return found[0], true
+		}
+	}
+	return bssTypes.BootOverride{}, false
+}
+
+// The override a node boots with now, if any.
+func activeOverride(host, role string) (bssTypes.BootOverride, bool) {
+	if host == "" {
+		return bssTypes.BootOverride{}, false
+	}
+	ol, err := cachedOverrides()
+	if err != nil || len(ol) == 0 {
+		return bssTypes.BootOverride{}, false
+	}
+	return selectOverride(ol, host, role, time.Now())
+}
+
+// Apply an override to the boot data of a node.
+func applyOverride(bd BootData, bo bssTypes.BootOverride) BootData {
+	if bo.Params != "" {
+		bd.Params = bo.Params
+	}
+	if bo.Kernel != "" {
+		bd.Kernel = ImageData{Path: bo.Kernel}
+	}
+	if bo.Initrd != "" {
+		bd.Initrd = ImageData{Path: bo.Initrd}
+	}
+	return bd
+}
+
+func checkOverride(bo *bssTypes.BootOverride) error {
+	if bo.Name == "" || strings.ContainsAny(bo.Name, "/ ") {
+		return fmt.Errorf("Override name '%s' is invalid", bo.Name)
+	}
+	if len(bo.Hosts) == 0 {
+		return fmt.Errorf("Override has no hosts")
+	}
+	if bo.Params == "" && bo.Kernel == "" && bo.Initrd == "" {
+		return fmt.Errorf("Override has no params, kernel or initrd")
+	}
+	if err := checkParams(bo.Params); err != nil {
+		return err
+	}
+	var start, end time.Time
+	var err error
+	if bo.Start != "" {
+		if start, err = time.Parse(time.RFC3339, bo.Start); err != nil {
+			return fmt.Errorf("Invalid start time: %s", err)
+		}
+	}
+	if bo.End != "" {
+		if end, err = time.Parse(time.RFC3339, bo.End); err != nil {
+			return fmt.Errorf("Invalid end time: %s", err)
+		}
+		if bo.Start != "" && !end.After(start) {
+			return fmt.Errorf("End time %s is not after the start time %s", bo.End, bo.Start)
+		}
+	}
+	bo.Consumed = nil
+	return nil
+}
+
+func overridesGetAPI(w http.ResponseWriter, r *http.Request, name string) {
+	debugf("overridesGetAPI(): Received request %v\n", r.URL)
+	if name == "" {
+		ol, err := getOverrides()
+		if err != nil {
+			base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+				fmt.Sprintf("Failed to retrieve boot overrides: %s", err))
+			return
+		}
+		if ol == nil {
+			ol = []bssTypes.BootOverride{}
+		}
+		for i := range ol {
+			overrideConsumed(&ol[i])
+		}
+		sendJSON(w, http.StatusOK, ol)
+		return
+	}
+	bo, exists, err := getOverride(name)
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+			fmt.Sprintf("Failed to retrieve boot override %s: %s", name, err))
+	} else if !exists {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound,
+			fmt.Sprintf("Boot override %s does not exist", name))
+	} else {
+		overrideConsumed(&bo)
+		sendJSON(w, http.StatusOK, bo)
+	}
+}
+
+// Store an override.  POST creates a new override, named with a UUID if the
+// request has no name.  PUT creates or replaces one, and replacing it lets
+// the hosts which have already used a one-shot override use it again.
+func overridesStoreAPI(w http.ResponseWriter, r *http.Request, name string) {
+	debugf("overridesStoreAPI(): Received request %v\n", r.URL)
+	var bo bssTypes.BootOverride
+	body, err := ioutil.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(body, &bo)
+	}
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+			fmt.Sprintf("Bad Request: %s", err))
+		return
+	}
+	if name != "" {
+		if bo.Name != "" && bo.Name != name {
+			base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+				fmt.Sprintf("Override name '%s' does not match the path", bo.Name))
+			return
+		}
+		bo.Name = name
+	} else if bo.Name == "" {
+		bo.Name = uuid.New().String()
+	}
+	if err = checkOverride(&bo); err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+			fmt.Sprintf("Invalid boot override: %s", err))
+		return
+	}
+
+	kvMutex.Lock()
+	defer kvMutex.Unlock()
+	if r.Method == http.MethodPost {
+		if _, exists, _ := getOverride(bo.Name); exists {
+			base.SendProblemDetailsGeneric(w, http.StatusConflict,
+				fmt.Sprintf("Boot override %s already exists", bo.Name))
+			return
+		}
+	}
+	err = clearConsumed(bo.Name)
+	if err == nil {
+		err = storeData(overridesPfx+bo.Name, bo)
+	}
+	if err == nil {
+		err = overridesChanged()
+	}
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Printf("Stored boot override %s for %v", bo.Name, bo.Hosts)
+	status := http.StatusOK
+	if r.Method == http.MethodPost {
+		status = http.StatusCreated
+	}
+	sendJSON(w, status, bo)
+}
+
+func overridesDeleteAPI(w http.ResponseWriter, r *http.Request, name string) {
+	debugf("overridesDeleteAPI(): Received request %v\n", r.URL)
+	kvMutex.Lock()
+	defer kvMutex.Unlock()
+	_, exists, err := getOverride(name)
+	if err == nil && !exists {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound,
+			fmt.Sprintf("Boot override %s does not exist", name))
+		return
+	}
+	if err == nil {
+		err = kvstore.Delete(overridesPfx + name)
+	}
+	if err == nil {
+		err = clearConsumed(name)
+	}
+	if err == nil {
+		err = overridesChanged()
+	}
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+			fmt.Sprintf("Failed to delete boot override %s: %s", name, err))
+		return
+	}
+	log.Printf("Deleted boot override %s", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// The override a boot parameters entry's host boots with now, if any, for
+// GET /bootparameters.
+func entryOverride(ol []bssTypes.BootOverride, name string, now time.Time) *bssTypes.BootOverride {
+	if len(ol) == 0 {
+		return nil
+	}
+	comp, _ := FindSMCompByName(name)
+	if bo, ok := selectOverride(ol, name, comp.Role, now); ok {
+		return &bo
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+func TestOverridesAPI(t *testing.T) {
+	rr := serveRequest(t, overrides, http.MethodPost, "/overrides", `{"hosts":["x0c0s3b0n0"],"kernel":"http://images/diag/kernel","one-shot":true}`)
+	var bo bssTypes.BootOverride
+	if rr.Code != http.StatusCreated || json.Unmarshal(rr.Body.Bytes(), &bo) != nil || bo.Name == "" {
+		t.Fatalf("POST returned %d: %s", rr.Code, rr.Body)
+	}
+	defer serveRequest(t, overrides, http.MethodDelete, "/overrides/"+bo.Name, nil)
+
+	if rr = serveRequest(t, overrides, http.MethodPost, "/overrides", `{"name":"`+bo.Name+`","hosts":["x0c0s3b0n0"],"params":"quiet"}`); rr.Code != http.StatusConflict {
+		t.Errorf("POST of an existing override returned %d", rr.Code)
+	}
+	for _, body := range []string{
+		`{"params":"quiet"}`,
+		`{"hosts":["x0c0s3b0n0"]}`,
+		`{"hosts":["x0c0s3b0n0"],"params":"quiet","start":"tomorrow"}`,
+		`{"hosts":["x0c0s3b0n0"],"params":"quiet","start":"2026-10-16T02:00:00Z","end":"2026-10-16T01:00:00Z"}`,
+	} {
+		if rr = serveRequest(t, overrides, http.MethodPut, "/overrides/bad", body); rr.Code != http.StatusBadRequest {
+			t.Errorf("PUT %s returned %d", body, rr.Code)
+		}
+	}
+
+	rr = serveRequest(t, overrides, http.MethodPut, "/overrides/window", `{"hosts":["Compute"],"params":"quiet","end":"2026-01-01T00:00:00Z"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("PUT returned %d: %s", rr.Code, rr.Body)
+	}
+	var ol []bssTypes.BootOverride
+	rr = serveRequest(t, overrides, http.MethodGet, "/overrides", nil)
+	if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &ol) != nil || len(ol) != 2 {
+		t.Errorf("GET returned %d: %s", rr.Code, rr.Body)
+	}
+	if rr = serveRequest(t, overrides, http.MethodDelete, "/overrides/window", nil); rr.Code != http.StatusNoContent {
+		t.Errorf("DELETE returned %d", rr.Code)
+	}
+	if rr = serveRequest(t, overrides, http.MethodGet, "/overrides/window", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("GET of a deleted override returned %d", rr.Code)
+	}
+}
+
+func TestSelectOverride(t *testing.T) {
+	now := time.Date(2026, 10, 16, 3, 0, 0, 0, time.UTC)
+	ol := []bssTypes.BootOverride{
+		{Name: "default", Hosts: []string{"Default"}, Params: "d"},
+		{Name: "role-early", Hosts: []string{"Compute"}, Params: "r1", Start: "2026-10-16T01:00:00Z"},
+		{Name: "role-late", Hosts: []string{"Compute"}, Params: "r2", Start: "2026-10-16T02:00:00Z"},
+		{Name: "host-future", Hosts: []string{"x9c0s0b0n0"}, Params: "h1", Start: "2026-10-17T00:00:00Z"},
+		{Name: "host-expired", Hosts: []string{"x9c0s0b0n0"}, Params: "h2", End: "2026-10-16T03:00:00Z"},
+	}
+	tests := []struct {
+		host, role, want string
+	}{
+		{"x9c0s0b0n0", "Compute", "role-late"},
+		{"x9c0s1b0n0", "Management", "default"},
+	}
+	for _, tt := range tests {
+		bo, ok := selectOverride(ol, tt.host, tt.role, now)
+		if !ok || bo.Name != tt.want {
+			t.Errorf("selectOverride(%s, %s) = %s, %v, expected %s", tt.host, tt.role, bo.Name, ok, tt.want)
+		}
+	}
+	if bo, ok := selectOverride(ol[3:], "x9c0s0b0n0", "", now); ok {
+		t.Errorf("Override %s is outside its window", bo.Name)
+	}
+}
+
+func TestOneShotOverride(t *testing.T) {
+	Store(bssTypes.BootParams{Hosts: []string{"x0c0s2b0n0"}, Params: "prod", Kernel: "http://images/prod/kernel"})
+	defer deleteHost("x0c0s2b0n0")
+	cleanupImages(t, kernelImageType, "http://images/prod/kernel")
+	rr := serveRequest(t, overrides, http.MethodPut, "/overrides/diag", `{"hosts":["x0c0s2b0n0"],"params":"diag","kernel":"http://images/diag/kernel","one-shot":true}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("PUT returned %d: %s", rr.Code, rr.Body)
+	}
+	defer serveRequest(t, overrides, http.MethodDelete, "/overrides/diag", nil)
+
+	// The override is shown by GET bootparameters until it is used
+	rr = serveRequest(t, bootParameters, http.MethodGet, "/bootparameters?name=x0c0s2b0n0", nil)
+	var bps []bssTypes.BootParams
+	json.Unmarshal(rr.Body.Bytes(), &bps)
+	if len(bps) != 1 || bps[0].Override == nil || bps[0].Override.Name != "diag" {
+		t.Errorf("GET bootparameters did not show the override: %s", rr.Body)
+	}
+
+	bootscript := func() string {
+		rr := serveRequest(t, bootScript, http.MethodGet, "/bootscript?name=x0c0s2b0n0", nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GET bootscript returned %d: %s", rr.Code, rr.Body)
+		}
+		return rr.Body.String()
+	}
+	if s := bootscript(); !strings.Contains(s, "http://images/diag/kernel diag ") {
+		t.Errorf("First boot did not use the override:\n%s", s)
+	}
+	if s := bootscript(); !strings.Contains(s, "http://images/prod/kernel prod ") {
+		t.Errorf("Second boot used the override again:\n%s", s)
+	}
+
+	rr = serveRequest(t, overrides, http.MethodGet, "/overrides/diag", nil)
+	var bo bssTypes.BootOverride
+	json.Unmarshal(rr.Body.Bytes(), &bo)
+	if _, ok := bo.Consumed["x0c0s2b0n0"]; !ok {
+		t.Errorf("Override use was not recorded: %+v", bo)
+	}
+
+	// Replacing the override lets the host use it again
+	serveRequest(t, overrides, http.MethodPut, "/overrides/diag", `{"hosts":["x0c0s2b0n0"],"params":"diag","kernel":"http://images/diag/kernel","one-shot":true}`)
+	if s := bootscript(); !strings.Contains(s, "http://images/diag/kernel diag ") {
+		t.Errorf("Replaced override was not used:\n%s", s)
+	}
+
+	// Another replica uses the override after this one cached it
+	serveRequest(t, overrides, http.MethodPut, "/overrides/diag", `{"hosts":["x0c0s2b0n0"],"params":"diag","kernel":"http://images/diag/kernel","one-shot":true}`)
+	if _, ok := activeOverride("x0c0s2b0n0", ""); !ok {
+		t.Fatalf("Replaced override is not active")
+	}
+	kvstore.Store(overrideConsumedKey("diag", "x0c0s2b0n0"), "0")
+	if s := bootscript(); !strings.Contains(s, "http://images/prod/kernel prod ") {
+		t.Errorf("Override used by another replica was used again:\n%s", s)
+	}
+}
@@ -0,0 +1,340 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+/*
+ * Boot profiles
+ *
+ * A boot profile is a named set of kernel, initrd, params and cloud-init
+ * data.  Host (or role) entries reference a profile by name instead of
+ * carrying their own copy, so that changing the kernel of thousands of nodes
+ * is a single update.  Profiles are kept under their own prefix in the same
+ * BootDataStore format as the host entries.  When a host is looked up for
+ * booting, any values set in the host entry override those of the profile.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+const profilesPfx = "/profiles/"
+
+func lookupProfile(name string) (BootDataStore, bool, error) {
+	var pds BootDataStore
+	val, exists, err := kvstore.Get(profilesPfx + name)
+	if err == nil && exists {
+		err = json.Unmarshal([]byte(val), &pds)
+	}
+	return pds, exists, err
+}
+
+// Make sure a profile referenced by boot parameters exists.
+func checkProfileRef(name string) error {
+	_, exists, err := lookupProfile(name)
+	if err == nil && !exists {
+		err = fmt.Errorf("Boot profile %s does not exist", name)
+	}
+	if err != nil {
+		msg := err.Error()
+		herr := base.NewHMSError("Storage", msg)
+		herr.AddProblem(base.NewProblemDetailsStatus(msg, http.StatusBadRequest))
+		return herr
+	}
+	return nil
+}
+
+func mergeCloudData(profile, host bssTypes.CloudDataType) bssTypes.CloudDataType {
+	if len(profile) == 0 {
+		return host
+	}
+	ret := make(bssTypes.CloudDataType, len(profile)+len(host))
+	for k, v := range profile {
+		ret[k] = v
+	}
+	for k, v := range host {
+		ret[k] = v
+	}
+	return ret
+}
+
+// Layer a host entry over the profile it references.  Values set in the host
+// entry win, and the cloud-init meta-data and user-data are merged key by key,
+// as are the fields of the architecture variants.
+// If the profile cannot be read, the host entry is used as it is.
+func withProfile(bds BootDataStore) BootDataStore {
+	if bds.Profile == "" {
+		return bds
+	}
+	pds, exists, err := lookupProfile(bds.Profile)
+	if err != nil || !exists {
+		log.Printf("Boot profile %s is not available: %v", bds.Profile, err)
+		return bds
+	}
+	ret := bds
+	if ret.Params == "" {
+		ret.Params = pds.Params
+	}
+	if ret.Kernel == "" {
+		ret.Kernel = pds.Kernel
+	}
+	if ret.Initrd == "" {
+		ret.Initrd = pds.Initrd
+	}
+	ret.CloudInit.MetaData = mergeCloudData(pds.CloudInit.MetaData, bds.CloudInit.MetaData)
+	ret.CloudInit.UserData = mergeCloudData(pds.CloudInit.UserData, bds.CloudInit.UserData)
+	ret.ArchVariants = overlayArchVariants(pds.ArchVariants, bds.ArchVariants)
+	return ret
+}
+
+func profileConvert(name string, pds BootDataStore) bssTypes.BootProfile {
+	bd := bdConvert(pds)
+	return bssTypes.BootProfile{
+		Name:      name,
+		Params:    bd.Params,
+		Kernel:    bd.Kernel.Path,
+		Initrd:    bd.Initrd.Path,
+		CloudInit: bd.CloudInit,
+
+		ArchVariants: archVariantsAPI(bd.ArchVariants),
+	}
+}
+
+func getProfiles() ([]bssTypes.BootProfile, error) {
+	kvl, err := kvstore.GetRange(profilesPfx+keyMin, profilesPfx+keyMax)
+	if err != nil {
+		return nil, err
+	}
+	var ret []bssTypes.BootProfile
+	for _, kv := range kvl {
+		var pds BootDataStore
+		if e := json.Unmarshal([]byte(kv.Value), &pds); e != nil {
+			log.Printf("Skipping bad boot profile %s: %s", kv.Key, e)
+			continue
+		}
+		ret = append(ret, profileConvert(strings.TrimPrefix(kv.Key, profilesPfx), pds))
+	}
+	return ret, nil
+}
+
+// Names of the host and role entries which reference a profile.
+func profileHosts(name string) ([]string, error) {
+	kvl, err := getTags()
+	if err != nil {
+		return nil, err
+	}
+	hosts := []string{}
+	for _, kv := range kvl {
+		var bds BootDataStore
+		if json.Unmarshal([]byte(kv.Value), &bds) == nil && bds.Profile == name {
+			hosts = append(hosts, extractParamName(kv))
+		}
+	}
+	sort.Strings(hosts)
+	return hosts, nil
+}
+
+// Convert a profile to its storage format, along with the writes of the
+// images it references once they are validated.
+func profileStore(bp bssTypes.BootProfile) (BootDataStore, []kvOp, error) {
+	pds := BootDataStore{Params: bp.Params, CloudInit: bp.CloudInit}
+	checks, err := checkImagePaths(bp.Kernel, bp.Initrd)
+	defer recordImageChecks(checks)
+	if err != nil {
+		return pds, nil, err
+	}
+	kernelOp, initrdOp, err := imageOps(bp.Kernel, bp.Initrd)
+	if err != nil {
+		return pds, nil, err
+	}
+	pds.Kernel, pds.Initrd = kernelOp.key, initrdOp.key
+	// Empty variants are kept, for a PATCH to remove the architecture
+	variants, images, vchecks, err := storeArchVariants(bp.ArchVariants)
+	defer recordImageChecks(vchecks)
+	pds.ArchVariants = variants
+	return pds, appendImageOps(images, kernelOp, initrdOp), err
+}
+
+func profilesGetAPI(w http.ResponseWriter, r *http.Request, name string) {
+	debugf("profilesGetAPI(): Received request %v\n", r.URL)
+	if name == "" {
+		pl, err := getProfiles()
+		if err != nil {
+			base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+				fmt.Sprintf("Failed to retrieve boot profiles: %s", err))
+			return
+		}
+		if pl == nil {
+			pl = []bssTypes.BootProfile{}
+		}
+		sendJSON(w, http.StatusOK, pl)
+		return
+	}
+	pds, exists, err := lookupProfile(name)
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+			fmt.Sprintf("Failed to retrieve boot profile %s: %s", name, err))
+	} else if !exists {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound,
+			fmt.Sprintf("Boot profile %s does not exist", name))
+	} else {
+		sendJSON(w, http.StatusOK, profileConvert(name, pds))
+	}
+}
+
+func profileHostsGetAPI(w http.ResponseWriter, r *http.Request, name string) {
+	debugf("profileHostsGetAPI(): Received request %v\n", r.URL)
+	if _, exists, err := lookupProfile(name); err == nil && !exists {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound,
+			fmt.Sprintf("Boot profile %s does not exist", name))
+		return
+	}
+	hosts, err := profileHosts(name)
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+			fmt.Sprintf("Failed to find hosts using boot profile %s: %s", name, err))
+		return
+	}
+	sendJSON(w, http.StatusOK, hosts)
+}
+
+// Create or modify a profile.  POST creates a new profile, PUT creates or
+// replaces one and PATCH updates the fields which are set in the request.
+func profilesStoreAPI(w http.ResponseWriter, r *http.Request, name string) {
+	debugf("profilesStoreAPI(): Received request %v\n", r.URL)
+	var bp bssTypes.BootProfile
+	body, err := ioutil.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(body, &bp)
+	}
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+			fmt.Sprintf("Bad Request: %s", err))
+		return
+	}
+	if name != "" {
+		if bp.Name != "" && bp.Name != name {
+			base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+				fmt.Sprintf("Boot profile name '%s' does not match the path", bp.Name))
+			return
+		}
+		bp.Name = name
+	}
+	if bp.Name == "" || strings.ContainsAny(bp.Name, "/ \t\n") {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+			fmt.Sprintf("Invalid boot profile name '%s'", bp.Name))
+		return
+	}
+
+	kvMutex.Lock()
+	existing, exists, err := lookupProfile(bp.Name)
+	kvMutex.Unlock()
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+			fmt.Sprintf("Failed to retrieve boot profile %s: %s", bp.Name, err))
+		return
+	}
+	switch {
+	case r.Method == http.MethodPost && exists:
+		base.SendProblemDetailsGeneric(w, http.StatusConflict,
+			fmt.Sprintf("Boot profile %s already exists", bp.Name))
+		return
+	case r.Method == http.MethodPatch && !exists:
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound,
+			fmt.Sprintf("Boot profile %s does not exist", bp.Name))
+		return
+	}
+
+	pds, images, err := profileStore(bp)
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if r.Method == http.MethodPatch {
+		if pds.Params != "" {
+			existing.Params = pds.Params
+		}
+		if pds.Kernel != "" {
+			existing.Kernel = pds.Kernel
+		}
+		if pds.Initrd != "" {
+			existing.Initrd = pds.Initrd
+		}
+		updateCloudInit(&existing.CloudInit, bp.CloudInit)
+		existing.ArchVariants = mergeArchVariants(existing.ArchVariants, pds.ArchVariants)
+		pds = existing
+	} else {
+		pds.ArchVariants = mergeArchVariants(nil, pds.ArchVariants)
+	}
+	key := profilesPfx + bp.Name
+	if err = applyEntryOps(key, append([]kvOp{storeOp(key, pds)}, images...), changeRequest{who: requestUser(r)}); err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Printf("Boot profile %s stored (%s)", bp.Name, r.Method)
+	status := http.StatusOK
+	if r.Method == http.MethodPost {
+		status = http.StatusCreated
+	}
+	sendJSON(w, status, nil)
+}
+
+// Delete a profile.  A profile which is still referenced cannot be deleted.
+func profilesDeleteAPI(w http.ResponseWriter, r *http.Request, name string) {
+	debugf("profilesDeleteAPI(): Received request %v\n", r.URL)
+	kvMutex.Lock()
+	defer kvMutex.Unlock()
+	_, exists, err := lookupProfile(name)
+	if err == nil && !exists {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound,
+			fmt.Sprintf("Boot profile %s does not exist", name))
+		return
+	}
+	var hosts []string
+	if err == nil {
+		hosts, err = profileHosts(name)
+	}
+	if err == nil && len(hosts) > 0 {
+		base.SendProblemDetailsGeneric(w, http.StatusConflict,
+			fmt.Sprintf("Boot profile %s is in use by %s", name, strings.Join(hosts, ",")))
+		return
+	}
+	if err == nil {
+		err = deleteEntry(profilesPfx+name, changeRequest{who: requestUser(r)})
+	}
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+			fmt.Sprintf("Failed to delete boot profile %s: %s", name, err))
+		return
+	}
+	log.Printf("Deleted boot profile %s", name)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,111 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+func TestProfiles(t *testing.T) {
+	prof := bssTypes.BootProfile{
+		Name:   "cos-2.5",
+		Kernel: "http://images/cos-2.5/kernel",
+		Initrd: "http://images/cos-2.5/initrd",
+		Params: "console=ttyS0 quiet",
+		CloudInit: bssTypes.CloudInit{
+			MetaData: bssTypes.CloudDataType{"site": "lab", "rack": "x9"},
+		},
+	}
+	patch := bssTypes.BootProfile{Kernel: "http://images/cos-2.6/kernel"}
+	cleanupImages(t, kernelImageType, prof.Kernel, patch.Kernel)
+	cleanupImages(t, initrdImageType, prof.Initrd)
+	if rr := serveRequest(t, profiles, http.MethodPost, "/profiles", prof); rr.Code != http.StatusCreated {
+		t.Fatalf("POST returned %d: %s", rr.Code, rr.Body)
+	}
+	if rr := serveRequest(t, profiles, http.MethodPost, "/profiles", prof); rr.Code != http.StatusConflict {
+		t.Errorf("Second POST returned %d, expected %d", rr.Code, http.StatusConflict)
+	}
+
+	// Host entries may reference existing profiles only
+	err, _ := Store(bssTypes.BootParams{Hosts: []string{"x9c0s1b0n0"}, Profile: "no-such-profile"})
+	if err == nil {
+		t.Errorf("Storing a reference to a missing profile succeeded")
+	}
+	host := bssTypes.BootParams{
+		Hosts:     []string{"x9c0s1b0n0"},
+		Profile:   prof.Name,
+		Params:    "console=ttyS1",
+		CloudInit: bssTypes.CloudInit{MetaData: bssTypes.CloudDataType{"rack": "x9000"}},
+	}
+	if err, _ = Store(host); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	defer deleteHost("x9c0s1b0n0")
+
+	bd := lookup("x9c0s1b0n0", "", "", "")
+	if bd.Kernel.Path != prof.Kernel || bd.Initrd.Path != prof.Initrd {
+		t.Errorf("Profile images not used: %+v", bd)
+	}
+	if bd.Params != "console=ttyS1" {
+		t.Errorf("Host params did not override the profile, got '%s'", bd.Params)
+	}
+	if bd.CloudInit.MetaData["site"] != "lab" || bd.CloudInit.MetaData["rack"] != "x9000" {
+		t.Errorf("Cloud-init meta-data not merged: %v", bd.CloudInit.MetaData)
+	}
+
+	// Changing the profile changes every host using it
+	if rr := serveRequest(t, profiles, http.MethodPatch, "/profiles/"+prof.Name, patch); rr.Code != http.StatusOK {
+		t.Fatalf("PATCH returned %d: %s", rr.Code, rr.Body)
+	}
+	if bd = lookup("x9c0s1b0n0", "", "", ""); bd.Kernel.Path != patch.Kernel {
+		t.Errorf("Profile update not seen, kernel is %s", bd.Kernel.Path)
+	}
+	rr := serveRequest(t, profiles, http.MethodGet, "/profiles/"+prof.Name, nil)
+	var got bssTypes.BootProfile
+	json.Unmarshal(rr.Body.Bytes(), &got)
+	if got.Kernel != patch.Kernel || got.Initrd != prof.Initrd || got.Params != prof.Params {
+		t.Errorf("PATCH changed more than the kernel: %+v", got)
+	}
+
+	rr = serveRequest(t, profiles, http.MethodGet, "/profiles/"+prof.Name+"/hosts", nil)
+	var hosts []string
+	json.Unmarshal(rr.Body.Bytes(), &hosts)
+	if len(hosts) != 1 || hosts[0] != "x9c0s1b0n0" {
+		t.Errorf("Expected x9c0s1b0n0 to use the profile, got %v", hosts)
+	}
+
+	if rr = serveRequest(t, profiles, http.MethodDelete, "/profiles/"+prof.Name, nil); rr.Code != http.StatusConflict {
+		t.Errorf("DELETE of a profile in use returned %d, expected %d", rr.Code, http.StatusConflict)
+	}
+	deleteHost("x9c0s1b0n0")
+	if rr = serveRequest(t, profiles, http.MethodDelete, "/profiles/"+prof.Name, nil); rr.Code != http.StatusNoContent {
+		t.Errorf("DELETE returned %d: %s", rr.Code, rr.Body)
+	}
+	if rr = serveRequest(t, profiles, http.MethodGet, "/profiles/"+prof.Name, nil); rr.Code != http.StatusNotFound {
+		t.Errorf("GET of a deleted profile returned %d", rr.Code)
+	}
+}
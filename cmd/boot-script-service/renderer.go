@@ -0,0 +1,256 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+/*
+ * Boot script renderers
+ *
+ * A renderer turns the kernel, initrd and kernel parameters BSS has resolved
+ * for a node into a script for the boot loader the node runs.  iPXE is the
+ * default.  GRUB2 is provided for nodes which PXE or UEFI HTTP boot straight
+ * into GRUB and fetch their grub.cfg from BSS.
+ *
+ * The renderer is picked with the format= query parameter of /bootscript,
+ * or else by the role of the node using the BSS_ROLE_BOOT_FORMATS setting,
+ * a comma separated list of Role=format or Role:SubRole=format entries.
+ */
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/Cray-HPE/hms-bss/pkg/cmdline"
+)
+
+const defaultBootFormat = "ipxe"
+
+type bootRenderer interface {
+	// Value of the format= query parameter selecting this renderer.
+	name() string
+
+	contentType() string
+
+	// Boot loader variables expanding to the architecture and MAC address of
+	// the node.  These are used to chain unknown nodes back to BSS.
+	archVar() string
+	macVar() string
+
+	// Script that boots the kernel and initrd with the params.  If booting
+	// fails, the script sleeps for Delay seconds and reloads ChainURL.
+	boot(d bootScriptData) string
+
+	// Script that sleeps for Delay seconds and reloads ChainURL.
+	chain(d bootScriptData) string
+}
+
+// Everything known about a boot script request.  This is handed to the
+// renderers, and is the data boot script templates are executed with.
+type bootScriptData struct {
+	Kernel        string // Kernel URL, with S3 URLs already signed
+	Initrd        string // Initrd URL, empty if there is none
+	Params        string // Kernel parameters
+	Xname         string
+	Nid           string
+	Mac           string
+	Role          string
+	SubRole       string
+	ReferralToken string
+	Arch          string // Boot loader variable for the node architecture
+	ChainURL      string // URL for retrying, or for the next request
+	Delay         uint   // Seconds to wait before loading ChainURL
+}
+
+var bootRenderers = map[string]bootRenderer{
+	"ipxe": ipxeRenderer{},
+	"grub": grubRenderer{},
+}
+
+// Boot format by "Role" or "Role:SubRole", from BSS_ROLE_BOOT_FORMATS.
+var roleBootFormats = map[string]string{}
+
+func bootFormatNames() string {
+	var names []string
+	for n := range bootRenderers {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// Set the role to boot format mapping from a list of Role=format or
+// Role:SubRole=format entries.
+func setRoleBootFormats(list []string) error {
+	formats := make(map[string]string)
+	for _, ent := range list {
+		ent = strings.TrimSpace(ent)
+		if ent == "" {
+			continue
+		}
+		kv := strings.SplitN(ent, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return fmt.Errorf("invalid role boot format '%s', expected Role=format", ent)
+		}
+		format := strings.ToLower(strings.TrimSpace(kv[1]))
+		if _, ok := bootRenderers[format]; !ok {
+			return fmt.Errorf("unknown boot format '%s' for role %s, expected one of %s",
+				kv[1], kv[0], bootFormatNames())
+		}
+		formats[strings.ToLower(strings.TrimSpace(kv[0]))] = format
+	}
+	roleBootFormats = formats
+	return nil
+}
+
+// Select the renderer for a request.  An explicit format wins, then the
+// role:subrole and role mappings, and then iPXE.
+func selectRenderer(format, role, subRole string) (bootRenderer, error) {
+	if format != "" {
+		if r, ok := bootRenderers[strings.ToLower(format)]; ok {
+			return r, nil
+		}
+		return nil, fmt.Errorf("unknown boot script format '%s', expected one of %s",
+			format, bootFormatNames())
+	}
+	role = strings.ToLower(role)
+	if role != "" {
+		if f, ok := roleBootFormats[role+":"+strings.ToLower(subRole)]; ok && subRole != "" {
+			return bootRenderers[f], nil
+		}
+		if f, ok := roleBootFormats[role]; ok {
+			return bootRenderers[f], nil
+		}
+	}
+	return bootRenderers[defaultBootFormat], nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// iPXE
+//////////////////////////////////////////////////////////////////////////////
+
+type ipxeRenderer struct{}
+
+func (ipxeRenderer) name() string        { return "ipxe" }
+func (ipxeRenderer) contentType() string { return "text/plain; charset=UTF-8" }
+func (ipxeRenderer) archVar() string     { return "${buildarch}" }
+func (ipxeRenderer) macVar() string      { return "${net/net0}" }
+
+func (ipxeRenderer) boot(d bootScriptData) string {
+	params := d.Params
+	script := "#!ipxe\n"
+	if d.Initrd != "" {
+		// Name the initrd so that EFI kernels can find it
+		params = "initrd=initrd " + params
+	}
+	script += "kernel --name kernel " + d.Kernel + " " + strings.Trim(params, " ")
+	script += " || goto boot_retry\n"
+	if d.Initrd != "" {
+		script += "initrd --name initrd " + d.Initrd + " || goto boot_retry\n"
+	}
+	script += "boot || goto boot_retry\n:boot_retry\n"
+	// We could vary the length of the sleep based on retry count or some
+	// other criteria.
+	// For now, just sleep a bit
+	script += fmt.Sprintf("sleep %d\n", d.Delay) + "chain " + d.ChainURL + "\n"
+	return script
+}
+
+func (ipxeRenderer) chain(d bootScriptData) string {
+	return fmt.Sprintf("#!ipxe\nsleep %d\n", d.Delay) + "chain " + d.ChainURL + "\n"
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// GRUB2
+//////////////////////////////////////////////////////////////////////////////
+
+// GRUB cannot fetch https URLs, so the images and BSS_CHAIN_PROTO must be
+// http (or tftp) for nodes booted this way.
+type grubRenderer struct{}
+
+func (grubRenderer) name() string        { return "grub" }
+func (grubRenderer) contentType() string { return "text/plain; charset=UTF-8" }
+func (grubRenderer) archVar() string     { return "${grub_cpu}" }
+func (grubRenderer) macVar() string      { return "${net_default_mac}" }
+
+// Convert a URL to a GRUB network path, e.g. http://host/x becomes
+// (http,host)/x.  Anything else is assumed to already be a GRUB path.
+func grubPath(u string) string {
+	p, err := url.Parse(u)
+	if err != nil || p.Host == "" {
+		return u
+	}
+	scheme := strings.ToLower(p.Scheme)
+	if scheme != "http" && scheme != "tftp" {
+		return u
+	}
+	path := p.EscapedPath()
+	if p.RawQuery != "" {
+		path += "?" + p.RawQuery
+	}
+	return "(" + scheme + "," + p.Host + ")" + path
+}
+
+const grubSafeChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789" +
+	"_-+=,./:@%()"
+
+// Quote a word for grub.cfg.  GRUB script treats characters such as ; & and
+// $ specially, and kernel parameters often contain them.
+func grubQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if strings.Trim(s, grubSafeChars) == "" {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// Quote a word, still allowing GRUB to expand variables in it.
+func grubQuoteExpand(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(s) + `"`
+}
+
+func (g grubRenderer) boot(d bootScriptData) string {
+	script := "linux " + grubQuote(grubPath(d.Kernel))
+	// GRUB adds its own quoting when it passes a word containing spaces on
+	// to the kernel, so the words are given to it unquoted.
+	for _, p := range cmdline.Split(d.Params) {
+		script += " " + grubQuote(p)
+	}
+	script = "if " + script + "; then\n"
+	if d.Initrd != "" {
+		script += "  if initrd " + grubQuote(grubPath(d.Initrd)) + "; then\n"
+		script += "    boot\n"
+		script += "  fi\n"
+	} else {
+		script += "  boot\n"
+	}
+	script += "fi\n"
+	return script + g.chain(d)
+}
+
+func (grubRenderer) chain(d bootScriptData) string {
+	return fmt.Sprintf("sleep %d\n", d.Delay) + "configfile " + grubQuoteExpand(grubPath(d.ChainURL)) + "\n"
+}
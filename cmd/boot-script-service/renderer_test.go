@@ -0,0 +1,122 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "Rewrite the golden files in testdata")
+
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	file := filepath.Join("testdata", name+".golden")
+	if *updateGolden {
+		if err := ioutil.WriteFile(file, []byte(got), 0644); err != nil {
+			t.Fatalf("Failed to update %s: %v", file, err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", file, err)
+	}
+	if got != string(want) {
+		t.Errorf("%s mismatch\n--- got:\n%s\n--- want:\n%s", file, got, want)
+	}
+}
+
+func TestRenderers(t *testing.T) {
+	saveAddr, saveDelay := advertiseAddress, retryDelay
+	advertiseAddress = "http://10.92.100.81:8888"
+	retryDelay = 30
+	defer func() { advertiseAddress, retryDelay = saveAddr, saveDelay }()
+
+	bd := BootData{
+		Params: "console=ttyS0,115200 initrd=old-initrd rd.shell quiet",
+		Kernel: ImageData{Path: "http://rgw-vip.nmn/boot-images/k1/kernel"},
+		Initrd: ImageData{Path: "http://rgw-vip.nmn/boot-images/k1/initrd"},
+	}
+	noInitrd := BootData{
+		Params: "console=ttyS0,115200 root='live:LABEL=my root'",
+		Kernel: ImageData{Path: "http://rgw-vip.nmn/boot-images/k2/kernel"},
+	}
+	sp := scriptParams{"x3000c0s17b3n0", "3", "00000000-0000-0000-0000-000000000000", "b4:2e:99:df:eb:bf"}
+	chain := "http://api-gw-service-nmn.local/apis/bss/boot/v1/bootscript?mac=b4:2e:99:df:eb:bf&retry=1"
+	unknown := "http://api-gw-service-nmn.local/apis/bss/boot/v1/bootscript?mac=b4:2e:99:df:eb:bf&arch="
+
+	for _, format := range []string{"ipxe", "grub"} {
+		rdr, err := selectRenderer(format, "", "")
+		if err != nil {
+			t.Fatalf("selectRenderer(%s) failed: %v", format, err)
+		}
+		script, err := buildBootScript(bd, sp, rdr, chain, "Compute", "", "test")
+		if err != nil {
+			t.Fatalf("%s: buildBootScript failed: %v", format, err)
+		}
+		checkGolden(t, "bootscript-"+format, script)
+
+		script, err = buildBootScript(noInitrd, sp, rdr, chain, "Compute", "", "test")
+		if err != nil {
+			t.Fatalf("%s: buildBootScript failed: %v", format, err)
+		}
+		checkGolden(t, "bootscript-"+format+"-noinitrd", script)
+
+		checkGolden(t, "bootscript-"+format+"-chain", rdr.chain(bootScriptData{ChainURL: unknown + rdr.archVar(), Delay: 10}))
+	}
+}
+
+func TestSelectRenderer(t *testing.T) {
+	defer setRoleBootFormats(nil)
+	if err := setRoleBootFormats([]string{"Storage=grub", "Management:Master=GRUB"}); err != nil {
+		t.Fatalf("setRoleBootFormats failed: %v", err)
+	}
+	tests := []struct {
+		format, role, subRole string
+		want                  string
+	}{
+		{"", "", "", "ipxe"},
+		{"", "Compute", "", "ipxe"},
+		{"", "Storage", "", "grub"},
+		{"ipxe", "Storage", "", "ipxe"},
+		{"", "Management", "Worker", "ipxe"},
+		{"", "Management", "Master", "grub"},
+		{"GRUB", "", "", "grub"},
+	}
+	for _, tt := range tests {
+		rdr, err := selectRenderer(tt.format, tt.role, tt.subRole)
+		if err != nil || rdr.name() != tt.want {
+			t.Errorf("selectRenderer(%q, %q, %q) = %v, %v, expected %s",
+				tt.format, tt.role, tt.subRole, rdr, err, tt.want)
+		}
+	}
+	if _, err := selectRenderer("pxelinux", "", ""); err == nil {
+		t.Errorf("Expected an unknown format to fail")
+	}
+	if err := setRoleBootFormats([]string{"Compute=pxelinux"}); err == nil {
+		t.Errorf("Expected an unknown role format to fail")
+	}
+}
@@ -36,9 +36,12 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	base "github.com/Cray-HPE/hms-base"
+	"log"
 	"net/http"
+	"strings"
 )
 
 const (
@@ -54,6 +57,8 @@ func initHandlers() {
 	http.HandleFunc(baseEndpoint+"/", Index)
 	// config
 	http.HandleFunc(baseEndpoint+"/bootparameters", bootParameters)
+	http.HandleFunc(baseEndpoint+"/bootparameters/explain", paramsExplain)
+	http.HandleFunc(baseEndpoint+"/bootparameters/arch-check", archCheck)
 	// boot
 	http.HandleFunc(baseEndpoint+"/bootscript", bootScript)
 	http.HandleFunc(baseEndpoint+"/hosts", hosts)
@@ -67,6 +72,32 @@ func initHandlers() {
 	http.HandleFunc(notifierEndpoint, scn)
 	// endpoint-access
 	http.HandleFunc(baseEndpoint+"/endpoint-history", endpointHistoryGet)
+	// boot script templates
+	http.HandleFunc(baseEndpoint+"/templates", templates)
+	http.HandleFunc(baseEndpoint+"/templates/", templates)
+	// boot profiles
+	http.HandleFunc(baseEndpoint+"/profiles", profiles)
+	http.HandleFunc(baseEndpoint+"/profiles/", profiles)
+	// boot overrides
+	http.HandleFunc(baseEndpoint+"/overrides", overrides)
+	http.HandleFunc(baseEndpoint+"/overrides/", overrides)
+	// image catalog
+	http.HandleFunc(baseEndpoint+"/images", images)
+	http.HandleFunc(baseEndpoint+"/images/", images)
+	// inventory
+	http.HandleFunc(baseEndpoint+"/inventory", inventory)
+	http.HandleFunc(baseEndpoint+"/inventory/", inventory)
+	// boot activity events
+	http.HandleFunc(baseEndpoint+"/events", eventStream)
+	// boot sessions
+	http.HandleFunc(baseEndpoint+"/bootsessions", bootSessions)
+	http.HandleFunc(baseEndpoint+"/bootsessions/", bootSessions)
+
+	http.HandleFunc(artifactsEndpoint+"/", artifacts)
+
+	// change history
+	http.HandleFunc(baseEndpoint+"/history", history)
+	http.HandleFunc(baseEndpoint+"/history/rollback", historyRollback)
 }
 
 func Index(w http.ResponseWriter, r *http.Request) {
@@ -78,6 +109,17 @@ func sendAllowable(w http.ResponseWriter, allowable string) {
 	base.SendProblemDetailsGeneric(w, http.StatusMethodNotAllowed, "allow "+allowable)
 }
 
+// Send a response with a status, and v encoded as JSON unless it is nil.
+func sendJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	if v != nil {
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			log.Printf("Yikes, I couldn't encode a JSON status response: %s\n", err)
+		}
+	}
+}
+
 func bootParameters(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
@@ -95,6 +137,24 @@ func bootParameters(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func paramsExplain(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		paramsExplainGetAPI(w, r)
+	default:
+		sendAllowable(w, "GET")
+	}
+}
+
+func archCheck(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		archCheckGetAPI(w, r)
+	default:
+		sendAllowable(w, "GET")
+	}
+}
+
 func bootScript(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
@@ -133,6 +193,29 @@ func service(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func eventStream(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		eventsGetAPI(w, r)
+	default:
+		sendAllowable(w, "GET")
+	}
+}
+
+func bootSessions(w http.ResponseWriter, r *http.Request) {
+	xname := strings.Trim(strings.TrimPrefix(r.URL.Path, baseEndpoint+"/bootsessions"), "/")
+	switch {
+	case r.Method == http.MethodGet:
+		bootSessionsGetAPI(w, r, xname)
+	case r.Method == http.MethodDelete && xname != "":
+		bootSessionsDeleteAPI(w, r, xname)
+	case xname == "":
+		sendAllowable(w, "GET")
+	default:
+		sendAllowable(w, "GET,DELETE")
+	}
+}
+
 func scn(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
@@ -177,3 +260,151 @@ func endpointHistoryGet(w http.ResponseWriter, r *http.Request) {
 		sendAllowable(w, "GET")
 	}
 }
+
+func templates(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, baseEndpoint+"/templates"), "/")
+	switch {
+	case r.Method == http.MethodGet:
+		templatesGetAPI(w, r, name)
+	case r.Method == http.MethodPost && name == "":
+		templatesStoreAPI(w, r, name)
+	case r.Method == http.MethodPut && name != "":
+		templatesStoreAPI(w, r, name)
+	case r.Method == http.MethodDelete && name != "":
+		templatesDeleteAPI(w, r, name)
+	case name == "":
+		sendAllowable(w, "GET,POST")
+	default:
+		sendAllowable(w, "GET,PUT,DELETE")
+	}
+}
+
+func profiles(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, baseEndpoint+"/profiles"), "/")
+	if strings.HasSuffix(name, "/hosts") {
+		name = strings.TrimSuffix(name, "/hosts")
+		switch r.Method {
+		case http.MethodGet:
+			profileHostsGetAPI(w, r, name)
+		default:
+			sendAllowable(w, "GET")
+		}
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet:
+		profilesGetAPI(w, r, name)
+	case r.Method == http.MethodPost && name == "":
+		profilesStoreAPI(w, r, name)
+	case (r.Method == http.MethodPut || r.Method == http.MethodPatch) && name != "":
+		profilesStoreAPI(w, r, name)
+	case r.Method == http.MethodDelete && name != "":
+		profilesDeleteAPI(w, r, name)
+	case name == "":
+		sendAllowable(w, "GET,POST")
+	default:
+		sendAllowable(w, "GET,PUT,PATCH,DELETE")
+	}
+}
+
+func overrides(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, baseEndpoint+"/overrides"), "/")
+	switch {
+	case r.Method == http.MethodGet:
+		overridesGetAPI(w, r, name)
+	case r.Method == http.MethodPost && name == "":
+		overridesStoreAPI(w, r, name)
+	case r.Method == http.MethodPut && name != "":
+		overridesStoreAPI(w, r, name)
+	case r.Method == http.MethodDelete && name != "":
+		overridesDeleteAPI(w, r, name)
+	case name == "":
+		sendAllowable(w, "GET,POST")
+	default:
+		sendAllowable(w, "GET,PUT,DELETE")
+	}
+}
+
+func inventory(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, baseEndpoint+"/inventory"), "/")
+	_, editable := smInventory.(etcdInventory)
+	switch {
+	case r.Method == http.MethodGet:
+		inventoryGetAPI(w, r, id)
+	case !editable:
+		sendAllowable(w, "GET")
+	case r.Method == http.MethodPost && id == "":
+		inventoryStoreAPI(w, r, id)
+	case r.Method == http.MethodPut && id != "":
+		inventoryStoreAPI(w, r, id)
+	case r.Method == http.MethodDelete && id != "":
+		inventoryDeleteAPI(w, r, id)
+	case id == "":
+		sendAllowable(w, "GET,POST")
+	default:
+		sendAllowable(w, "GET,PUT,DELETE")
+	}
+}
+
+func images(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, baseEndpoint+"/images"), "/")
+	if name == "gc" {
+		switch r.Method {
+		case http.MethodPost:
+			imagesGCAPI(w, r)
+		default:
+			sendAllowable(w, "POST")
+		}
+		return
+	}
+	imtype, id := name, ""
+	if i := strings.Index(name, "/"); i >= 0 {
+		imtype, id = name[:i], name[i+1:]
+	}
+	if imtype != "" && !validImageType(imtype) {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound,
+			fmt.Sprintf("No such image type %s", imtype))
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet:
+		imagesGetAPI(w, r, imtype, id)
+	case r.Method == http.MethodPost && id == "":
+		imagesStoreAPI(w, r, imtype, id)
+	case r.Method == http.MethodPut && id != "":
+		imagesStoreAPI(w, r, imtype, id)
+	case r.Method == http.MethodDelete && id != "":
+		imagesDeleteAPI(w, r, imtype, id)
+	case id == "":
+		sendAllowable(w, "GET,POST")
+	default:
+		sendAllowable(w, "GET,PUT,DELETE")
+	}
+}
+
+func artifacts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		artifactsGetAPI(w, r)
+	default:
+		sendAllowable(w, "GET,HEAD")
+	}
+}
+
+func history(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		historyGetAPI(w, r)
+	default:
+		sendAllowable(w, "GET")
+	}
+}
+
+func historyRollback(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		historyRollbackAPI(w, r)
+	default:
+		sendAllowable(w, "POST")
+	}
+}
@@ -37,12 +37,14 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
 )
 
 const (
@@ -56,8 +58,21 @@ type ScnNotifier struct {
 	SubscriberName string
 	SubscriberURL  string
 	NotifierURL    string
-	Components     []string
+	Components     []string // Subscribed to
 	Client         *http.Client
+
+	// The subscription is owned by the manager goroutine, see scnsub.go.
+	interval time.Duration // Between checks of the subscription
+	retryMin time.Duration
+	retryMax time.Duration
+	changed  chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+
+	mu      sync.Mutex
+	current *ScnSubscribe // Last made, only changed by the manager
+	wanted  *ScnSubscribe
+	status  bssTypes.SCNStatus
 }
 
 type Scn struct {
@@ -94,6 +109,18 @@ func newNotifier(name, subscriberURL, notifierURL, opts string) *ScnNotifier {
 		SubscriberURL:  subscriberURL,
 		NotifierURL:    notifierURL,
 		Client:         &http.Client{},
+		interval:       time.Duration(scnVerifyInterval) * time.Second,
+		retryMin:       time.Duration(scnRetryMin) * time.Second,
+		retryMax:       time.Duration(scnRetryMax) * time.Second,
+		changed:        make(chan struct{}, 1),
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+		status: bssTypes.SCNStatus{
+			State:      bssTypes.SCNPending,
+			Strategy:   scnStrategy,
+			Subscriber: name + "@x0",
+			URL:        notifierURL,
+		},
 	}
 	if subscriberURL[0:6] == "https:" && insecure {
 		tcfg := &tls.Config{InsecureSkipVerify: true}
@@ -116,34 +143,13 @@ func customHeaders(req *http.Request) {
 	}
 }
 
-func (notifier *ScnNotifier) subscribe(comps []string) error {
-	n := len(comps)
-	if n == 0 {
-		return fmt.Errorf("Empty component subscription list")
-	}
-	debugf("New notifier subscription, current: %v, incoming: %v", notifier.Components, comps)
-	sort.Strings(comps)
-	if n == len(notifier.Components) {
-		i := 0
-		for i < n && comps[i] == notifier.Components[i] {
-			i++
-		}
-		if i == n {
-			// We are subscribing to the same elements as previously, so we
-			// don't need to change the subscription.
-			return nil
-		}
-	}
-
+func (notifier *ScnNotifier) subscribe(sub ScnSubscribe) error {
+	debugf("New notifier subscription, current: %v, incoming: %v", notifier.Components, sub.Components)
 	enabled := true
-	sub := ScnSubscribe{
-		Subscriber: notifier.SubscriberName + "@x0",
-		Components: comps,
-		States:     []string{"on", "off", "empty", "unknown", "populated"},
-		Enabled:    &enabled,
-		Url:        notifier.NotifierURL,
-	}
-	debugf("Subscribing for comps: %v", comps)
+	sub.Subscriber = notifier.status.Subscriber
+	sub.Enabled = &enabled
+	sub.Url = scnCallbackURL(notifier.NotifierURL)
+	debugf("Subscribing for comps: %v, roles: %v, subroles: %v", sub.Components, sub.Roles, sub.SubRoles)
 	payload, err := json.Marshal(sub)
 	if err != nil {
 		log.Printf("ERROR: marshalling failed: %s", err)
@@ -169,8 +175,10 @@ func (notifier *ScnNotifier) subscribe(comps []string) error {
 		switch rsp.StatusCode {
 		case http.StatusOK, http.StatusNoContent, http.StatusAccepted:
 			log.Printf("%s'd subscriptions for node changes.", method)
-			notifier.Components = make([]string, n)
-			copy(notifier.Components, comps)
+			notifier.mu.Lock()
+			notifier.Components = sub.Components
+			notifier.current = &sub
+			notifier.mu.Unlock()
 			return nil
 		default:
 			ret = fmt.Errorf("ERROR reponse from hmnfd, status: %s, Error code: %d, Rsp: %s", rsp.Status, rsp.StatusCode, rspBody)
@@ -187,31 +195,82 @@ func stateChangeNotification(w http.ResponseWriter, r *http.Request) {
 	p, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		log.Printf("ERROR reading body of POST from hmnfd")
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, "Cannot read the notification")
+		return
+	}
+	if err = verifySCNSender(r, p); err != nil {
+		log.Printf("Rejected state change notification from %s: %s", r.RemoteAddr, err)
+		rejectSCN(w, err)
 		return
 	}
 	var scn Scn
 	if err = json.Unmarshal(p, &scn); err != nil {
 		log.Printf("ERROR reading body of POST from hmnfd")
-		w.WriteHeader(http.StatusBadRequest)
-		// FIXME: Add error return data
+		rejectSCN(w, fmt.Errorf("Bad JSON: %s", err))
 		return
 	}
 	log.Printf("Received state change notification: %s", p)
-	// We simply store a timestamp.  This is the approx. time that SM updated
-	// something.  The next time BSS needs to check a host, it will see if it
-	// is up-to-date, and if not, it will fetch new SM data at that time.
-	// This has the advantage of not needing to fetch this data if BSS doesn't
-	// need it.  Additional updates to SM can then be made without BSS
-	// fetching the intermediate state.  The disadvantage is that it needs to
-	// get everything all at once.  The time isn't all that critical since it
-	// will respond to immediate requests with a chained response to have the
-	// requester try again after a short delay, giving BSS time to retrieve
-	// the SM data.
-	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
-	if err = kvstore.Store(UpdateTimestampKey, timestamp); err != nil {
-		log.Printf("Failed to store update timestamp %s to key %s: %s",
-			timestamp, UpdateTimestampKey, err)
+	if err = validateSCN(&scn); err != nil {
+		log.Printf("Rejected state change notification from %s: %s", r.RemoteAddr, err)
+		rejectSCN(w, err)
+		return
+	}
+	comps := scnComponents(scn)
+	if len(comps) == 0 {
+		debugf("Ignoring state change notification of %v", scn.Components)
+		atomic.AddInt64(&scnIgnored, 1)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	// We record the components which changed, and every BSS instance
+	// refreshes them from SM in the background, see hsmSyncer().  This has
+	// the advantage of only fetching what changed, without holding up the
+	// boot requests.
+	if base.VerifyNormalizeState(scn.State) == base.StateReady.String() {
+		for _, c := range comps {
+			if base.GetHMSType(c) == base.Node {
+				bootSessionProgress(c, bssTypes.BootBooted, "")
+			}
+		}
+	}
+	if err = recordHSMChange(comps); err != nil {
+		log.Printf("Failed to record state change of %v: %s", comps, err)
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+			fmt.Sprintf("Failed to record the state change: %s", err))
+		return
 	}
+	wakeHSMSyncer()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// The HMS types whose state changes matter to BSS: nodes, and the BMCs
+// through which nodes are discovered.
+var scnTypes = map[base.HMSType]bool{
+	base.Node:       true,
+	base.NodeBMC:    true,
+	base.RouterBMC:  true,
+	base.ChassisBMC: true,
+}
+
+// Notifications ignored since BSS started.
+var scnIgnored int64
+
+// The components of a notification which BSS cares about.  BSS doesn't use
+// the software status, so a notification which only changes it is ignored,
+// as are components other than nodes and BMCs, which a subscription to all
+// components or to roles may send.
+func scnComponents(scn Scn) []string {
+	if scn.SoftwareStatus != "" && scn.State == "" && scn.Enabled == nil &&
+		scn.Role == "" && scn.SubRole == "" {
+		return nil
+	}
+	var comps []string
+	for _, c := range scn.Components {
+		if scnTypes[base.GetHMSType(c)] {
+			comps = append(comps, base.NormalizeHMSCompID(c))
+		}
+	}
+	return comps
 }
 
 // Checks the current timestamp of this running image vs. the timestamp in etcd.
@@ -224,6 +283,11 @@ func checkState(force bool) bool {
 		err       error
 	)
 	if force {
+		if !forcedRefreshDue() {
+			// Still have the requester ask again, with its architecture
+			debugf("Skipping forced refresh of the HSM state")
+			return true
+		}
 		ts = -1
 	} else {
 		timestamp, exists, _ = kvstore.Get(UpdateTimestampKey)
@@ -234,5 +298,7 @@ func checkState(force bool) bool {
 		go refreshState(ts)
 		return true
 	}
-	return false
+	// Changes are applied by hsmSyncer(), which may be fetching the whole
+	// state for them.
+	return atomic.LoadInt32(&hsmRefreshing) != 0
 }
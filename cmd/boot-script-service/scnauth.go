@@ -0,0 +1,252 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+/*
+ * State change notification verification
+ *
+ * Each notification makes BSS fetch state from HSM, so with BSS_SCN_AUTH
+ * BSS only accepts notifications from hmnfd:
+ *
+ *   token  The notification must carry BSS_SCN_SECRET, as a bearer token or a
+ *          token= query parameter.  BSS subscribes with a notification URL
+ *          which has the token= parameter, so hmnfd sends it.
+ *   hmac   The notification must have an X-BSS-Signature header of
+ *          sha256=<hex>, the HMAC-SHA256 of the body keyed with
+ *          BSS_SCN_SECRET.
+ *   mtls   The service mesh sidecar must have verified a client certificate,
+ *          which it passes on in the X-Forwarded-Client-Cert header.  The
+ *          URI, DNS name or subject of the certificate must be one of
+ *          BSS_SCN_CLIENT_NAMES, which is required since the header may
+ *          also have been set by whoever sent the request.
+ *
+ * A notification must also name components, which must be valid xnames and,
+ * when subscribed by component, ones BSS subscribed to.  Rejected
+ * notifications are counted in /boot/v1/service/scn.
+ */
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	base "github.com/Cray-HPE/hms-base"
+)
+
+const (
+	scnAuthNone  = "none"
+	scnAuthToken = "token"
+	scnAuthHMAC  = "hmac"
+	scnAuthMTLS  = "mtls"
+
+	scnSignatureHeader = "X-BSS-Signature"
+	scnXFCCHeader      = "X-Forwarded-Client-Cert"
+)
+
+var (
+	scnAuth        = scnAuthNone
+	scnSecret      string
+	scnClientNames []string
+)
+
+// Notifications rejected since BSS started.
+var scnRejected int64
+
+// A notification which was rejected, with the status to respond with.
+type scnRejection struct {
+	status int
+	reason string
+}
+
+func (r scnRejection) Error() string {
+	return r.reason
+}
+
+func setSCNAuth(mode string) error {
+	switch strings.ToLower(mode) {
+	case "", scnAuthNone:
+		scnAuth = scnAuthNone
+	case scnAuthToken, scnAuthHMAC:
+		if scnSecret == "" {
+			return fmt.Errorf("%s needs BSS_SCN_SECRET", mode)
+		}
+		scnAuth = strings.ToLower(mode)
+	case scnAuthMTLS:
+		if len(scnClientNames) == 0 {
+			return fmt.Errorf("%s needs BSS_SCN_CLIENT_NAMES", mode)
+		}
+		scnAuth = scnAuthMTLS
+	default:
+		return fmt.Errorf("Unknown notification verification %s, expected %s, %s, %s or %s",
+			mode, scnAuthNone, scnAuthToken, scnAuthHMAC, scnAuthMTLS)
+	}
+	return nil
+}
+
+// The URL hmnfd sends notifications to, with the token if there is one.
+func scnCallbackURL(notifierURL string) string {
+	if scnAuth != scnAuthToken {
+		return notifierURL
+	}
+	sep := "?"
+	if strings.Contains(notifierURL, "?") {
+		sep = "&"
+	}
+	return notifierURL + sep + "token=" + url.QueryEscape(scnSecret)
+}
+
+func secretEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// Split s at the separator, except within double quotes.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// The names of the client certificate in an X-Forwarded-Client-Cert header.
+// The header has an element for each proxy the request passed through, and
+// the last is from the sidecar in front of BSS.
+func xfccNames(header string) []string {
+	elems := splitQuoted(header, ',')
+	var names []string
+	for _, pair := range splitQuoted(elems[len(elems)-1], ';') {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		v := strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		switch strings.ToUpper(strings.TrimSpace(kv[0])) {
+		case "URI", "DNS", "SUBJECT":
+			if v != "" {
+				names = append(names, strings.ReplaceAll(v, `\"`, `"`))
+			}
+		}
+	}
+	return names
+}
+
+// Check that a notification is from hmnfd.
+func verifySCNSender(r *http.Request, body []byte) error {
+	switch scnAuth {
+	case scnAuthToken:
+		token := r.URL.Query().Get("token")
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if token == "" || !secretEqual(token, scnSecret) {
+			return scnRejection{http.StatusUnauthorized, "missing or wrong token"}
+		}
+	case scnAuthHMAC:
+		sig := strings.TrimPrefix(r.Header.Get(scnSignatureHeader), "sha256=")
+		got, err := hex.DecodeString(sig)
+		mac := hmac.New(sha256.New, []byte(scnSecret))
+		mac.Write(body)
+		if sig == "" || err != nil || !hmac.Equal(got, mac.Sum(nil)) {
+			return scnRejection{http.StatusUnauthorized, "missing or wrong " + scnSignatureHeader}
+		}
+	case scnAuthMTLS:
+		xfcc := r.Header.Get(scnXFCCHeader)
+		if xfcc == "" {
+			return scnRejection{http.StatusUnauthorized, "no client certificate"}
+		}
+		names := xfccNames(xfcc)
+		for _, n := range names {
+			for _, allowed := range scnClientNames {
+				if n == allowed {
+					return nil
+				}
+			}
+		}
+		return scnRejection{http.StatusForbidden, fmt.Sprintf("client certificate %v is not allowed", names)}
+	}
+	return nil
+}
+
+// Check the components and state of a notification, normalizing the
+// component IDs.  When subscribed by component, components BSS didn't
+// subscribe to are dropped, and the notification is rejected if none are
+// left.
+func validateSCN(scn *Scn) error {
+	if len(scn.Components) == 0 {
+		return scnRejection{http.StatusBadRequest, "no components"}
+	}
+	if scn.State != "" && base.VerifyNormalizeState(scn.State) == "" {
+		return scnRejection{http.StatusBadRequest, fmt.Sprintf("invalid state %s", scn.State)}
+	}
+	for i, c := range scn.Components {
+		id := base.VerifyNormalizeCompID(c)
+		if id == "" {
+			return scnRejection{http.StatusBadRequest, fmt.Sprintf("invalid component %s", c)}
+		}
+		scn.Components[i] = id
+	}
+	subscribed := notifier.subscribedComponents()
+	if subscribed == nil {
+		return nil
+	}
+	comps := scn.Components[:0]
+	for _, c := range scn.Components {
+		if i := sort.SearchStrings(subscribed, c); i < len(subscribed) && subscribed[i] == c {
+			comps = append(comps, c)
+		}
+	}
+	if len(comps) == 0 {
+		return scnRejection{http.StatusBadRequest, "no components BSS subscribed to"}
+	}
+	scn.Components = comps
+	return nil
+}
+
+func rejectSCN(w http.ResponseWriter, err error) {
+	atomic.AddInt64(&scnRejected, 1)
+	status := http.StatusBadRequest
+	if rej, ok := err.(scnRejection); ok {
+		status = rej.status
+	}
+	if status == http.StatusUnauthorized && scnAuth == scnAuthToken {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+	base.SendProblemDetailsGeneric(w, status, "State change notification rejected: "+err.Error())
+}
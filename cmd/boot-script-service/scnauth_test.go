@@ -0,0 +1,172 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func withSCNAuth(t *testing.T, mode, secret string, names ...string) {
+	t.Helper()
+	scnSecret, scnClientNames = secret, names
+	if err := setSCNAuth(mode); err != nil {
+		t.Fatalf("setSCNAuth(%s) failed: %v", mode, err)
+	}
+	t.Cleanup(func() { scnAuth, scnSecret, scnClientNames = scnAuthNone, "", nil })
+}
+
+func TestVerifySCNSender(t *testing.T) {
+	body := []byte(`{"Components":["x0c0s0b0n0"],"State":"On"}`)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	sig := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	xfcc := `By=spiffe://cluster.local/ns/services/sa/cray-bss;Hash=abc;Subject="CN=hmnfd,O=HPE";` +
+		`URI=spiffe://cluster.local/ns/services/sa/cray-hmnfd`
+
+	tests := []struct {
+		mode, url, header, value string
+		names                    []string
+		want                     int
+	}{
+		{scnAuthNone, "/boot/v1/scn", "", "", nil, 0},
+		{scnAuthToken, "/boot/v1/scn?token=s3cret", "", "", nil, 0},
+		{scnAuthToken, "/boot/v1/scn", "Authorization", "Bearer s3cret", nil, 0},
+		{scnAuthToken, "/boot/v1/scn?token=guess", "", "", nil, http.StatusUnauthorized},
+		{scnAuthToken, "/boot/v1/scn", "", "", nil, http.StatusUnauthorized},
+		{scnAuthHMAC, "/boot/v1/scn", scnSignatureHeader, sig, nil, 0},
+		{scnAuthHMAC, "/boot/v1/scn", scnSignatureHeader, "sha256=00", nil, http.StatusUnauthorized},
+		{scnAuthHMAC, "/boot/v1/scn?token=s3cret", "", "", nil, http.StatusUnauthorized},
+		{scnAuthMTLS, "/boot/v1/scn", scnXFCCHeader, xfcc, []string{"spiffe://cluster.local/ns/services/sa/cray-hmnfd"}, 0},
+		{scnAuthMTLS, "/boot/v1/scn", scnXFCCHeader, xfcc, []string{"CN=hmnfd,O=HPE"}, 0},
+		{scnAuthMTLS, "/boot/v1/scn", scnXFCCHeader, xfcc, []string{"spiffe://cluster.local/ns/services/sa/other"}, http.StatusForbidden},
+		{scnAuthMTLS, "/boot/v1/scn", "", "", []string{"CN=hmnfd,O=HPE"}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		withSCNAuth(t, tt.mode, "s3cret", tt.names...)
+		req, _ := http.NewRequest(http.MethodPost, tt.url, bytes.NewReader(body))
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+		err := verifySCNSender(req, body)
+		status := 0
+		if rej, ok := err.(scnRejection); ok {
+			status = rej.status
+		}
+		if status != tt.want || (err == nil) != (tt.want == 0) {
+			t.Errorf("%s %s %s: %q got %v, expected %d", tt.mode, tt.url, tt.header, tt.value, err, tt.want)
+		}
+	}
+
+	scnSecret, scnClientNames = "", nil
+	if err := setSCNAuth(scnAuthHMAC); err == nil {
+		t.Errorf("setSCNAuth(hmac) succeeded without a secret")
+	}
+	if err := setSCNAuth(scnAuthMTLS); err == nil {
+		t.Errorf("setSCNAuth(mtls) succeeded without client names")
+	}
+	withSCNAuth(t, scnAuthToken, "a b")
+	if u := scnCallbackURL("http://bss/boot/v1/scn"); u != "http://bss/boot/v1/scn?token=a+b" {
+		t.Errorf("Callback URL %s", u)
+	}
+}
+
+func TestXFCCNames(t *testing.T) {
+	h := `By=spiffe://a;URI=spiffe://proxy,By=spiffe://b;Hash=x;Subject="CN=a\"b,O=c";URI=spiffe://c;DNS=c.local`
+	want := []string{`CN=a"b,O=c`, "spiffe://c", "c.local"}
+	if got := xfccNames(h); !sameList(got, want) {
+		t.Errorf("xfccNames() = %q, expected %q", got, want)
+	}
+}
+
+func TestStateChangeNotification(t *testing.T) {
+	saved := notifier
+	defer func() {
+		notifier = saved
+		for _, k := range hsmChangeKeys() {
+			kvstore.Delete(k)
+		}
+	}()
+	notifier = newNotifier("bss-test", "http://hmnfd/hmi/v1/subscribe", "http://bss/boot/v1/scn", "")
+	notifier.current = &ScnSubscribe{Components: []string{"x0c0s0b0", "x0c0s0b0n0", "x0c0s1b0n0"}}
+	withSCNAuth(t, scnAuthToken, "s3cret")
+
+	post := func(url, body string) int {
+		req, _ := http.NewRequest(http.MethodPost, url, bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(scn).ServeHTTP(rr, req)
+		return rr.Code
+	}
+	rejected := atomic.LoadInt64(&scnRejected)
+	tests := []struct {
+		url, body string
+		want      int
+	}{
+		{"/boot/v1/scn", `{"Components":["x0c0s0b0n0"],"State":"On"}`, http.StatusUnauthorized},
+		{"/boot/v1/scn?token=s3cret", `{"Components":`, http.StatusBadRequest},
+		{"/boot/v1/scn?token=s3cret", `{"Components":[],"State":"On"}`, http.StatusBadRequest},
+		{"/boot/v1/scn?token=s3cret", `{"Components":["not-an-xname"],"State":"On"}`, http.StatusBadRequest},
+		{"/boot/v1/scn?token=s3cret", `{"Components":["x0c0s0b0n0"],"State":"Sideways"}`, http.StatusBadRequest},
+		{"/boot/v1/scn?token=s3cret", `{"Components":["x0c0s7b0n0"],"State":"On"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if code := post(tt.url, tt.body); code != tt.want {
+			t.Errorf("POST %s %s returned %d, expected %d", tt.url, tt.body, code, tt.want)
+		}
+	}
+	if n := atomic.LoadInt64(&scnRejected) - rejected; n != int64(len(tests)) {
+		t.Errorf("%d notifications counted as rejected, expected %d", n, len(tests))
+	}
+	if keys := hsmChangeKeys(); len(keys) != 0 {
+		t.Errorf("Rejected notifications recorded changes %v", keys)
+	}
+
+	select {
+	case <-hsmChangesWake:
+	default:
+	}
+
+	// Components which weren't subscribed to are dropped
+	code := post("/boot/v1/scn?token=s3cret", `{"Components":["x0c0s7b0n0","x0c0s01b0n0"],"State":"Off"}`)
+	keys := hsmChangeKeys()
+	if code != http.StatusNoContent || len(keys) != 1 {
+		t.Fatalf("POST returned %d, changes %v", code, keys)
+	}
+	if v, _, _ := kvstore.Get(keys[0]); v != `["x0c0s1b0n0"]` {
+		t.Errorf("Change recorded as %s", v)
+	}
+	select {
+	case <-hsmChangesWake:
+	default:
+		t.Errorf("hsmSyncer() not woken to apply the change")
+	}
+	if st := notifier.Status(); st.Rejected != atomic.LoadInt64(&scnRejected) || st.URL != "http://bss/boot/v1/scn" {
+		t.Errorf("Unexpected status %+v", st)
+	}
+}
@@ -0,0 +1,372 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+/*
+ * State change notification subscription manager
+ *
+ * The subscription to hmnfd is owned by a manager goroutine.  Each fetch of
+ * the HSM state gives it the components BSS wants notifications for, and it
+ * subscribes whenever they change.  Every BSS_SCN_VERIFY_INTERVAL seconds it
+ * asks hmnfd for its subscriptions, and subscribes again if hmnfd no longer
+ * has ours, as happens when hmnfd is restarted without its store.  Failed
+ * attempts are retried with exponential backoff, from BSS_SCN_RETRY_MIN up to
+ * BSS_SCN_RETRY_MAX seconds.  Every BSS replica manages the same
+ * subscription, so it is left in place when one of them shuts down.  Nor is
+ * it deleted when the last replica shuts down, since a replica can't tell
+ * that it is the last, so once BSS is removed for good hmnfd keeps sending
+ * notifications to it until the subscription is deleted from hmnfd.
+ *
+ * The state of the subscription is in /boot/v1/service/scn.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+var (
+	scnVerifyInterval = 300 // Seconds
+	scnRetryMin       = 5   // Seconds
+	scnRetryMax       = 300 // Seconds
+)
+
+var errSCNNotSubscribed = errors.New("hmnfd has no subscription for BSS")
+
+type scnSubscriptionList struct {
+	SubscriptionList []ScnSubscribe `json:"SubscriptionList"`
+}
+
+// Subscription strategies.  With components, BSS subscribes to its nodes
+// and their Redfish endpoints by name.  With roles, it subscribes to the
+// nodes with the roles and subroles of BSS_SCN_ROLES and BSS_SCN_SUBROLES, or
+// the roles of its nodes if BSS_SCN_ROLES is empty, which keeps the
+// subscription small on large systems.  With all, it subscribes to every
+// component.
+const (
+	scnByComponents = "components"
+	scnByRoles      = "roles"
+	scnAll          = "all"
+)
+
+var (
+	scnStrategy = scnByComponents
+	scnRoles    []string
+	scnSubRoles []string
+)
+
+// The component states BSS subscribes to.  Ready marks the boot sessions of
+// nodes booted, see bootSessionProgress().
+var scnStates = []string{"on", "off", "empty", "unknown", "populated", "ready"}
+
+func setSCNStrategy(strategy string) error {
+	switch strings.ToLower(strategy) {
+	case "", scnByComponents:
+		scnStrategy = scnByComponents
+	case scnByRoles:
+		scnStrategy = scnByRoles
+	case scnAll:
+		scnStrategy = scnAll
+	default:
+		return fmt.Errorf("Unknown subscription strategy %s, expected %s, %s or %s",
+			strategy, scnByComponents, scnByRoles, scnAll)
+	}
+	return nil
+}
+
+func sortedCopy(list []string) []string {
+	if len(list) == 0 {
+		return nil
+	}
+	ret := make([]string, len(list))
+	copy(ret, list)
+	sort.Strings(ret)
+	return ret
+}
+
+// The subscription for the nodes and Redfish endpoints of an HSM state, or
+// nil if there is nothing to subscribe to.
+func scnSubscription(comps []SMComponent, endpoints []string) *ScnSubscribe {
+	sub := ScnSubscribe{States: scnStates}
+	switch scnStrategy {
+	case scnByComponents:
+		for _, c := range comps {
+			sub.Components = append(sub.Components, c.ID)
+		}
+		sub.Components = append(sub.Components, endpoints...)
+		if len(sub.Components) == 0 {
+			return nil
+		}
+	case scnByRoles:
+		sub.Roles, sub.SubRoles = scnRoles, scnSubRoles
+		if len(sub.Roles) == 0 {
+			roles := map[string]bool{}
+			for _, c := range comps {
+				if c.Role != "" && !roles[c.Role] {
+					roles[c.Role] = true
+					sub.Roles = append(sub.Roles, c.Role)
+				}
+			}
+		}
+		if len(sub.Roles) == 0 {
+			return nil
+		}
+	}
+	sub.Components = sortedCopy(sub.Components)
+	sub.Roles = sortedCopy(sub.Roles)
+	sub.SubRoles = sortedCopy(sub.SubRoles)
+	return &sub
+}
+
+// Set what to subscribe to.  The manager subscribes if it differs from the
+// current subscription.
+func (notifier *ScnNotifier) setSubscription(sub *ScnSubscribe) {
+	notifier.mu.Lock()
+	notifier.wanted = sub
+	notifier.mu.Unlock()
+	select {
+	case notifier.changed <- struct{}{}:
+	default:
+	}
+}
+
+func sameList(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Whether two subscriptions are for the same components.  The lists of
+// both must be sorted.
+func sameSubscription(a, b *ScnSubscribe) bool {
+	return a != nil && b != nil && sameList(a.Components, b.Components) &&
+		sameList(a.Roles, b.Roles) && sameList(a.SubRoles, b.SubRoles)
+}
+
+func (notifier *ScnNotifier) request(method, url string, body interface{}) (*http.Response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, url, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, err
+	}
+	customHeaders(req)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	base.SetHTTPUserAgent(req, serviceName)
+	req.Close = true
+	return notifier.Client.Do(req)
+}
+
+// Check that hmnfd still has our subscription, for the components we last
+// subscribed to.
+func (notifier *ScnNotifier) verify() error {
+	url := strings.TrimSuffix(notifier.SubscriberURL, "/subscribe") + "/subscriptions"
+	rsp, err := notifier.request(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(rsp.Body)
+		return fmt.Errorf("GET %s returned %s: %s", url, rsp.Status, body)
+	}
+	var subs scnSubscriptionList
+	if err := json.NewDecoder(rsp.Body).Decode(&subs); err != nil {
+		return fmt.Errorf("Bad response from %s: %s", url, err)
+	}
+	for _, sub := range subs.SubscriptionList {
+		if sub.Subscriber != notifier.status.Subscriber || sub.Url != scnCallbackURL(notifier.NotifierURL) {
+			continue
+		}
+		got := ScnSubscribe{Components: sortedCopy(sub.Components),
+			Roles: sortedCopy(sub.Roles), SubRoles: sortedCopy(sub.SubRoles)}
+		if !sameSubscription(&got, notifier.current) {
+			return fmt.Errorf("hmnfd subscription is for %d components, roles %v, subroles %v, expected %d, %v, %v",
+				len(got.Components), got.Roles, got.SubRoles,
+				len(notifier.current.Components), notifier.current.Roles, notifier.current.SubRoles)
+		}
+		return nil
+	}
+	return errSCNNotSubscribed
+}
+
+// Record a failed attempt to subscribe.
+func (notifier *ScnNotifier) failed(err error) {
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+	notifier.status.State = bssTypes.SCNFailed
+	notifier.status.Failures++
+	notifier.status.LastError = err.Error()
+	notifier.status.LastErrorTime = time.Now().Format(time.RFC3339)
+}
+
+// Bring the subscription up to date.  Unless check is set, the subscription
+// is only verified with hmnfd if it needs to change.
+func (notifier *ScnNotifier) sync(check bool) error {
+	notifier.mu.Lock()
+	wanted := notifier.wanted
+	state := notifier.status.State
+	notifier.mu.Unlock()
+	if wanted == nil {
+		return nil
+	}
+	current := state == bssTypes.SCNSubscribed && sameSubscription(wanted, notifier.current)
+	if current && !check {
+		return nil
+	}
+	if current {
+		err := notifier.verify()
+		if err == nil {
+			notifier.mu.Lock()
+			notifier.status.Verified = time.Now().Format(time.RFC3339)
+			notifier.mu.Unlock()
+			return nil
+		}
+		log.Printf("hmnfd subscription check failed, subscribing again: %s", err)
+	}
+
+	notifier.mu.Lock()
+	notifier.status.Attempts++
+	notifier.mu.Unlock()
+	if err := notifier.subscribe(*wanted); err != nil {
+		notifier.failed(err)
+		return err
+	}
+	now := time.Now().Format(time.RFC3339)
+	notifier.mu.Lock()
+	notifier.status.State = bssTypes.SCNSubscribed
+	notifier.status.Strategy = scnStrategy
+	notifier.status.Components = len(wanted.Components)
+	notifier.status.Roles = wanted.Roles
+	notifier.status.SubRoles = wanted.SubRoles
+	notifier.status.Subscribed = now
+	notifier.status.Verified = now
+	notifier.mu.Unlock()
+	return nil
+}
+
+// The manager goroutine, which owns the subscription until shutdown() is
+// called.
+func (notifier *ScnNotifier) run() {
+	defer close(notifier.done)
+	backoff := notifier.retryMin
+	failing := false
+	next := time.Now().Add(notifier.interval)
+	for {
+		notifier.mu.Lock()
+		notifier.status.NextCheck = next.Format(time.RFC3339)
+		notifier.mu.Unlock()
+		timer := time.NewTimer(time.Until(next))
+		check := false
+		select {
+		case <-notifier.stop:
+			timer.Stop()
+			notifier.mu.Lock()
+			notifier.status.NextCheck = ""
+			notifier.mu.Unlock()
+			return
+		case <-notifier.changed:
+			if failing {
+				// Wait for the retry
+				timer.Stop()
+				continue
+			}
+		case <-timer.C:
+			check = true
+		}
+		timer.Stop()
+
+		if err := notifier.sync(check); err != nil {
+			log.Printf("hmnfd subscription failed, retrying in %s: %s", backoff, err)
+			failing = true
+			next = time.Now().Add(backoff)
+			if backoff *= 2; backoff > notifier.retryMax {
+				backoff = notifier.retryMax
+			}
+		} else if check || failing {
+			failing = false
+			backoff = notifier.retryMin
+			next = time.Now().Add(notifier.interval)
+		}
+	}
+}
+
+// Stop the manager, leaving the subscription to the other replicas.  The
+// wait for a request to hmnfd in progress is bounded by timeout.
+func (notifier *ScnNotifier) shutdown(timeout time.Duration) {
+	close(notifier.stop)
+	select {
+	case <-notifier.done:
+	case <-time.After(timeout):
+		log.Printf("WARNING: Timed out stopping the hmnfd subscription manager")
+	}
+}
+
+// The components subscribed to by name, sorted, or nil if BSS hasn't
+// subscribed by component.
+func (notifier *ScnNotifier) subscribedComponents() []string {
+	if notifier == nil {
+		return nil
+	}
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+	if notifier.current == nil {
+		return nil
+	}
+	return notifier.current.Components
+}
+
+// The state of the subscription.
+func (notifier *ScnNotifier) Status() bssTypes.SCNStatus {
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+	st := notifier.status
+	st.Ignored = atomic.LoadInt64(&scnIgnored)
+	st.Rejected = atomic.LoadInt64(&scnRejected)
+	return st
+}
@@ -0,0 +1,239 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+// A fake hmnfd, which keeps subscriptions by subscriber and URL.
+type fakeNFD struct {
+	mu      sync.Mutex
+	subs    map[string]ScnSubscribe
+	down    bool
+	deletes int
+}
+
+func (f *fakeNFD) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var sub ScnSubscribe
+	switch {
+	case r.URL.Path == "/hmi/v1/subscriptions" && r.Method == http.MethodGet:
+		var list scnSubscriptionList
+		for _, s := range f.subs {
+			list.SubscriptionList = append(list.SubscriptionList, s)
+		}
+		json.NewEncoder(w).Encode(list)
+	case r.URL.Path != "/hmi/v1/subscribe":
+		w.WriteHeader(http.StatusNotFound)
+	case json.NewDecoder(r.Body).Decode(&sub) != nil:
+		w.WriteHeader(http.StatusBadRequest)
+	case r.Method == http.MethodPost || r.Method == http.MethodPatch:
+		f.subs[sub.Subscriber+sub.Url] = sub
+	case r.Method == http.MethodDelete:
+		f.deletes++
+		delete(f.subs, sub.Subscriber+sub.Url)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeNFD) set(down bool, restart bool) {
+	f.mu.Lock()
+	f.down = down
+	if restart {
+		f.subs = map[string]ScnSubscribe{}
+	}
+	f.mu.Unlock()
+}
+
+func (f *fakeNFD) subscription() (ScnSubscribe, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, s := range f.subs {
+		return s, true
+	}
+	return ScnSubscribe{}, false
+}
+
+// Wait for the notifier status to satisfy cond.
+func waitSCNStatus(t *testing.T, n *ScnNotifier, what string, cond func(bssTypes.SCNStatus) bool) bssTypes.SCNStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		st := n.Status()
+		if cond(st) {
+			return st
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s, status %+v", what, st)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSCNSubscriptionManager(t *testing.T) {
+	nfd := &fakeNFD{subs: map[string]ScnSubscribe{}}
+	srv := httptest.NewServer(nfd)
+	defer srv.Close()
+
+	n := newNotifier("bss-test", srv.URL+"/hmi/v1/subscribe", "http://bss/boot/v1/scn", "")
+	n.interval = 50 * time.Millisecond
+	n.retryMin = 20 * time.Millisecond
+	n.retryMax = 40 * time.Millisecond
+	go n.run()
+
+	if st := n.Status(); st.State != bssTypes.SCNPending {
+		t.Errorf("State %s before any components, expected %s", st.State, bssTypes.SCNPending)
+	}
+	comps := []SMComponent{{Component: base.Component{ID: "x0c0s1b0n0"}}, {Component: base.Component{ID: "x0c0s0b0n0"}}}
+	n.setSubscription(scnSubscription(comps, nil))
+	waitSCNStatus(t, n, "the subscription", func(st bssTypes.SCNStatus) bool {
+		return st.State == bssTypes.SCNSubscribed && st.Components == 2
+	})
+	if sub, ok := nfd.subscription(); !ok || sub.Subscriber != "bss-test@x0" || len(sub.Components) != 2 ||
+		sub.Components[0] != "x0c0s0b0n0" {
+		t.Errorf("Unexpected hmnfd subscription %+v", sub)
+	}
+
+	// hmnfd restarts without its subscriptions, and is down for a while
+	nfd.set(true, true)
+	waitSCNStatus(t, n, "a failure", func(st bssTypes.SCNStatus) bool {
+		return st.State == bssTypes.SCNFailed && st.Failures >= 2
+	})
+	nfd.set(false, false)
+	st := waitSCNStatus(t, n, "the resubscription", func(st bssTypes.SCNStatus) bool {
+		return st.State == bssTypes.SCNSubscribed
+	})
+	if _, ok := nfd.subscription(); !ok || st.LastError == "" {
+		t.Errorf("Not resubscribed after hmnfd restart, status %+v", st)
+	}
+
+	// A changed component list is subscribed to at once
+	n.setSubscription(scnSubscription(comps[:0], []string{"x0c0s2b0"}))
+	waitSCNStatus(t, n, "the new components", func(st bssTypes.SCNStatus) bool {
+		return st.Components == 1
+	})
+	if sub, _ := nfd.subscription(); len(sub.Components) != 1 || sub.Components[0] != "x0c0s2b0" {
+		t.Errorf("hmnfd subscription %v not updated", sub.Components)
+	}
+
+	// The other replicas still need the subscription
+	n.shutdown(5 * time.Second)
+	if _, ok := nfd.subscription(); !ok || nfd.deletes != 0 {
+		t.Errorf("Subscription deleted at shutdown, %d deletes", nfd.deletes)
+	}
+}
+
+func TestSCNSubscriptionStrategy(t *testing.T) {
+	defer func() { scnStrategy, scnRoles, scnSubRoles = scnByComponents, nil, nil }()
+	comps := []SMComponent{
+		{Component: base.Component{ID: "x0c0s1b0n0", Role: "Compute"}},
+		{Component: base.Component{ID: "x0c0s0b0n0", Role: "Management", SubRole: "Worker"}},
+		{Component: base.Component{ID: "x0c0s2b0n0", Role: "Compute"}},
+	}
+	endpoints := []string{"x0c0s1b0", "x0c0s0b0"}
+
+	sub := scnSubscription(comps, endpoints)
+	want := []string{"x0c0s0b0", "x0c0s0b0n0", "x0c0s1b0", "x0c0s1b0n0", "x0c0s2b0n0"}
+	if sub == nil || !sameList(sub.Components, want) || sub.Roles != nil || len(sub.States) == 0 {
+		t.Errorf("Component subscription %+v, expected components %v", sub, want)
+	}
+	if sub := scnSubscription(nil, nil); sub != nil {
+		t.Errorf("Subscription %+v without components", sub)
+	}
+
+	if err := setSCNStrategy("Roles"); err != nil || scnStrategy != scnByRoles {
+		t.Fatalf("setSCNStrategy(Roles) = %v, strategy %s", err, scnStrategy)
+	}
+	sub = scnSubscription(comps, endpoints)
+	if sub == nil || sub.Components != nil || !sameList(sub.Roles, []string{"Compute", "Management"}) {
+		t.Errorf("Role subscription %+v, expected the roles of the nodes", sub)
+	}
+	scnRoles, scnSubRoles = []string{"Management"}, []string{"Worker", "Storage"}
+	other := scnSubscription(comps, endpoints)
+	if other == nil || !sameList(other.Roles, []string{"Management"}) ||
+		!sameList(other.SubRoles, []string{"Storage", "Worker"}) || sameSubscription(sub, other) {
+		t.Errorf("Role subscription %+v, expected the configured roles", other)
+	}
+
+	if err := setSCNStrategy("all"); err != nil {
+		t.Fatalf("setSCNStrategy(all) failed: %v", err)
+	}
+	if sub := scnSubscription(nil, nil); sub == nil || sub.Components != nil || sub.Roles != nil {
+		t.Errorf("Wildcard subscription %+v", sub)
+	}
+	if err := setSCNStrategy("nodes"); err == nil {
+		t.Errorf("setSCNStrategy(nodes) succeeded")
+	}
+}
+
+func TestSCNComponents(t *testing.T) {
+	enabled := false
+	tests := []struct {
+		scn  Scn
+		want []string
+	}{
+		{Scn{Components: []string{"x0c0s0b0n0", "x0c0s0b0", "x0c0r1e0", "x0m0p0"}, State: "Off"},
+			[]string{"x0c0s0b0n0", "x0c0s0b0"}},
+		{Scn{Components: []string{"x0c0s00b0n0"}, Enabled: &enabled}, []string{"x0c0s0b0n0"}},
+		{Scn{Components: []string{"x0c0s0b0n0"}, SoftwareStatus: "AdminDown"}, nil},
+		{Scn{Components: []string{"x0c0s0b0n0"}, SoftwareStatus: "AdminDown", Role: "Compute"},
+			[]string{"x0c0s0b0n0"}},
+		{Scn{Components: []string{"x0c0r1e0"}, State: "On"}, nil},
+	}
+	for _, tt := range tests {
+		if got := scnComponents(tt.scn); !sameList(got, tt.want) {
+			t.Errorf("scnComponents(%+v) = %v, expected %v", tt.scn, got, tt.want)
+		}
+	}
+}
+
+func TestSCNStatusAPI(t *testing.T) {
+	saved := notifier
+	defer func() { notifier = saved }()
+	notifier = newNotifier("bss-test", "http://hmnfd/hmi/v1/subscribe", "http://bss/boot/v1/scn", "")
+
+	rr := serveRequest(t, service, http.MethodGet, "/service/scn", nil)
+	var status bssTypes.ServiceStatus
+	if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &status) != nil {
+		t.Fatalf("GET returned %d: %s", rr.Code, rr.Body)
+	}
+	if status.SCN == nil || status.SCN.State != bssTypes.SCNPending || status.SCN.Subscriber != "bss-test@x0" ||
+		status.HSMState != nil {
+		t.Errorf("Unexpected status %s", rr.Body)
+	}
+}
@@ -30,14 +30,11 @@ import (
 	"math/rand"
 	"net/http"
 	"strings"
+
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
 )
 
-type serviceStatus struct {
-	Version    string `json:"bss-version,omitempty"`
-	Status     string `json:"bss-status,omitempty"`
-	HSMStatus  string `json:"bss-status-hsm,omitempty"`
-	EctdStatus string `json:"bss-status-etcd,omitempty"`
-}
+type serviceStatus = bssTypes.ServiceStatus
 
 func serviceStatusAPI(w http.ResponseWriter, req *http.Request) {
 	var bssStatus serviceStatus
@@ -62,20 +59,25 @@ func serviceStatusAPI(w http.ResponseWriter, req *http.Request) {
 	if strings.Contains(strings.ToUpper(req.URL.Path), "HSM") ||
 		strings.Contains(strings.ToUpper(req.URL.Path), "ALL") {
 		bssStatus.HSMStatus = "connected"
-		url := smBaseURL + "/service/values/class"
-		rsp, err := smClient.Get(url)
-		if err != nil {
-			httpStatus = http.StatusInternalServerError
-			bssStatus.HSMStatus = "error"
-			log.Printf("Cannot connect to HSM: %s", err)
-		} else {
-			_, err = ioutil.ReadAll(rsp.Body)
-			if err != nil {
+		// There is no client with the mem: and file: HSM used for testing
+		if smClient != nil {
+			if err := smClient.getJSON("/service/values/class", new(interface{})); err != nil {
 				httpStatus = http.StatusInternalServerError
 				bssStatus.HSMStatus = "error"
-				log.Printf("Cannot read /service/values/class response from HSM: %s", err)
+				log.Printf("Cannot connect to HSM: %s", err)
 			}
-			rsp.Body.Close()
+			st := smClient.Stats()
+			bssStatus.HSMClient = &st
+		}
+		state := hsmStateStatus()
+		bssStatus.HSMState = &state
+	}
+	if strings.Contains(strings.ToUpper(req.URL.Path), "SCN") ||
+		strings.Contains(strings.ToUpper(req.URL.Path), "ALL") {
+		// There is no notifier in the tests
+		if notifier != nil {
+			st := notifier.Status()
+			bssStatus.SCN = &st
 		}
 	}
 	if strings.Contains(strings.ToUpper(req.URL.Path), "ETCD") ||
@@ -34,17 +34,16 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
 	rf "github.com/Cray-HPE/hms-smd/pkg/redfish"
 	"github.com/Cray-HPE/hms-smd/pkg/sm"
 )
@@ -52,12 +51,8 @@ import (
 const badMAC = "not available"
 const undefinedMAC = "ff:ff:ff:ff:ff:ff"
 
-type SMComponent struct {
-	base.Component
-	Fqdn            string   `json:"FQDN"`
-	Mac             []string `json:"MAC"`
-	EndpointEnabled bool     `json:"EndpointEnabled"`
-}
+// The component type is shared with API clients through the bssTypes package.
+type SMComponent = bssTypes.SMComponent
 
 type SMData struct {
 	Components []SMComponent                    `json:"Components"`
@@ -65,21 +60,101 @@ type SMData struct {
 }
 
 var (
-	smMutex     sync.Mutex
-	smData      *SMData
-	smClient    *http.Client
-	smDataMap   map[string]SMComponent
-	smBaseURL   string
-	smJSONFile  string
-	smTimeStamp int64
+	smMutex      sync.Mutex // Held only to read or replace the state
+	smFetchMutex sync.Mutex // Held for a fetch of the whole state, one at a time
+	smData       *SMData
+	smClient     *hsmClient
+	smDataIndex  *smIndex
+	smTimeStamp  int64
+	smFetches    int64 // Fetches of the whole state made so far
 )
 
-func makeSmMap(state *SMData) map[string]SMComponent {
-	m := make(map[string]SMComponent)
-	for _, v := range state.Components {
-		m[v.ID] = v
+// Indexes of the components of the HSM state, positions in comps.  They are
+// rebuilt whenever the state is refreshed, so that looking up a node does not
+// scan every component.  Where several components have the same MAC or NID,
+// the first of them is indexed.
+type smIndex struct {
+	comps  []SMComponent
+	byID   map[string]int
+	byMAC  map[string]int // Normalized MACs, leaving out empty slots
+	byNID  map[int64]int
+	byFQDN map[string]int // Lower case
+}
+
+func makeSmIndex(state *SMData) *smIndex {
+	var comps []SMComponent
+	if state != nil {
+		comps = state.Components
+	}
+	x := &smIndex{
+		comps:  comps,
+		byID:   make(map[string]int, len(comps)),
+		byMAC:  make(map[string]int, 2*len(comps)),
+		byNID:  make(map[int64]int, len(comps)),
+		byFQDN: make(map[string]int, len(comps)),
+	}
+	for i, v := range comps {
+		if _, ok := x.byID[v.ID]; !ok {
+			x.byID[v.ID] = i
+		}
+		if !strings.EqualFold(v.State, "empty") {
+			for _, m := range v.Mac {
+				if m = normalizeMAC(m); m != "" {
+					if _, ok := x.byMAC[m]; !ok {
+						x.byMAC[m] = i
+					}
+				}
+			}
+		}
+		if nid, err := v.NID.Int64(); err == nil {
+			if _, ok := x.byNID[nid]; !ok {
+				x.byNID[nid] = i
+			}
+		}
+		if f := strings.ToLower(v.Fqdn); f != "" {
+			if _, ok := x.byFQDN[f]; !ok {
+				x.byFQDN[f] = i
+			}
+		}
 	}
-	return m
+	return x
+}
+
+func (x *smIndex) comp(i int, ok bool) (SMComponent, bool) {
+	if !ok {
+		return SMComponent{}, false
+	}
+	return x.comps[i], true
+}
+
+// The canonical form of a MAC address, lower case with colons, so that
+// 00-1E-67-E3-46-93 and 001e.67e3.4693 both match 00:1e:67:e3:46:93.  A
+// string which is not a MAC address is only made lower case.
+func normalizeMAC(mac string) string {
+	digits := make([]byte, 0, 12)
+	for i := 0; i < len(mac); i++ {
+		c := mac[i]
+		switch {
+		case c == ':' || c == '-' || c == '.':
+		case '0' <= c && c <= '9', 'a' <= c && c <= 'f':
+			digits = append(digits, c)
+		case 'A' <= c && c <= 'F':
+			digits = append(digits, c+'a'-'A')
+		default:
+			return strings.ToLower(mac)
+		}
+	}
+	if len(digits) != 12 {
+		return strings.ToLower(mac)
+	}
+	ret := make([]byte, 0, 17)
+	for i := 0; i < 12; i += 2 {
+		if i > 0 {
+			ret = append(ret, ':')
+		}
+		ret = append(ret, digits[i], digits[i+1])
+	}
+	return string(ret)
 }
 
 func SmOpen(base, options string) error {
@@ -101,15 +176,20 @@ func SmOpen(base, options string) error {
 			debugf("Internal data conversion failure: %v", err)
 		}
 		smData = &comps
-		smDataMap = makeSmMap(smData)
+		smDataIndex = makeSmIndex(smData)
+		smInventory = memInventory{}
 		return nil
 	}
 	if u.Scheme == "file" {
-		// The file: interface allows for another method of testing with a
-		// little more flexibilty than the mem: interface, but not quite as
-		// stand-alone.
-		smJSONFile = u.Path
-		debugf("Setting externel HSM data file: %s", smJSONFile)
+		// An inventory file, for systems without HSM
+		smInventory = newFileInventory(u.Path)
+		log.Printf("Using the inventory file %s\n", u.Path)
+		return nil
+	}
+	if u.Scheme == "etcd" {
+		// The inventory edited through the /inventory API
+		smInventory = etcdInventory{}
+		log.Printf("Using the etcd inventory\n")
 		return nil
 	}
 	https := u.Scheme == "https"
@@ -125,17 +205,18 @@ func SmOpen(base, options string) error {
 		}
 	}
 	// Using the Datastore service
-	smClient = new(http.Client)
+	client := new(http.Client)
 	if https && insecure {
 		tcfg := new(tls.Config)
 		tcfg.InsecureSkipVerify = true
 		trans := new(http.Transport)
 		trans.TLSClientConfig = tcfg
-		smClient.Transport = trans
+		client.Transport = trans
 		log.Printf("WARNING: insecure https connection to state manager service\n")
 	}
-	smBaseURL = base + "/hsm/v2"
-	log.Printf("Accessing state manager via %s\n", smBaseURL)
+	smClient = newHSMClient(client, base+"/hsm/v2")
+	smInventory = hsmInventory{}
+	log.Printf("Accessing state manager via %s\n", smClient.baseURL)
 	return nil
 }
 
@@ -182,203 +263,234 @@ func ensureLegalMAC(mac string) string {
 }
 
 func getStateFromHSM() *SMData {
-	if smClient != nil {
-		log.Printf("Retrieving state info from %s", smBaseURL)
-		url := smBaseURL + "/State/Components?type=Node"
-		debugf("url: %s, smClient: %v\n", url, smClient)
-		req, rerr := http.NewRequest(http.MethodGet, url, nil)
-		if rerr != nil {
-			log.Printf("Failed to create HTTP request for '%s': %v", url, rerr)
-			return nil
-		}
-		req.Close = true
-		base.SetHTTPUserAgent(req, serviceName)
-		r, err := smClient.Do(req)
-		if err != nil {
-			log.Printf("Sm State request %s failed: %v", url, err)
-			return nil
-		}
-		debugf("getStateFromHSM(): GET %s -> r: %v, err: %v\n", url, r, err)
-		var comps SMData
-		err = json.NewDecoder(r.Body).Decode(&comps)
-		r.Body.Close()
-		// Set up an indexing map to speed up lookup of components in the list
-		compsIndex := make(map[string]int, len(comps.Components))
-		for i, c := range comps.Components {
-			compsIndex[c.ID] = i
-		}
+	return fetchHSMState(nil)
+}
 
-		url = smBaseURL + "/Inventory/ComponentEndpoints?type=Node"
-		req, rerr = http.NewRequest(http.MethodGet, url, nil)
-		if err != nil {
-			log.Printf("Failed to create HTTP request for '%s': %v", url, rerr)
-			return nil
-		}
-		req.Close = true
-		base.SetHTTPUserAgent(req, serviceName)
-		r, err = smClient.Do(req)
-		if err != nil {
-			log.Printf("Sm Inventory request %s failed: %v", url, err)
-			return nil
-		}
-		debugf("getStateFromHSM(): GET %s -> r: %v, err: %v\n", url, r, err)
-		var ep sm.ComponentEndpointArray
-		ce, err := ioutil.ReadAll(r.Body)
-		err = json.Unmarshal(ce, &ep)
-		debugf("getStateFromHSM(): GET %s -> r: %v, err: %v\n", url, r, err)
-		r.Body.Close()
+// The query parameters selecting components by ID, for fetchHSMState().
+func hsmIDQuery(param string, ids []string) string {
+	var b strings.Builder
+	for _, id := range ids {
+		b.WriteString("&" + param + "=" + url.QueryEscape(id))
+	}
+	return b.String()
+}
 
-		type myCompEndpt struct {
-			ID           string `json:"ID"`
-			Enabled      *bool  `json:"Enabled"`
-			RfEndpointID string `json: "RedfishEndpointID"`
-		}
-		type myCompEndptArray struct {
-			CompEndpts []*myCompEndpt `json:"ComponentEndpoints"`
-		}
-		var mep myCompEndptArray
-		if err == nil {
-			err = json.Unmarshal(ce, &mep)
-		}
+// Fetch the state of the given components from HSM, or of every node if ids
+// is nil.  The group labels and the notifier subscription are only updated
+// when every node is fetched.
+func fetchHSMState(ids []string) *SMData {
+	if smClient == nil {
+		return nil
+	}
+	if ids == nil {
+		log.Printf("Retrieving state info from %s", smClient.baseURL)
+	} else {
+		log.Printf("Retrieving state info of %d components from %s", len(ids), smClient.baseURL)
+	}
+	var comps SMData
+	if err := smClient.getJSON("/State/Components?type=Node"+hsmIDQuery("id", ids), &comps); err != nil {
+		log.Printf("Sm State request failed, keeping the previous state: %s", err)
+		return nil
+	}
+	// Set up an indexing map to speed up lookup of components in the list
+	compsIndex := make(map[string]int, len(comps.Components))
+	for i, c := range comps.Components {
+		compsIndex[c.ID] = i
+	}
 
-		// We use a map rather than a list.  The values in the map don't matter,
-		// just the keys.  This way duplicates get filtered out.  We will most
-		// likely have duplicates in the Redfish Endpoint IDs.
-		cMap := make(map[string]bool)
-		for idx, e := range ep.ComponentEndpoints {
-			debugf("Endpoint: %v\n", e)
-			if cIndex, gotIt := compsIndex[e.ID]; gotIt {
-				comps.Components[cIndex].Fqdn = e.FQDN
-				if e.MACAddr != "" && !strings.EqualFold(e.MACAddr, badMAC) &&
-					!strings.EqualFold(e.MACAddr, undefinedMAC) {
-					comps.Components[cIndex].Mac = append(comps.Components[cIndex].Mac, e.MACAddr)
-				}
-				if mep.CompEndpts[idx].Enabled != nil {
-					debugf("%s: Enable: %s", e.ID, *mep.CompEndpts[idx].Enabled)
-					comps.Components[cIndex].EndpointEnabled = *mep.CompEndpts[idx].Enabled
-				} else {
-					debugf("%s: Enable: nil (true)", e.ID)
-					comps.Components[cIndex].EndpointEnabled = true
-				}
-				switch e.ComponentEndpointType {
-				case sm.CompEPTypeSystem:
+	type myCompEndpt struct {
+		ID           string `json:"ID"`
+		Enabled      *bool  `json:"Enabled"`
+		RfEndpointID string `json:"RedfishEndpointID"`
+	}
+	type myCompEndptArray struct {
+		CompEndpts []*myCompEndpt `json:"ComponentEndpoints"`
+	}
+	var ep sm.ComponentEndpointArray
+	var mep myCompEndptArray
+	url := "/Inventory/ComponentEndpoints?type=Node" + hsmIDQuery("id", ids)
+	if err := smClient.getJSON(url, &ep, &mep); err != nil {
+		log.Printf("Sm Inventory request failed, keeping the previous state: %s", err)
+		return nil
+	}
+
+	// We use a map rather than a list.  The values in the map don't matter,
+	// just the keys.  This way duplicates get filtered out.  We will most
+	// likely have duplicates in the Redfish Endpoint IDs.
+	cMap := make(map[string]bool)
+	for idx, e := range ep.ComponentEndpoints {
+		if e == nil {
+			continue
+		}
+		debugf("Endpoint: %v\n", e)
+		if cIndex, gotIt := compsIndex[e.ID]; gotIt {
+			comps.Components[cIndex].Fqdn = e.FQDN
+			if e.MACAddr != "" && !strings.EqualFold(e.MACAddr, badMAC) &&
+				!strings.EqualFold(e.MACAddr, undefinedMAC) {
+				comps.Components[cIndex].Mac = append(comps.Components[cIndex].Mac, e.MACAddr)
+			}
+			if idx < len(mep.CompEndpts) && mep.CompEndpts[idx] != nil && mep.CompEndpts[idx].Enabled != nil {
+				debugf("%s: Enable: %t", e.ID, *mep.CompEndpts[idx].Enabled)
+				comps.Components[cIndex].EndpointEnabled = *mep.CompEndpts[idx].Enabled
+			} else {
+				debugf("%s: Enable: nil (true)", e.ID)
+				comps.Components[cIndex].EndpointEnabled = true
+			}
+			switch e.ComponentEndpointType {
+			case sm.CompEPTypeSystem:
+				if e.RedfishSystemInfo != nil {
 					getMacs(&comps.Components[cIndex], e.RedfishSystemInfo.EthNICInfo)
-				case sm.CompEPTypeManager:
-					getMacs(&comps.Components[cIndex], e.RedfishManagerInfo.EthNICInfo)
-				case sm.CompEPTypeChassis:
-					// Nothing
 				}
-				if e.RfEndpointID != "" {
-					cMap[e.RfEndpointID] = true
+			case sm.CompEPTypeManager:
+				if e.RedfishManagerInfo != nil {
+					getMacs(&comps.Components[cIndex], e.RedfishManagerInfo.EthNICInfo)
 				}
+			case sm.CompEPTypeChassis:
+				// Nothing
+			}
+			if e.RfEndpointID != "" {
+				cMap[e.RfEndpointID] = true
 			}
 		}
+	}
 
-		//ip address
-		url = smBaseURL + "/Inventory/EthernetInterfaces?type=Node"
-		req, rerr = http.NewRequest(http.MethodGet, url, nil)
-		if err != nil {
-			log.Printf("Failed to create HTTP request for '%s': %v", url, rerr)
-			return nil
-		}
-		req.Close = true
-		base.SetHTTPUserAgent(req, serviceName)
-		r, err = smClient.Do(req)
-		if err != nil {
-			log.Printf("Sm Inventory request %s failed: %v", url, err)
-			return nil
-		}
-		debugf("getStateFromHSM(): GET %s -> r: %v, err: %v\n", url, r, err)
-
-		var ethIfaces []sm.CompEthInterfaceV2
-
-		ce, err = ioutil.ReadAll(r.Body)
-		err = json.Unmarshal(ce, &ethIfaces)
-		r.Body.Close()
+	//ip address
+	var ethIfaces []sm.CompEthInterfaceV2
+	url = "/Inventory/EthernetInterfaces?type=Node" + hsmIDQuery("ComponentID", ids)
+	if err := smClient.getJSON(url, &ethIfaces); err != nil {
+		log.Printf("Sm Inventory request failed, keeping the previous state: %s", err)
+		return nil
+	}
 
-		addresses := make(map[string]sm.CompEthInterfaceV2)
-		for _, e := range ethIfaces {
-			debugf("EthInterface: %v\n", e)
-			for _, ip := range e.IPAddrs {
-				if ip.IPAddr != "" {
-					addresses[ip.IPAddr] = e
-				}
+	addresses := make(map[string]sm.CompEthInterfaceV2)
+	for _, e := range ethIfaces {
+		debugf("EthInterface: %v\n", e)
+		for _, ip := range e.IPAddrs {
+			if ip.IPAddr != "" {
+				addresses[ip.IPAddr] = e
 			}
+		}
 
-			// Also see if this EthernetInterface belongs to any Components.
-			for index, _ := range comps.Components {
-				component := comps.Components[index]
-
-				if component.ID == e.CompID {
-					comps.Components[index].Mac = append(comps.Components[index].Mac, ensureLegalMAC(e.MACAddr))
-				}
-			}
+		// Also see if this EthernetInterface belongs to any Components.
+		if index, ok := compsIndex[e.CompID]; ok {
+			comps.Components[index].Mac = append(comps.Components[index].Mac, ensureLegalMAC(e.MACAddr))
 		}
+	}
 
-		comps.IPAddrs = addresses
+	comps.IPAddrs = addresses
+	if ids != nil {
+		return &comps
+	}
 
-		// Now get a list of the keys:
-		compList := make([]string, 0, len(cMap)+len(comps.Components))
-		for i, c := range comps.Components {
-			compList = append(compList, c.ID)
-			debugf("Comp[%d]: %v\n", i, c)
+	if paramLayering {
+		if err := getGroupsFromHSM(&comps, compsIndex); err != nil {
+			log.Printf("Sm groups request failed, keeping the previous state: %s", err)
+			return nil
 		}
-		// Add Redfish Endpoints to the component list for subscription to the notifier
+	}
+
+	// Subscribe to notifications for the nodes and their Redfish endpoints
+	if notifier != nil {
+		endpoints := make([]string, 0, len(cMap))
 		for k := range cMap {
-			compList = append(compList, k)
+			endpoints = append(endpoints, k)
 		}
-		notifier.subscribe(compList)
-		return &comps
+		notifier.setSubscription(scnSubscription(comps.Components, endpoints))
 	}
-	return nil
+	return &comps
 }
 
-func getStateFromFile() (ret *SMData) {
-	if smJSONFile != "" {
-		log.Printf("Retrieving state info from %s", smJSONFile)
-		debugf("Reading HSM info from %s", smJSONFile)
-		f, err := os.Open(smJSONFile)
-		if err != nil {
-			log.Printf("Error: %v\n", err)
-		} else {
-			defer f.Close()
-			var comps SMData
-			dec := json.NewDecoder(f)
-			err = dec.Decode(&comps)
-			if err != nil {
-				log.Printf("Error: %v\n", err)
-			} else {
-				ret = &comps
+// Fill in the HSM group labels of the components, which select the
+// Group-<label> parameter layers.
+func getGroupsFromHSM(comps *SMData, compsIndex map[string]int) error {
+	var groups []sm.Group
+	if err := smClient.getJSON("/groups", &groups); err != nil {
+		return err
+	}
+	for _, g := range groups {
+		for _, id := range g.Members.IDs {
+			if i, ok := compsIndex[id]; ok {
+				comps.Components[i].Groups = append(comps.Components[i].Groups, g.Label)
 			}
 		}
 	}
-	return ret
+	return nil
 }
 
-func getStateInfo() (ret *SMData) {
-	ret = getStateFromHSM()
-	if ret == nil {
-		ret = getStateFromFile()
+func getStateInfo() *SMData {
+	if smInventory == nil {
+		return nil
+	}
+	data, err := smInventory.fetch()
+	if err != nil {
+		log.Printf("Failed to retrieve state info from the %s inventory: %s", smInventory.name(), err)
+		return nil
 	}
-	return ret
+	return data
 }
 
-func protectedGetState(ts int64) (*SMData, map[string]SMComponent) {
+// The current state, or one fetched if it is older than ts, or ts is
+// negative.  Only one fetch is made at a time, and smMutex isn't held while
+// fetching, so lookups use the current state until the fetch is done.
+func protectedGetState(ts int64) (*SMData, *smIndex) {
+	data, idx, fetches, ok := currentState(ts)
+	if ok {
+		return data, idx
+	}
+	smFetchMutex.Lock()
+	defer smFetchMutex.Unlock()
+	// Callers which waited for another fetch use its result, even if it
+	// failed
+	if data, idx, n, ok := currentState(ts); ok || n != fetches {
+		return data, idx
+	}
+
+	changes := hsmChangeKeys()
+	started := time.Now()
+	fetched := getStateInfo()
+	fetchedAt := time.Now()
+	hsmStateFetched(fetched)
+	smMutex.Lock()
+	if ts <= 0 {
+		smTimeStamp = started.Unix()
+	} else {
+		smTimeStamp = ts
+	}
+	smFetches++
+	if fetched != nil {
+		smData = fetched
+		smDataIndex = makeSmIndex(smData)
+		atomic.StoreInt64(&smFetched, fetchedAt.UnixNano())
+		markHSMChanges(changes)
+	}
+	if smDataIndex == nil {
+		smDataIndex = makeSmIndex(smData)
+	}
+	data, idx = smData, smDataIndex
+	smMutex.Unlock()
+
+	// Lookups don't wait for the snapshot to be saved
+	if fetched != nil && smClient != nil {
+		saveHSMSnapshot(fetched, fetchedAt)
+	}
+	return data, idx
+}
+
+// The current state, the number of fetches made so far, and whether the
+// state will do for ts.
+func currentState(ts int64) (*SMData, *smIndex, int64, bool) {
 	smMutex.Lock()
 	defer smMutex.Unlock()
-	if ts < 0 || ts > smTimeStamp || smData == nil {
-		if ts <= 0 {
-			smTimeStamp = time.Now().Unix()
-		} else {
-			smTimeStamp = ts
-		}
-		newSMData := getStateInfo()
-		if newSMData != nil {
-			smData = newSMData
-			smDataMap = makeSmMap(smData)
-		}
+	if ts < 0 && smData != nil && !forcedRefreshDue() {
+		// Forced refreshes are rate limited
+		debugf("Skipping forced refresh of the HSM state")
+		ts = 0
 	}
-	return smData, smDataMap
+	if smDataIndex == nil {
+		smDataIndex = makeSmIndex(smData)
+	}
+	ok := ts >= 0 && ts <= smTimeStamp && smData != nil
+	return smData, smDataIndex, smFetches, ok
 }
 
 func getState() *SMData {
@@ -386,7 +498,7 @@ func getState() *SMData {
 	return data
 }
 
-func getStateAndMap() (*SMData, map[string]SMComponent) {
+func getStateAndIndex() (*SMData, *smIndex) {
 	return protectedGetState(0)
 }
 
@@ -396,47 +508,32 @@ func refreshState(ts int64) *SMData {
 }
 
 func FindSMCompByMAC(mac string) (SMComponent, bool) {
-	state := getState()
-	for _, v := range state.Components {
-		if !strings.EqualFold(v.State, "empty") {
-			for _, m := range v.Mac {
-				if strings.EqualFold(mac, m) {
-					return v, true
-				}
-			}
-		}
-	}
-	return SMComponent{}, false
+	_, idx := getStateAndIndex()
+	i, ok := idx.byMAC[normalizeMAC(mac)]
+	return idx.comp(i, ok)
 }
 
 func FindSMCompByNameInCache(host string) (SMComponent, bool) {
-	_, stateMap := getStateAndMap()
-	if v, ok := stateMap[host]; ok {
-		return v, true
-	}
-	return SMComponent{}, false
+	_, idx := getStateAndIndex()
+	i, ok := idx.byID[host]
+	return idx.comp(i, ok)
 }
 
+// Find a component by its xname or, failing that, its FQDN.
 func FindSMCompByName(host string) (SMComponent, bool) {
 	debugf("Searching SM data for %s\n", host)
-	state := getState()
-	for i, v := range state.Components {
-		debugf("SM data[%d]: %v\n", i, v)
-		if v.ID == host {
-			return v, true
-		}
+	_, idx := getStateAndIndex()
+	i, ok := idx.byID[host]
+	if !ok {
+		i, ok = idx.byFQDN[strings.ToLower(host)]
 	}
-	return SMComponent{}, false
+	return idx.comp(i, ok)
 }
 
 func FindSMCompByNid(nid int) (SMComponent, bool) {
-	state := getState()
-	for _, v := range state.Components {
-		if vnid, err := v.NID.Int64(); err == nil && vnid == int64(nid) {
-			return v, true
-		}
-	}
-	return SMComponent{}, false
+	_, idx := getStateAndIndex()
+	i, ok := idx.byNID[int64(nid)]
+	return idx.comp(i, ok)
 }
 
 func FindXnameByIP(ip string) (string, bool) {
@@ -456,7 +553,9 @@ func FindXnameByIP(ip string) (string, bool) {
 		// If we didn't find the IP, try again with a current timestamp
 		// to force getting new state from HSM. In case the hardware came up
 		// within the last cache eviction period.
-		state = refreshState(time.Now().Unix())
+		// The refresh is rate limited, so that requests from unknown
+		// addresses cannot make BSS fetch everything from HSM each time.
+		state = refreshState(-1)
 		ethIFace, found = state.IPAddrs[ip]
 	}
 	return ethIFace.CompID, found
@@ -0,0 +1,216 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+// Add components to the HSM data for the length of a test.
+func withComponents(tb testing.TB, comps ...SMComponent) {
+	smMutex.Lock()
+	saved := smData
+	data := *smData
+	data.Components = append(append([]SMComponent{}, saved.Components...), comps...)
+	smData = &data
+	smDataIndex = makeSmIndex(smData)
+	smMutex.Unlock()
+	tb.Cleanup(func() {
+		smMutex.Lock()
+		smData = saved
+		smDataIndex = makeSmIndex(saved)
+		smMutex.Unlock()
+	})
+}
+
+func TestNormalizeMAC(t *testing.T) {
+	tests := map[string]string{
+		"00:1E:67:E3:46:93": "00:1e:67:e3:46:93",
+		"00-1e-67-e3-46-93": "00:1e:67:e3:46:93",
+		"001e67e34693":      "00:1e:67:e3:46:93",
+		"001e.67e3.4693":    "00:1e:67:e3:46:93",
+		"not available":     "not available",
+		"00:1E:67":          "00:1e:67",
+	}
+	for in, want := range tests {
+		if got := normalizeMAC(in); got != want {
+			t.Errorf("normalizeMAC(%q) = %q, expected %q", in, got, want)
+		}
+	}
+}
+
+func TestSmIndex(t *testing.T) {
+	for _, mac := range []string{"00:1E:67:E3:46:93", "00-1e-67-e3-46-94", "001e67e34693"} {
+		if c, ok := FindSMCompByMAC(mac); !ok || c.ID != "x0c0s0b0n0" {
+			t.Errorf("FindSMCompByMAC(%s) = %s, %v", mac, c.ID, ok)
+		}
+	}
+	if c, ok := FindSMCompByName("X0C0S1B0N0.test.com"); !ok || c.ID != "x0c0s1b0n0" {
+		t.Errorf("FindSMCompByName() by FQDN = %s, %v", c.ID, ok)
+	}
+	if _, ok := FindSMCompByNid(99999); ok {
+		t.Errorf("FindSMCompByNid() found an unknown NID")
+	}
+
+	// Empty slots are not found by MAC, and the first of duplicates wins
+	withComponents(t,
+		SMComponent{Component: base.Component{ID: "x9c0s9b0n0", State: "Empty", NID: "9001"},
+			Mac: []string{"02:00:00:00:90:01"}},
+		SMComponent{Component: base.Component{ID: "x9c0s9b0n1", State: "Ready", NID: "8"},
+			Mac: []string{"02:00:00:00:90:02"}})
+	if _, ok := FindSMCompByMAC("02:00:00:00:90:01"); ok {
+		t.Errorf("FindSMCompByMAC() found an empty slot")
+	}
+	if c, ok := FindSMCompByNid(8); !ok || c.ID != "x0c0s1b0n0" {
+		t.Errorf("FindSMCompByNid(8) = %s, %v", c.ID, ok)
+	}
+	if c, ok := FindSMCompByNid(9001); !ok || c.ID != "x9c0s9b0n0" {
+		t.Errorf("FindSMCompByNid(9001) = %s, %v", c.ID, ok)
+	}
+}
+
+func TestBootparametersGetByFQDN(t *testing.T) {
+	Store(bssTypes.BootParams{Hosts: []string{"x0c0s4b0n0"}, Params: "fqdn"})
+	defer deleteHost("x0c0s4b0n0")
+	rr := serveRequest(t, bootParameters, http.MethodGet,
+		"/bootparameters?name=x0c0s4b0n0.test.com&mac=00:1E:67:DF:F7:0D&nid=20", nil)
+	var bps []bssTypes.BootParams
+	json.Unmarshal(rr.Body.Bytes(), &bps)
+	if len(bps) != 1 || bps[0].Hosts[0] != "x0c0s4b0n0" || bps[0].Params != "fqdn" {
+		t.Errorf("GET bootparameters returned %d: %s", rr.Code, rr.Body)
+	}
+}
+
+// A large system, for the benchmarks
+func benchComponents(n int) []SMComponent {
+	comps := make([]SMComponent, n)
+	for i := range comps {
+		id := fmt.Sprintf("x%dc0s%db0n%d", 1000+i/512, (i/4)%128, i%4)
+		comps[i] = SMComponent{
+			Component: base.Component{ID: id, State: "Ready", Role: "Compute",
+				NID: json.Number(strconv.Itoa(100000 + i))},
+			Fqdn:            id + ".bench.com",
+			Mac:             []string{fmt.Sprintf("02:00:00:%02x:%02x:%02x", i>>16, (i>>8)&0xff, i&0xff)},
+			EndpointEnabled: true,
+		}
+	}
+	return comps
+}
+
+const benchNodes = 10000
+
+// The lookup by MAC as it was before the indexes, for comparison.
+func scanSMCompByMAC(mac string) (SMComponent, bool) {
+	for _, v := range getState().Components {
+		if !strings.EqualFold(v.State, "empty") {
+			for _, m := range v.Mac {
+				if strings.EqualFold(mac, m) {
+					return v, true
+				}
+			}
+		}
+	}
+	return SMComponent{}, false
+}
+
+func BenchmarkFindSMCompByMACScan(b *testing.B) {
+	comps := benchComponents(benchNodes)
+	withComponents(b, comps...)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := scanSMCompByMAC(comps[i%len(comps)].Mac[0]); !ok {
+			b.Fatal("MAC not found")
+		}
+	}
+}
+
+func BenchmarkFindSMCompByMAC(b *testing.B) {
+	comps := benchComponents(benchNodes)
+	withComponents(b, comps...)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := FindSMCompByMAC(comps[i%len(comps)].Mac[0]); !ok {
+			b.Fatal("MAC not found")
+		}
+	}
+}
+
+func BenchmarkFindSMCompByName(b *testing.B) {
+	comps := benchComponents(benchNodes)
+	withComponents(b, comps...)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := FindSMCompByName(comps[i%len(comps)].ID); !ok {
+			b.Fatal("Name not found")
+		}
+	}
+}
+
+func BenchmarkFindSMCompByNid(b *testing.B) {
+	comps := benchComponents(benchNodes)
+	withComponents(b, comps...)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := FindSMCompByNid(100000 + i%len(comps)); !ok {
+			b.Fatal("NID not found")
+		}
+	}
+}
+
+func BenchmarkMakeSmIndex(b *testing.B) {
+	state := &SMData{Components: benchComponents(benchNodes)}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		makeSmIndex(state)
+	}
+}
+
+// GET bootparameters by MAC with an entry for every node
+func BenchmarkBootparametersGetByMAC(b *testing.B) {
+	comps := benchComponents(benchNodes)
+	withComponents(b, comps...)
+	for _, c := range comps {
+		kvstore.Store(paramsPfx+c.ID, `{"params":"bench"}`)
+	}
+	b.Cleanup(func() {
+		for _, c := range comps {
+			kvstore.Delete(paramsPfx + c.ID)
+		}
+	})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mac := comps[i%len(comps)].Mac[0]
+		rr := serveRequest(b, bootParameters, http.MethodGet, "/bootparameters?mac="+mac, nil)
+		if rr.Code != http.StatusOK {
+			b.Fatalf("GET bootparameters returned %d: %s", rr.Code, rr.Body)
+		}
+	}
+}
@@ -0,0 +1,402 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+/*
+ * Boot script templates
+ *
+ * Sites may replace the built-in boot script layout with their own Go
+ * text/template templates.  Templates are kept in the KV store and are
+ * assigned to hosts, roles or Default.  A template is executed with a
+ * bootScriptData, so it can reference {{.Kernel}}, {{.Initrd}}, {{.Params}},
+ * {{.Xname}}, {{.Nid}}, {{.Mac}}, {{.ReferralToken}}, {{.ChainURL}},
+ * {{.Delay}} and so on.  A template may also define a "chain" template, used
+ * for the scripts which only delay and chain back to BSS.  Anything a
+ * template does not provide falls back to the built-in renderer.
+ *
+ * The templates are parsed once and cached for boot script requests.  Every
+ * change to the templates stores a new revision under templatesRevKey, so
+ * that each replica only reads the templates again when they have changed.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+	"github.com/google/uuid"
+)
+
+const templatesPfx = "/templates/"
+const templatesRevKey = "/templates-revision"
+
+// A stored template, parsed.
+type parsedTemplate struct {
+	bt   bssTypes.BootTemplate
+	tmpl *template.Template
+	err  error
+}
+
+// The parsed templates, as of a revision of templatesRevKey.
+var templateCache struct {
+	sync.Mutex
+	loaded bool
+	rev    string
+	tl     []parsedTemplate
+}
+
+// Functions available to templates, mostly for building GRUB scripts.
+var templateFuncs = template.FuncMap{
+	"grubPath":  grubPath,
+	"grubQuote": grubQuote,
+}
+
+// A renderer using a site template, falling back to the built-in renderer
+// of the same format.
+type templateRenderer struct {
+	bootRenderer
+	tmplName string
+	tmpl     *template.Template
+}
+
+func (t templateRenderer) execute(name string, d bootScriptData) (string, error) {
+	var buf bytes.Buffer
+	var err error
+	if name == "" {
+		err = t.tmpl.Execute(&buf, d)
+	} else {
+		err = t.tmpl.ExecuteTemplate(&buf, name, d)
+	}
+	return buf.String(), err
+}
+
+func (t templateRenderer) boot(d bootScriptData) string {
+	script, err := t.execute("", d)
+	if err != nil {
+		log.Printf("Boot script template %s failed for %s, using the built-in script: %s",
+			t.tmplName, d.Xname, err)
+		return t.bootRenderer.boot(d)
+	}
+	return script
+}
+
+func (t templateRenderer) chain(d bootScriptData) string {
+	if t.tmpl.Lookup("chain") == nil {
+		return t.bootRenderer.chain(d)
+	}
+	script, err := t.execute("chain", d)
+	if err != nil {
+		log.Printf("Boot script template %s chain failed for %s, using the built-in script: %s",
+			t.tmplName, d.Xname, err)
+		return t.bootRenderer.chain(d)
+	}
+	return script
+}
+
+func parseTemplate(bt bssTypes.BootTemplate) (*template.Template, error) {
+	return template.New(bt.Name).Funcs(templateFuncs).Parse(bt.Template)
+}
+
+// Sample data used to check that a template executes before it is stored.
+var templateCheckData = bootScriptData{
+	Kernel:        "http://images/kernel",
+	Initrd:        "http://images/initrd",
+	Params:        "console=ttyS0 xname=x0c0s0b0n0 nid=1",
+	Xname:         "x0c0s0b0n0",
+	Nid:           "1",
+	Mac:           "00:00:00:00:00:01",
+	Role:          "Compute",
+	ReferralToken: "00000000-0000-0000-0000-000000000000",
+	Arch:          "${buildarch}",
+	ChainURL:      "https://api-gw-service-nmn.local/apis/bss/boot/v1/bootscript?mac=00:00:00:00:00:01",
+	Delay:         30,
+}
+
+// Validate a template and fill in the defaults.  The template must parse,
+// and must execute against sample data.
+func checkTemplate(bt *bssTypes.BootTemplate) error {
+	if bt.Name == "" || strings.ContainsAny(bt.Name, "/ \t\n") {
+		return fmt.Errorf("invalid template name '%s'", bt.Name)
+	}
+	if bt.Format == "" {
+		bt.Format = defaultBootFormat
+	}
+	bt.Format = strings.ToLower(bt.Format)
+	rdr, ok := bootRenderers[bt.Format]
+	if !ok {
+		return fmt.Errorf("unknown boot script format '%s', expected one of %s",
+			bt.Format, bootFormatNames())
+	}
+	if strings.TrimSpace(bt.Template) == "" {
+		return fmt.Errorf("template %s is empty", bt.Name)
+	}
+	tmpl, err := parseTemplate(*bt)
+	if err != nil {
+		return err
+	}
+	t := templateRenderer{rdr, bt.Name, tmpl}
+	d := templateCheckData
+	d.Arch = rdr.archVar()
+	if _, err = t.execute("", d); err != nil {
+		return err
+	}
+	if tmpl.Lookup("chain") != nil {
+		if _, err = t.execute("chain", d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getTemplates() ([]bssTypes.BootTemplate, error) {
+	kvl, err := kvstore.GetRange(templatesPfx+keyMin, templatesPfx+keyMax)
+	if err != nil {
+		return nil, err
+	}
+	var ret []bssTypes.BootTemplate
+	for _, kv := range kvl {
+		var bt bssTypes.BootTemplate
+		if e := json.Unmarshal([]byte(kv.Value), &bt); e != nil {
+			log.Printf("Skipping bad boot script template %s: %s", kv.Key, e)
+			continue
+		}
+		ret = append(ret, bt)
+	}
+	return ret, nil
+}
+
+// The parsed templates, read again only if they have changed since they were
+// cached.
+func cachedTemplates() ([]parsedTemplate, error) {
+	rev, _, err := kvstore.Get(templatesRevKey)
+	if err != nil {
+		return nil, err
+	}
+	templateCache.Lock()
+	defer templateCache.Unlock()
+	if templateCache.loaded && rev == templateCache.rev {
+		return templateCache.tl, nil
+	}
+	tl, err := getTemplates()
+	if err != nil {
+		return nil, err
+	}
+	ptl := make([]parsedTemplate, len(tl))
+	for i, bt := range tl {
+		ptl[i].bt = bt
+		ptl[i].tmpl, ptl[i].err = parseTemplate(bt)
+	}
+	templateCache.loaded, templateCache.rev, templateCache.tl = true, rev, ptl
+	return ptl, nil
+}
+
+// Store a template, and a new revision of the templates.
+func storeTemplate(bt bssTypes.BootTemplate) error {
+	err := storeData(templatesPfx+bt.Name, bt)
+	if err == nil {
+		err = kvstore.Store(templatesRevKey, uuid.New().String())
+	}
+	return err
+}
+
+// Delete a template, and store a new revision of the templates.
+func deleteTemplate(name string) error {
+	err := kvstore.Delete(templatesPfx + name)
+	if err == nil {
+		err = kvstore.Store(templatesRevKey, uuid.New().String())
+	}
+	return err
+}
+
+func getTemplate(name string) (bssTypes.BootTemplate, bool, error) {
+	var bt bssTypes.BootTemplate
+	val, exists, err := kvstore.Get(templatesPfx + name)
+	if err == nil && exists {
+		err = json.Unmarshal([]byte(val), &bt)
+	}
+	return bt, exists, err
+}
+
+// Check that no other template of the same format is assigned to any of the
+// hosts of bt, so that the template used for a node is never ambiguous.
+func templateConflict(bt bssTypes.BootTemplate) error {
+	tl, err := getTemplates()
+	if err != nil {
+		return err
+	}
+	for _, other := range tl {
+		if other.Name == bt.Name || other.Format != bt.Format {
+			continue
+		}
+		for _, h := range bt.Hosts {
+			for _, oh := range other.Hosts {
+				if strings.EqualFold(h, oh) {
+					return fmt.Errorf("%s is already assigned to %s template %s",
+						h, bt.Format, other.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Find the template for a node, looking for one assigned to the host, then
+// its role and then Default.  If there is none, rdr is returned.
+func templateFor(rdr bootRenderer, host, role string) bootRenderer {
+	tl, err := cachedTemplates()
+	if err != nil || len(tl) == 0 {
+		return rdr
+	}
+	for _, name := range []string{host, role, DefaultTag} {
+		if name == "" {
+			continue
+		}
+		for _, pt := range tl {
+			if pt.bt.Format != rdr.name() {
+				continue
+			}
+			for _, h := range pt.bt.Hosts {
+				if strings.EqualFold(h, name) {
+					if pt.err != nil {
+						log.Printf("Boot script template %s is invalid, using the built-in script: %s",
+							pt.bt.Name, pt.err)
+						return rdr
+					}
+					return templateRenderer{rdr, pt.bt.Name, pt.tmpl}
+				}
+			}
+		}
+	}
+	return rdr
+}
+
+func templatesGetAPI(w http.ResponseWriter, r *http.Request, name string) {
+	debugf("templatesGetAPI(): Received request %v\n", r.URL)
+	if name == "" {
+		tl, err := getTemplates()
+		if err != nil {
+			base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+				fmt.Sprintf("Failed to retrieve templates: %s", err))
+			return
+		}
+		if tl == nil {
+			tl = []bssTypes.BootTemplate{}
+		}
+		sendJSON(w, http.StatusOK, tl)
+		return
+	}
+	bt, exists, err := getTemplate(name)
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+			fmt.Sprintf("Failed to retrieve template %s: %s", name, err))
+	} else if !exists {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound,
+			fmt.Sprintf("Template %s does not exist", name))
+	} else {
+		sendJSON(w, http.StatusOK, bt)
+	}
+}
+
+// Store a template.  For POST the template must not exist yet, for PUT it is
+// created or replaced.
+func templatesStoreAPI(w http.ResponseWriter, r *http.Request, name string) {
+	debugf("templatesStoreAPI(): Received request %v\n", r.URL)
+	var bt bssTypes.BootTemplate
+	body, err := ioutil.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(body, &bt)
+	}
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+			fmt.Sprintf("Bad Request: %s", err))
+		return
+	}
+	if name != "" {
+		if bt.Name != "" && bt.Name != name {
+			base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+				fmt.Sprintf("Template name '%s' does not match the path", bt.Name))
+			return
+		}
+		bt.Name = name
+	}
+	if err = checkTemplate(&bt); err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+			fmt.Sprintf("Invalid template: %s", err))
+		return
+	}
+
+	kvMutex.Lock()
+	defer kvMutex.Unlock()
+	if r.Method == http.MethodPost {
+		if _, exists, _ := getTemplate(bt.Name); exists {
+			base.SendProblemDetailsGeneric(w, http.StatusConflict,
+				fmt.Sprintf("Template %s already exists", bt.Name))
+			return
+		}
+	}
+	if err = templateConflict(bt); err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err = storeTemplate(bt); err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Printf("Stored %s boot script template %s for %v", bt.Format, bt.Name, bt.Hosts)
+	status := http.StatusOK
+	if r.Method == http.MethodPost {
+		status = http.StatusCreated
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+}
+
+func templatesDeleteAPI(w http.ResponseWriter, r *http.Request, name string) {
+	debugf("templatesDeleteAPI(): Received request %v\n", r.URL)
+	kvMutex.Lock()
+	defer kvMutex.Unlock()
+	_, exists, err := getTemplate(name)
+	if err == nil && !exists {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound,
+			fmt.Sprintf("Template %s does not exist", name))
+		return
+	}
+	if err == nil {
+		err = deleteTemplate(name)
+	}
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+			fmt.Sprintf("Failed to delete template %s: %s", name, err))
+		return
+	}
+	log.Printf("Deleted boot script template %s", name)
+	w.WriteHeader(http.StatusNoContent)
+}
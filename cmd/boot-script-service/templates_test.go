@@ -0,0 +1,126 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+func TestTemplatesAPI(t *testing.T) {
+	compute := bssTypes.BootTemplate{
+		Hosts:    []string{"Compute"},
+		Template: "#!ipxe\nkernel {{.Kernel}} {{.Params}}\nboot\n",
+	}
+	if rr := serveRequest(t, templates, http.MethodPut, "/templates/compute", compute); rr.Code != http.StatusOK {
+		t.Fatalf("PUT returned %d: %s", rr.Code, rr.Body)
+	}
+	defer serveRequest(t, templates, http.MethodDelete, "/templates/compute", nil)
+
+	rr := serveRequest(t, templates, http.MethodGet, "/templates/compute", nil)
+	var got bssTypes.BootTemplate
+	if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &got) != nil {
+		t.Fatalf("GET returned %d: %s", rr.Code, rr.Body)
+	}
+	if got.Name != "compute" || got.Format != "ipxe" {
+		t.Errorf("Expected the name and default format to be filled in, got %+v", got)
+	}
+
+	bad := []struct {
+		name string
+		bt   bssTypes.BootTemplate
+		code int
+	}{
+		{"parse", bssTypes.BootTemplate{Template: "{{.Kernel"}, http.StatusBadRequest},
+		{"field", bssTypes.BootTemplate{Template: "{{.NoSuchField}}"}, http.StatusBadRequest},
+		{"format", bssTypes.BootTemplate{Format: "pxelinux", Template: "x"}, http.StatusBadRequest},
+		{"other", bssTypes.BootTemplate{Hosts: []string{"compute"}, Template: "x"}, http.StatusConflict},
+	}
+	for _, b := range bad {
+		if rr := serveRequest(t, templates, http.MethodPut, "/templates/"+b.name, b.bt); rr.Code != b.code {
+			t.Errorf("PUT %s returned %d, expected %d: %s", b.name, rr.Code, b.code, rr.Body)
+		}
+	}
+	if rr := serveRequest(t, templates, http.MethodPost, "/templates", compute); rr.Code != http.StatusBadRequest {
+		t.Errorf("POST without a name returned %d, expected %d", rr.Code, http.StatusBadRequest)
+	}
+	if rr := serveRequest(t, templates, http.MethodDelete, "/templates/missing", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("DELETE of a missing template returned %d", rr.Code)
+	}
+}
+
+func TestTemplateLookup(t *testing.T) {
+	tmpls := []bssTypes.BootTemplate{
+		{Name: "t-default", Hosts: []string{"Default"}, Template: "default {{.Xname}}"},
+		{Name: "t-storage", Hosts: []string{"Storage"}, Template: "storage {{.Xname}}",
+			Format: "grub"},
+		{Name: "t-host", Hosts: []string{"x0c0s1b0n0"}, Template: "host {{.Xname}}" +
+			`{{define "chain"}}wait {{.Delay}} {{.ChainURL}}{{end}}`},
+	}
+	for _, bt := range tmpls {
+		if err := checkTemplate(&bt); err != nil {
+			t.Fatalf("checkTemplate(%s) failed: %v", bt.Name, err)
+		}
+		storeTemplate(bt)
+		defer deleteTemplate(bt.Name)
+	}
+
+	ipxe, grub := bootRenderers["ipxe"], bootRenderers["grub"]
+	d := bootScriptData{Xname: "x0c0s1b0n0", ChainURL: "http://bss", Delay: 5}
+	tests := []struct {
+		rdr        bootRenderer
+		host, role string
+		boot       string
+		chain      string
+	}{
+		{ipxe, "x0c0s1b0n0", "Compute", "host x0c0s1b0n0", "wait 5 http://bss"},
+		{ipxe, "x0c0s2b0n0", "Compute", "default x0c0s1b0n0", ipxe.chain(d)},
+		{grub, "x0c0s2b0n0", "Storage", "storage x0c0s1b0n0", grub.chain(d)},
+		{grub, "x0c0s2b0n0", "Compute", grub.boot(d), grub.chain(d)},
+	}
+	for _, tt := range tests {
+		rdr := templateFor(tt.rdr, tt.host, tt.role)
+		if got := rdr.boot(d); got != tt.boot {
+			t.Errorf("%s %s %s: boot returned %q, expected %q", tt.rdr.name(), tt.host, tt.role, got, tt.boot)
+		}
+		if got := rdr.chain(d); got != tt.chain {
+			t.Errorf("%s %s %s: chain returned %q, expected %q", tt.rdr.name(), tt.host, tt.role, got, tt.chain)
+		}
+	}
+
+	// The parsed templates are kept until a change stores a new revision
+	bt := tmpls[0]
+	bt.Template = "changed {{.Xname}}"
+	checkTemplate(&bt)
+	storeData(templatesPfx+bt.Name, bt)
+	if got := templateFor(ipxe, "x0c0s2b0n0", "").boot(d); got != "default x0c0s1b0n0" {
+		t.Errorf("Template was parsed again without a new revision: %q", got)
+	}
+	storeTemplate(bt)
+	if got := templateFor(ipxe, "x0c0s2b0n0", "").boot(d); got != "changed x0c0s1b0n0" {
+		t.Errorf("Changed template not used: %q", got)
+	}
+}
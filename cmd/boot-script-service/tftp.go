@@ -0,0 +1,231 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+/*
+ * TFTP server
+ *
+ * A read only TFTP server (RFC 1350) for the artifact server, for firmware
+ * which can only PXE boot over TFTP.  The blksize (RFC 2348) and tsize
+ * (RFC 2349) options are supported.  Each transfer is made from its own
+ * port, as the protocol requires.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	tftpRRQ   = 1
+	tftpDATA  = 3
+	tftpACK   = 4
+	tftpERROR = 5
+	tftpOACK  = 6
+
+	tftpErrNotFound = 1
+	tftpErrIllegal  = 4
+
+	tftpBlockSize    = 512
+	tftpMaxBlockSize = 65464
+)
+
+var tftpListen = ""
+var tftpTimeout = 2 * time.Second
+var tftpRetries = 5
+
+// A read request.
+type tftpRequest struct {
+	filename string
+	mode     string
+	options  map[string]string
+}
+
+func parseTFTPRequest(pkt []byte) (tftpRequest, error) {
+	var req tftpRequest
+	if len(pkt) < 4 || binary.BigEndian.Uint16(pkt) != tftpRRQ {
+		return req, errors.New("only read requests are supported")
+	}
+	fields := bytes.Split(bytes.TrimSuffix(pkt[2:], []byte{0}), []byte{0})
+	if len(fields) < 2 {
+		return req, errors.New("malformed request")
+	}
+	req.filename = string(fields[0])
+	req.mode = strings.ToLower(string(fields[1]))
+	req.options = make(map[string]string)
+	for i := 2; i+1 < len(fields); i += 2 {
+		req.options[strings.ToLower(string(fields[i]))] = string(fields[i+1])
+	}
+	return req, nil
+}
+
+func tftpPacket(op uint16, num uint16, data []byte) []byte {
+	pkt := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint16(pkt, op)
+	binary.BigEndian.PutUint16(pkt[2:], num)
+	return append(pkt, data...)
+}
+
+func tftpError(conn net.PacketConn, addr net.Addr, code uint16, msg string) {
+	conn.WriteTo(tftpPacket(tftpERROR, code, append([]byte(msg), 0)), addr)
+}
+
+// Send a packet until it is acknowledged.
+func tftpSend(conn net.PacketConn, addr net.Addr, pkt []byte, block uint16) error {
+	buf := make([]byte, 512)
+	for try := 0; try < tftpRetries; try++ {
+		if _, err := conn.WriteTo(pkt, addr); err != nil {
+			return err
+		}
+		deadline := time.Now().Add(tftpTimeout)
+		for {
+			conn.SetReadDeadline(deadline)
+			n, from, err := conn.ReadFrom(buf)
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				break
+			} else if err != nil {
+				return err
+			}
+			if from.String() != addr.String() || n < 4 {
+				continue
+			}
+			switch binary.BigEndian.Uint16(buf) {
+			case tftpACK:
+				if binary.BigEndian.Uint16(buf[2:]) == block {
+					return nil
+				}
+			case tftpERROR:
+				return fmt.Errorf("transfer aborted by the client: %s", bytes.TrimRight(buf[4:n], "\x00"))
+			}
+		}
+	}
+	return errors.New("timed out")
+}
+
+// Transfer a file in answer to a read request.
+func tftpTransfer(pkt []byte, addr net.Addr) {
+	conn, err := net.ListenPacket("udp", ":0")
+	if err != nil {
+		log.Printf("TFTP: cannot open a transfer port for %s: %s", addr, err)
+		return
+	}
+	defer conn.Close()
+	req, err := parseTFTPRequest(pkt)
+	if err != nil {
+		tftpError(conn, addr, tftpErrIllegal, err.Error())
+		return
+	}
+	if req.mode != "octet" {
+		tftpError(conn, addr, tftpErrIllegal, "only octet mode is supported")
+		return
+	}
+	f, err := artifactOpen(req.filename)
+	if err != nil {
+		tftpError(conn, addr, tftpErrNotFound, "file not found")
+		log.Printf("TFTP: %s requested %s: %s", addr, req.filename, err)
+		return
+	}
+	defer f.Close()
+
+	blksize := tftpBlockSize
+	var oack []byte
+	if v, ok := req.options["blksize"]; ok {
+		if n, err := strconv.Atoi(v); err == nil && n >= 8 {
+			if n > tftpMaxBlockSize {
+				n = tftpMaxBlockSize
+			}
+			blksize = n
+			oack = append(oack, []byte("blksize\x00"+strconv.Itoa(n)+"\x00")...)
+		}
+	}
+	if _, ok := req.options["tsize"]; ok {
+		if fi, err := f.Stat(); err == nil {
+			oack = append(oack, []byte("tsize\x00"+strconv.FormatInt(fi.Size(), 10)+"\x00")...)
+		}
+	}
+	if oack != nil {
+		pkt := append([]byte{0, tftpOACK}, oack...)
+		if err = tftpSend(conn, addr, pkt, 0); err != nil {
+			log.Printf("TFTP: transfer of %s to %s failed: %s", req.filename, addr, err)
+			return
+		}
+	}
+
+	data := make([]byte, blksize)
+	for block := uint16(1); ; block++ {
+		n, err := io.ReadFull(f, data)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			tftpError(conn, addr, 0, "read error")
+			log.Printf("TFTP: reading %s failed: %s", req.filename, err)
+			return
+		}
+		if err = tftpSend(conn, addr, tftpPacket(tftpDATA, block, data[:n]), block); err != nil {
+			log.Printf("TFTP: transfer of %s to %s failed: %s", req.filename, addr, err)
+			return
+		}
+		if n < blksize {
+			break
+		}
+	}
+	debugf("TFTP: sent %s to %s", req.filename, addr)
+}
+
+// Answer TFTP requests on a connection until it fails, then wait for the
+// transfers in progress to finish.
+func tftpServeConn(conn net.PacketConn) error {
+	var transfers sync.WaitGroup
+	defer transfers.Wait()
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		transfers.Add(1)
+		go func(pkt []byte) {
+			defer transfers.Done()
+			tftpTransfer(pkt, addr)
+		}(append([]byte(nil), buf[:n]...))
+	}
+}
+
+func tftpServe(addr string) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		log.Printf("ERROR: TFTP server cannot listen on %s: %s", addr, err)
+		return
+	}
+	log.Printf("TFTP server listening on %s", addr)
+	if err = tftpServeConn(conn); err != nil {
+		log.Printf("ERROR: TFTP server stopped: %s", err)
+	}
+}
@@ -0,0 +1,366 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+/*
+ * Image validation
+ *
+ * With BSS_IMAGE_VALIDATION set to warn or enforce, the kernel and initrd
+ * URLs of boot parameters and boot profiles are checked when they are
+ * stored, and those of every image in the background each
+ * BSS_IMAGE_VALIDATION_INTERVAL seconds.  http and https URLs are checked
+ * with a HEAD request, or a GET of the first byte if HEAD is not allowed, and
+ * s3 URLs with a GET of the first byte through a presigned URL.  Other paths
+ * are skipped.  The result is recorded on the image record, without adding
+ * to its history.  Each background pass is claimed by storing its start time
+ * under imageValidationRunKey, guarded by the time stored by the last pass,
+ * so that only one replica checks the images each interval.
+ *
+ * The checksum is taken from an x-amz-checksum-sha256 or Digest header when
+ * the server sends one.  If it doesn't and the image has a sha256 in the
+ * catalog, the background check downloads the image to hash it, until it has
+ * been found to match.
+ *
+ * In warn mode problems are only logged.  In enforce mode, boot parameters
+ * and boot profiles whose images can't be downloaded are refused, and boot
+ * scripts are not served with an image whose last check failed.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+const (
+	imageValidationOff     = "off"
+	imageValidationWarn    = "warn"
+	imageValidationEnforce = "enforce"
+
+	imageValidationRunKey = "/image-validation-run"
+)
+
+var imageValidation = imageValidationOff
+var imageValidationInterval = 3600
+var imageValidationClient = &http.Client{Timeout: 10 * time.Second}
+
+// Downloading an image to hash it may take much longer than checking it
+var imageHashClient = &http.Client{Timeout: 10 * time.Minute}
+
+func setImageValidation(mode string) error {
+	switch strings.ToLower(mode) {
+	case "", imageValidationOff:
+		imageValidation = imageValidationOff
+	case imageValidationWarn, imageValidationEnforce:
+		imageValidation = strings.ToLower(mode)
+	default:
+		return fmt.Errorf("unknown mode '%s', expected off, warn or enforce", mode)
+	}
+	return nil
+}
+
+// The URL to check for an image path, which is empty if the path can't be
+// checked.  s3 URLs are presigned for a GET, so can't be checked with HEAD.
+func imageCheckURL(path string) (string, bool, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return "", false, err
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return path, false, nil
+	case "s3":
+		signed, err := checkURL(path)
+		return signed, true, err
+	}
+	return "", false, nil
+}
+
+// The sha256 a response reports for its content, as hex.
+func headerSha256(h http.Header) string {
+	decode := func(v string) string {
+		if b, err := base64.StdEncoding.DecodeString(v); err == nil && len(b) == sha256.Size {
+			return hex.EncodeToString(b)
+		}
+		return ""
+	}
+	if v := h.Get("X-Amz-Checksum-Sha256"); v != "" {
+		return decode(v)
+	}
+	for _, d := range strings.Split(h.Get("Digest"), ",") {
+		kv := strings.SplitN(strings.TrimSpace(d), "=", 2)
+		if len(kv) == 2 && strings.EqualFold(kv[0], "sha-256") {
+			return decode(kv[1])
+		}
+	}
+	return ""
+}
+
+// The size of an image from a HEAD or ranged GET response.
+func responseSize(resp *http.Response) int64 {
+	if cr := resp.Header.Get("Content-Range"); cr != "" {
+		if i := strings.LastIndex(cr, "/"); i >= 0 {
+			if n, err := strconv.ParseInt(cr[i+1:], 10, 64); err == nil {
+				return n
+			}
+		}
+		return 0
+	}
+	if resp.ContentLength > 0 {
+		return resp.ContentLength
+	}
+	return 0
+}
+
+func hashImage(u string) (string, error) {
+	resp, err := imageHashClient.Get(u)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New(resp.Status)
+	}
+	h := sha256.New()
+	if _, err = io.Copy(h, resp.Body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Check that an image can be downloaded, and that it has the sha256 want if
+// that is set.  If the server doesn't report a checksum and hash is set, the
+// image is downloaded to hash it.
+func validateImage(path, want string, hash bool) bssTypes.ImageStatus {
+	st := bssTypes.ImageStatus{Checked: time.Now().UTC().Format(time.RFC3339)}
+	fail := func(err error) bssTypes.ImageStatus {
+		st.Status, st.Error = bssTypes.ImageUnreachable, err.Error()
+		return st
+	}
+	u, ranged, err := imageCheckURL(path)
+	if err != nil {
+		return fail(err)
+	}
+	if u == "" {
+		st.Status = bssTypes.ImageSkipped
+		return st
+	}
+	var resp *http.Response
+	if !ranged {
+		resp, err = imageValidationClient.Head(u)
+		if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+			resp.Body.Close()
+			ranged = true
+		}
+	}
+	if ranged {
+		req, _ := http.NewRequest(http.MethodGet, u, nil)
+		req.Header.Set("Range", "bytes=0-0")
+		resp, err = imageValidationClient.Do(req)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	st.HTTPStatus = resp.StatusCode
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fail(errors.New(resp.Status))
+	}
+	st.Size = responseSize(resp)
+	st.Sha256 = headerSha256(resp.Header)
+	if want != "" && st.Sha256 == "" && hash {
+		if st.Sha256, err = hashImage(u); err != nil {
+			return fail(fmt.Errorf("download failed: %s", err))
+		}
+	}
+	st.Status = bssTypes.ImageOK
+	if want != "" && st.Sha256 != "" && !strings.EqualFold(want, st.Sha256) {
+		st.Status = bssTypes.ImageChecksumMismatch
+		st.Error = fmt.Sprintf("sha256 is %s, expected %s", st.Sha256, strings.ToLower(want))
+	}
+	return st
+}
+
+func imageFailed(st *bssTypes.ImageStatus) bool {
+	return st != nil && (st.Status == bssTypes.ImageUnreachable || st.Status == bssTypes.ImageChecksumMismatch)
+}
+
+// Record the validation status of an image, unless the image has been
+// changed since it was read.
+func recordImageStatus(key, prev string, st bssTypes.ImageStatus) {
+	var imdata ImageData
+	if err := json.Unmarshal([]byte(prev), &imdata); err != nil {
+		return
+	}
+	if imageFailed(&st) && (imdata.Validation == nil || imdata.Validation.Status != st.Status) {
+		log.Printf("WARNING: Image %s (%s) is %s: %s", key, imdata.Path, st.Status, st.Error)
+	}
+	imdata.Validation = &st
+	op := storeOp(key, imdata)
+	op.guarded, op.prev, op.prevExists = true, prev, true
+	if _, err := applyBatch([]kvOp{op}); err != nil && !errors.Is(err, errConflict) {
+		log.Printf("Failed to record the validation of image %s: %s", key, err)
+	}
+}
+
+// Validate a stored image and record the result.
+func validateImageKey(key string) {
+	val, exists, err := kvstore.Get(key)
+	var imdata ImageData
+	if err != nil || !exists || json.Unmarshal([]byte(val), &imdata) != nil {
+		return
+	}
+	prev := imdata.Validation
+	verified := prev != nil && imdata.Sha256 != "" && strings.EqualFold(prev.Sha256, imdata.Sha256)
+	st := validateImage(imdata.Path, imdata.Sha256, !verified)
+	if verified && st.Status == bssTypes.ImageOK && st.Sha256 == "" {
+		st.Sha256 = prev.Sha256
+	}
+	recordImageStatus(key, val, st)
+}
+
+// Validate every image.
+func validateImages() {
+	for _, imtype := range []string{kernelImageType, initrdImageType} {
+		kvl, err := getImages(imtype)
+		if err != nil {
+			log.Printf("Failed to get the %s images to validate: %s", imtype, err)
+			continue
+		}
+		for _, kv := range kvl {
+			validateImageKey(kv.Key)
+		}
+	}
+}
+
+// Claim the background validation of every image due at now, unless
+// another replica already has.  The time until the next one is due is
+// returned too.
+func claimImageValidation(now time.Time) (bool, time.Duration) {
+	interval := time.Duration(imageValidationInterval) * time.Second
+	val, exists, err := kvstore.Get(imageValidationRunKey)
+	if err != nil {
+		log.Printf("Failed to get the last image validation: %s", err)
+		return false, interval
+	}
+	if last, e := strconv.ParseInt(val, 10, 64); exists && e == nil {
+		if wait := time.Unix(last, 0).Add(interval).Sub(now); wait > 0 {
+			return false, wait
+		}
+	}
+	op := guardOp(kvOp{key: imageValidationRunKey, value: strconv.FormatInt(now.Unix(), 10)}, val, 0, exists)
+	if _, err = applyBatch([]kvOp{op}); err != nil {
+		if !errors.Is(err, errConflict) {
+			log.Printf("Failed to claim the image validation: %s", err)
+		}
+		return false, interval
+	}
+	return true, interval
+}
+
+func imageValidator() {
+	for {
+		claimed, wait := claimImageValidation(time.Now())
+		if claimed {
+			validateImages()
+		}
+		time.Sleep(wait)
+	}
+}
+
+// The result of checking an image of boot parameters or a profile.
+type imageCheck struct {
+	imtype string
+	path   string
+	st     bssTypes.ImageStatus
+}
+
+// Check the images of boot parameters or a boot profile being stored.  In
+// enforce mode an error is returned if one can't be downloaded or has the
+// wrong checksum.
+func checkImagePaths(kernel, initrd string) ([]imageCheck, error) {
+	if imageValidation == imageValidationOff {
+		return nil, nil
+	}
+	var checks []imageCheck
+	for _, c := range []imageCheck{{imtype: kernelImageType, path: kernel}, {imtype: initrdImageType, path: initrd}} {
+		if c.path == "" {
+			continue
+		}
+		want := ""
+		if key := imageFind(c.path, c.imtype); key != "" {
+			imdata, _ := getImage(key, "")
+			want = imdata.Sha256
+		}
+		c.st = validateImage(c.path, want, false)
+		checks = append(checks, c)
+		if imageFailed(&c.st) {
+			msg := fmt.Sprintf("The %s %s is %s: %s", c.imtype, c.path, c.st.Status, c.st.Error)
+			if imageValidation == imageValidationEnforce {
+				return checks, paramsError(msg)
+			}
+			log.Printf("WARNING: %s", msg)
+		}
+	}
+	return checks, nil
+}
+
+// Record the results of checkImagePaths() on the images which were stored.
+func recordImageChecks(checks []imageCheck) {
+	for _, c := range checks {
+		key := imageFind(c.path, c.imtype)
+		if key == "" {
+			continue
+		}
+		if val, exists, err := kvstore.Get(key); err == nil && exists {
+			recordImageStatus(key, val, c.st)
+		}
+	}
+}
+
+// In enforce mode, refuse to boot with an image whose last check failed.
+func checkBootImages(bd BootData) error {
+	if imageValidation != imageValidationEnforce {
+		return nil
+	}
+	for _, im := range []ImageData{bd.Kernel, bd.Initrd} {
+		if imageFailed(im.Validation) {
+			return fmt.Errorf("image %s is %s: %s", im.Path, im.Validation.Status, im.Validation.Error)
+		}
+	}
+	return nil
+}
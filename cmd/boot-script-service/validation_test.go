@@ -0,0 +1,197 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+const imageContent = "kernel image"
+
+func imageServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/kernel":
+			if r.Method == http.MethodHead {
+				w.Header().Set("Content-Length", "12")
+				return
+			}
+			w.Write([]byte(imageContent))
+		case "/digest":
+			sum := sha256.Sum256([]byte(imageContent))
+			w.Header().Set("Digest", "sha-256="+base64.StdEncoding.EncodeToString(sum[:]))
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			if r.Header.Get("Range") == "bytes=0-0" {
+				w.Header().Set("Content-Range", "bytes 0-0/12")
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write([]byte(imageContent[:1]))
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestValidateImage(t *testing.T) {
+	ts := imageServer()
+	defer ts.Close()
+	sum := sha256.Sum256([]byte(imageContent))
+	good := hex.EncodeToString(sum[:])
+	bad := strings.Repeat("0", 64)
+
+	tests := []struct {
+		path   string
+		want   string
+		hash   bool
+		status string
+		size   int64
+		sha    string
+	}{
+		{ts.URL + "/kernel", "", false, bssTypes.ImageOK, 12, ""},
+		{ts.URL + "/no-head", "", false, bssTypes.ImageOK, 12, ""},
+		{ts.URL + "/missing", "", false, bssTypes.ImageUnreachable, 0, ""},
+		{ts.URL + "/digest", good, false, bssTypes.ImageOK, 0, good},
+		{ts.URL + "/digest", bad, false, bssTypes.ImageChecksumMismatch, 0, good},
+		{ts.URL + "/kernel", bad, false, bssTypes.ImageOK, 12, ""},
+		{ts.URL + "/kernel", bad, true, bssTypes.ImageChecksumMismatch, 12, good},
+		{ts.URL + "/kernel", good, true, bssTypes.ImageOK, 12, good},
+		{"/boot/kernel", "", false, bssTypes.ImageSkipped, 0, ""},
+	}
+	for _, tt := range tests {
+		st := validateImage(tt.path, tt.want, tt.hash)
+		if st.Status != tt.status || st.Size != tt.size || st.Sha256 != tt.sha || st.Checked == "" {
+			t.Errorf("validateImage(%s, %s, %v) = %+v, expected %s size %d sha256 %s",
+				tt.path, tt.want, tt.hash, st, tt.status, tt.size, tt.sha)
+		}
+	}
+}
+
+func TestImageValidationModes(t *testing.T) {
+	ts := imageServer()
+	defer ts.Close()
+	defer setImageValidation(imageValidationOff)
+	bp := bssTypes.BootParams{Hosts: []string{"x0c0s2b0n0"}, Kernel: ts.URL + "/missing", Params: "quiet"}
+	defer deleteHost("x0c0s2b0n0")
+	cleanupImages(t, kernelImageType, ts.URL+"/missing", ts.URL+"/kernel")
+
+	setImageValidation(imageValidationEnforce)
+	if err, _ := Store(bp); err == nil {
+		t.Errorf("Enforce mode stored an unreachable kernel")
+	}
+	setImageValidation(imageValidationWarn)
+	if err, _ := Store(bp); err != nil {
+		t.Errorf("Warn mode failed to store an unreachable kernel: %v", err)
+	}
+	if img := getCatalogImage(t, kernelImageType, bp.Kernel); img.Validation == nil ||
+		img.Validation.Status != bssTypes.ImageUnreachable || img.Validation.HTTPStatus != http.StatusNotFound {
+		t.Errorf("Validation not recorded: %+v", img.Validation)
+	}
+	if rr := serveRequest(t, profiles, http.MethodPut, "/profiles/validation", bssTypes.BootProfile{Kernel: bp.Kernel}); rr.Code != http.StatusOK {
+		t.Errorf("Warn mode failed to store a profile: %d", rr.Code)
+	}
+	defer serveRequest(t, profiles, http.MethodDelete, "/profiles/validation", nil)
+
+	// Enforce mode won't serve a boot script with an image which failed
+	bootscript := func() int {
+		rr := serveRequest(t, bootScript, http.MethodGet, "/bootscript?name=x0c0s2b0n0", nil)
+		return rr.Code
+	}
+	if code := bootscript(); code != http.StatusOK {
+		t.Errorf("Warn mode bootscript returned %d", code)
+	}
+	setImageValidation(imageValidationEnforce)
+	if rr := serveRequest(t, profiles, http.MethodPut, "/profiles/validation", bssTypes.BootProfile{Kernel: bp.Kernel}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Enforce mode profile PUT returned %d", rr.Code)
+	}
+	if code := bootscript(); code != http.StatusNotFound {
+		t.Errorf("Enforce mode bootscript returned %d", code)
+	}
+
+	// Once the image is found it is served again
+	bp.Kernel = ts.URL + "/kernel"
+	if err, _ := Store(bp); err != nil {
+		t.Fatalf("Enforce mode failed to store a good kernel: %v", err)
+	}
+	if code := bootscript(); code != http.StatusOK {
+		t.Errorf("Enforce mode bootscript returned %d with a good kernel", code)
+	}
+}
+
+func TestValidateImageKey(t *testing.T) {
+	ts := imageServer()
+	defer ts.Close()
+	sum := sha256.Sum256([]byte(imageContent))
+	reg := bssTypes.Image{Type: initrdImageType, Path: ts.URL + "/kernel", Sha256: hex.EncodeToString(sum[:])}
+	rr := serveRequest(t, images, http.MethodPost, "/images", reg)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("POST returned %d: %s", rr.Code, rr.Body)
+	}
+	defer removeImage(reg.Path, initrdImageType, changeRequest{})
+
+	key := makeImageKey(initrdImageType, reg.Path)
+	validateImageKey(key)
+	img := getCatalogImage(t, initrdImageType, reg.Path)
+	if img.Validation == nil || img.Validation.Status != bssTypes.ImageOK || img.Validation.Sha256 != reg.Sha256 {
+		t.Errorf("Background validation recorded %+v", img.Validation)
+	}
+	// A new checksum discards the old result
+	reg.Sha256 = strings.Repeat("1", 64)
+	serveRequest(t, images, http.MethodPut, "/images/initrd/"+img.ID, reg)
+	if img = getCatalogImage(t, initrdImageType, reg.Path); img.Validation != nil {
+		t.Errorf("Validation kept after a checksum change: %+v", img.Validation)
+	}
+	validateImageKey(key)
+	if img = getCatalogImage(t, initrdImageType, reg.Path); img.Validation == nil ||
+		img.Validation.Status != bssTypes.ImageChecksumMismatch {
+		t.Errorf("Background validation recorded %+v", img.Validation)
+	}
+}
+
+func TestClaimImageValidation(t *testing.T) {
+	defer kvstore.Delete(imageValidationRunKey)
+	interval := time.Duration(imageValidationInterval) * time.Second
+	now := time.Now().Truncate(time.Second)
+	if claimed, wait := claimImageValidation(now); !claimed || wait != interval {
+		t.Errorf("First validation not claimed: %v, %s", claimed, wait)
+	}
+	// Another replica a minute later
+	if claimed, wait := claimImageValidation(now.Add(time.Minute)); claimed || wait != interval-time.Minute {
+		t.Errorf("Validation claimed again: %v, %s", claimed, wait)
+	}
+	if claimed, _ := claimImageValidation(now.Add(interval)); !claimed {
+		t.Errorf("Validation not claimed after the interval")
+	}
+}
@@ -0,0 +1,406 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+//
+// bssctl is a command line tool for operators to manage the Boot Script
+// Service.  It talks to BSS through pkg/bssclient and uses the bssTypes
+// structures, so it stays in step with the server.
+//
+//   bssctl <command> [options]
+//
+// The BSS location and credentials are taken from the -url and -token options
+// or from the BSS_URL and BSS_TOKEN environment variables.
+//
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+	"github.com/Cray-HPE/hms-bss/pkg/bssclient"
+)
+
+const defaultURL = "http://localhost:27778"
+
+type command struct {
+	name  string
+	descr string
+	run   func(ctx context.Context, c *cli, args []string) error
+}
+
+var commands = []command{
+	{"list", "List all boot parameters", cmdList},
+	{"show", "Show boot parameters for selected nodes", cmdShow},
+	{"set", "Create or replace boot parameters (PUT)", cmdSet},
+	{"patch", "Update existing boot parameters (PATCH)", cmdPatch},
+	{"delete", "Delete boot parameters", cmdDelete},
+	{"bootscript", "Render the boot script for a node", cmdBootscript},
+	{"history", "Show bootscript and cloud-init endpoint history", cmdHistory},
+	{"dump", "Dump the BSS state", cmdDump},
+	{"restore", "Restore boot parameters from a dump", cmdRestore},
+}
+
+// Per invocation state, shared by all the commands.
+type cli struct {
+	out    io.Writer
+	url    string
+	token  string
+	format string
+	client *bssclient.Client
+}
+
+// Node selectors common to several commands.  Roles are stored by BSS under
+// their own name, just like xnames, so they are sent as hosts.
+type selectors struct {
+	xname string
+	mac   string
+	nid   string
+	role  string
+}
+
+func (c *cli) flags(name string, defaultFormat string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.out)
+	url := os.Getenv("BSS_URL")
+	if url == "" {
+		url = defaultURL
+	}
+	fs.StringVar(&c.url, "url", url, "BSS base URL, without /boot/v1")
+	fs.StringVar(&c.token, "token", os.Getenv("BSS_TOKEN"), "Bearer token for the API gateway")
+	fs.StringVar(&c.format, "o", defaultFormat, "Output format: table, json or yaml")
+	return fs
+}
+
+func (s *selectors) add(fs *flag.FlagSet) {
+	fs.StringVar(&s.xname, "xname", "", "Comma separated list of xnames")
+	fs.StringVar(&s.mac, "mac", "", "Comma separated list of MAC addresses")
+	fs.StringVar(&s.nid, "nid", "", "Comma separated list of NIDs")
+	fs.StringVar(&s.role, "role", "", "Comma separated list of roles, e.g. Compute or Default")
+}
+
+func splitList(s string) []string {
+	var ret []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+// Fill in the node selection of bp from the command line.
+func (s *selectors) apply(bp *bssTypes.BootParams) error {
+	bp.Hosts = append(bp.Hosts, splitList(s.xname)...)
+	bp.Hosts = append(bp.Hosts, splitList(s.role)...)
+	bp.Macs = append(bp.Macs, splitList(s.mac)...)
+	for _, n := range splitList(s.nid) {
+		nid, err := strconv.ParseInt(n, 0, 32)
+		if err != nil {
+			return fmt.Errorf("invalid nid '%s'", n)
+		}
+		bp.Nids = append(bp.Nids, int32(nid))
+	}
+	return nil
+}
+
+func (s *selectors) empty() bool {
+	return s.xname == "" && s.mac == "" && s.nid == "" && s.role == ""
+}
+
+func (c *cli) parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("%s: unexpected arguments: %s", fs.Name(), strings.Join(fs.Args(), " "))
+	}
+	switch c.format {
+	case "table", "json", "yaml":
+	default:
+		return fmt.Errorf("unknown output format '%s'", c.format)
+	}
+	c.client = bssclient.NewClient(c.url)
+	c.client.Token = c.token
+	c.client.UserAgent = "bssctl"
+	return nil
+}
+
+func cmdList(ctx context.Context, c *cli, args []string) error {
+	fs := c.flags("list", "table")
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	bps, err := c.client.GetAllBootParameters(ctx)
+	if err != nil {
+		return err
+	}
+	return c.printParams(bps)
+}
+
+func cmdShow(ctx context.Context, c *cli, args []string) error {
+	var sel selectors
+	var kernel, initrd string
+	fs := c.flags("show", "table")
+	sel.add(fs)
+	fs.StringVar(&kernel, "kernel", "", "Kernel image to show the parameters of")
+	fs.StringVar(&initrd, "initrd", "", "Initrd image to show the parameters of")
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	if sel.empty() && kernel == "" && initrd == "" {
+		return errors.New("show: no nodes or images selected")
+	}
+	bp := bssTypes.BootParams{Kernel: kernel, Initrd: initrd}
+	if err := sel.apply(&bp); err != nil {
+		return err
+	}
+	bps, err := c.client.GetBootParameters(ctx, bp)
+	if err != nil {
+		return err
+	}
+	return c.printParams(bps)
+}
+
+// Shared by set and patch.  The parameters come from an optional JSON or
+// YAML file and are then overridden by the command line.
+func readParams(c *cli, name string, args []string) (bssTypes.BootParams, error) {
+	var bp bssTypes.BootParams
+	var sel selectors
+	var file, kernel, initrd, params string
+	fs := c.flags(name, "table")
+	sel.add(fs)
+	fs.StringVar(&file, "f", "", "JSON or YAML file holding the boot parameters ('-' for stdin)")
+	fs.StringVar(&kernel, "kernel", "", "Kernel image URL")
+	fs.StringVar(&initrd, "initrd", "", "Initrd image URL")
+	fs.StringVar(&params, "params", "", "Kernel command line parameters")
+	if err := c.parse(fs, args); err != nil {
+		return bp, err
+	}
+	if file != "" {
+		if err := readFile(file, &bp); err != nil {
+			return bp, err
+		}
+	}
+	if err := sel.apply(&bp); err != nil {
+		return bp, err
+	}
+	if kernel != "" {
+		bp.Kernel = kernel
+	}
+	if initrd != "" {
+		bp.Initrd = initrd
+	}
+	if params != "" {
+		bp.Params = params
+	}
+	if len(bp.Hosts) == 0 && len(bp.Macs) == 0 && len(bp.Nids) == 0 &&
+		bp.Kernel == "" && bp.Initrd == "" {
+		return bp, fmt.Errorf("%s: no nodes or images selected", name)
+	}
+	return bp, nil
+}
+
+func cmdSet(ctx context.Context, c *cli, args []string) error {
+	bp, err := readParams(c, "set", args)
+	if err != nil {
+		return err
+	}
+	token, err := c.client.PutBootParameters(ctx, bp)
+	if err != nil {
+		return err
+	}
+	if token != "" && c.format == "table" {
+		fmt.Fprintf(c.out, "Referral token: %s\n", token)
+	}
+	return nil
+}
+
+func cmdPatch(ctx context.Context, c *cli, args []string) error {
+	bp, err := readParams(c, "patch", args)
+	if err != nil {
+		return err
+	}
+	return c.client.PatchBootParameters(ctx, bp)
+}
+
+func cmdDelete(ctx context.Context, c *cli, args []string) error {
+	var sel selectors
+	var kernel, initrd string
+	fs := c.flags("delete", "table")
+	sel.add(fs)
+	fs.StringVar(&kernel, "kernel", "", "Kernel image to delete")
+	fs.StringVar(&initrd, "initrd", "", "Initrd image to delete")
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	if sel.empty() && kernel == "" && initrd == "" {
+		return errors.New("delete: no nodes or images selected")
+	}
+	bp := bssTypes.BootParams{Kernel: kernel, Initrd: initrd}
+	if err := sel.apply(&bp); err != nil {
+		return err
+	}
+	return c.client.DeleteBootParameters(ctx, bp)
+}
+
+func cmdBootscript(ctx context.Context, c *cli, args []string) error {
+	var q bssclient.BootscriptQuery
+	fs := c.flags("bootscript", "table")
+	fs.StringVar(&q.Name, "xname", "", "Node xname")
+	fs.StringVar(&q.Mac, "mac", "", "Node MAC address")
+	fs.IntVar(&q.Nid, "nid", 0, "Node NID")
+	fs.StringVar(&q.Arch, "arch", "", "Node architecture, as reported by iPXE")
+	fs.StringVar(&q.Format, "format", "", "Boot script format: ipxe or grub (default depends on the node role)")
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	script, err := c.client.GetBootscript(ctx, q)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(c.out, script)
+	return err
+}
+
+func cmdHistory(ctx context.Context, c *cli, args []string) error {
+	var name, endpoint string
+	fs := c.flags("history", "table")
+	fs.StringVar(&name, "xname", "", "Node xname")
+	fs.StringVar(&endpoint, "endpoint", "", "Endpoint type: bootscript or user-data")
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	hist, err := c.client.GetEndpointHistory(ctx, name, bssTypes.EndpointType(endpoint))
+	if err != nil {
+		return err
+	}
+	return c.printHistory(hist)
+}
+
+func cmdDump(ctx context.Context, c *cli, args []string) error {
+	fs := c.flags("dump", "json")
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	state, err := c.client.DumpState(ctx)
+	if err != nil {
+		return err
+	}
+	if c.format == "table" {
+		return c.printParams(state.Params)
+	}
+	return c.print(state)
+}
+
+// Restore the boot parameters of a dump.  The components in the dump come
+// from HSM and are not restored.
+func cmdRestore(ctx context.Context, c *cli, args []string) error {
+	var file string
+	fs := c.flags("restore", "table")
+	fs.StringVar(&file, "f", "", "Dump file, in JSON or YAML ('-' for stdin)")
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	if file == "" {
+		return errors.New("restore: a dump file is required")
+	}
+	var state bssTypes.DumpState
+	if err := readFile(file, &state); err != nil {
+		return err
+	}
+	failed := 0
+	for _, bp := range state.Params {
+		if _, err := c.client.PutBootParameters(ctx, bp); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", paramsName(bp), err)
+			failed++
+		}
+	}
+	fmt.Fprintf(c.out, "Restored %d of %d entries\n", len(state.Params)-failed, len(state.Params))
+	if failed > 0 {
+		return fmt.Errorf("restore: %d entries failed", failed)
+	}
+	return nil
+}
+
+func readFile(name string, v interface{}) error {
+	var data []byte
+	var err error
+	if name == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(name)
+	}
+	if err != nil {
+		return err
+	}
+	if err = decode(data, v); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
+
+func usage(out io.Writer) {
+	fmt.Fprintf(out, "Usage: bssctl <command> [options]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-12s %s\n", cmd.name, cmd.descr)
+	}
+	fmt.Fprintf(out, "\nRun 'bssctl <command> -h' for the options of a command.\n")
+}
+
+func run(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		usage(out)
+		return errors.New("no command given")
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(ctx, &cli{out: out}, args[1:])
+		}
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage(out)
+		return nil
+	}
+	usage(out)
+	return fmt.Errorf("unknown command '%s'", args[0])
+}
+
+func main() {
+	err := run(context.Background(), os.Args[1:], os.Stdout)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bssctl: %v\n", err)
+		os.Exit(1)
+	}
+}
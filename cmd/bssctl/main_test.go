@@ -0,0 +1,109 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+func TestListTable(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]bssTypes.BootParams{
+			{Kernel: "s3://boot/k", Params: "quiet"},
+			{Hosts: []string{"Compute"}, Kernel: "s3://boot/k", Initrd: "s3://boot/i"},
+		})
+	}))
+	defer ts.Close()
+
+	var out bytes.Buffer
+	if err := run(context.Background(), []string{"list", "-url", ts.URL}, &out); err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "NAME") {
+		t.Fatalf("Unexpected table:\n%s", out.String())
+	}
+	if f := strings.Fields(lines[1]); f[0] != "(kernel)" || f[2] != "-" || f[3] != "quiet" {
+		t.Errorf("Unexpected kernel row %q", lines[1])
+	}
+	if f := strings.Fields(lines[2]); f[0] != "Compute" || f[2] != "s3://boot/i" {
+		t.Errorf("Unexpected role row %q", lines[2])
+	}
+}
+
+func TestSetFromYAML(t *testing.T) {
+	var got bssTypes.BootParams
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("Expected PUT, got %s", r.Method)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &got)
+	}))
+	defer ts.Close()
+
+	file := filepath.Join(t.TempDir(), "bp.yaml")
+	yml := "kernel: s3://boot/k\nparams: console=ttyS0\ncloud-init:\n  meta-data:\n    foo: bar\n"
+	if err := os.WriteFile(file, []byte(yml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"set", "-url", ts.URL, "-f", file, "-xname", "x0c0s1b0n0", "-nid", "1,2"}
+	if err := run(context.Background(), args, ioutil.Discard); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if got.Kernel != "s3://boot/k" || got.Params != "console=ttyS0" {
+		t.Errorf("File contents not sent: %+v", got)
+	}
+	if len(got.Hosts) != 1 || len(got.Nids) != 2 || got.Nids[1] != 2 {
+		t.Errorf("Selectors not applied: %+v", got)
+	}
+	if got.CloudInit.MetaData["foo"] != "bar" {
+		t.Errorf("Cloud-init meta-data not decoded: %+v", got.CloudInit)
+	}
+}
+
+func TestBadArguments(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"frobnicate"},
+		{"delete", "-url", "http://localhost:1"},
+		{"list", "-o", "xml"},
+		{"show", "-nid", "one"},
+	}
+	for _, args := range tests {
+		if err := run(context.Background(), args, ioutil.Discard); err == nil {
+			t.Errorf("Expected %v to fail", args)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+	"gopkg.in/yaml.v2"
+)
+
+// YAML is produced and consumed by way of JSON so that the field names are
+// the same JSON names the API uses, without needing yaml tags in bssTypes.
+
+func toYAML(v interface{}) ([]byte, error) {
+	j, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err = json.Unmarshal(j, &generic); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(generic)
+}
+
+// yaml.v2 decodes mappings with interface{} keys, which encoding/json
+// cannot handle, so convert them to string keys.
+func jsonCompatible(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[fmt.Sprint(k)] = jsonCompatible(val)
+		}
+		return m
+	case []interface{}:
+		for i := range t {
+			t[i] = jsonCompatible(t[i])
+		}
+	}
+	return v
+}
+
+// Decode JSON or YAML data into v using the JSON field names.
+func decode(data []byte, v interface{}) error {
+	if json.Valid(data) {
+		return json.Unmarshal(data, v)
+	}
+	var generic interface{}
+	if err := yaml.Unmarshal(data, &generic); err != nil {
+		return err
+	}
+	j, err := json.Marshal(jsonCompatible(generic))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(j, v)
+}
+
+// Print v as JSON or YAML.  Table output is handled by the callers.
+func (c *cli) print(v interface{}) error {
+	if c.format == "yaml" {
+		y, err := toYAML(v)
+		if err != nil {
+			return err
+		}
+		_, err = c.out.Write(y)
+		return err
+	}
+	enc := json.NewEncoder(c.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// A short name for a boot parameter entry, for tables and messages.
+func paramsName(bp bssTypes.BootParams) string {
+	var names []string
+	names = append(names, bp.Hosts...)
+	names = append(names, bp.Macs...)
+	for _, n := range bp.Nids {
+		names = append(names, "nid"+strconv.Itoa(int(n)))
+	}
+	if len(names) > 0 {
+		return strings.Join(names, ",")
+	}
+	if bp.Kernel != "" {
+		return "(kernel)"
+	}
+	if bp.Initrd != "" {
+		return "(initrd)"
+	}
+	return "-"
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func (c *cli) printParams(bps []bssTypes.BootParams) error {
+	if c.format != "table" {
+		return c.print(bps)
+	}
+	tw := tabwriter.NewWriter(c.out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tKERNEL\tINITRD\tPARAMS")
+	for _, bp := range bps {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", paramsName(bp), dash(bp.Kernel),
+			dash(bp.Initrd), dash(bp.Params))
+	}
+	return tw.Flush()
+}
+
+func (c *cli) printHistory(hist []bssTypes.EndpointAccess) error {
+	if c.format != "table" {
+		return c.print(hist)
+	}
+	tw := tabwriter.NewWriter(c.out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tENDPOINT\tLAST ACCESS")
+	for _, h := range hist {
+		last := time.Unix(h.LastEpoch, 0).UTC().Format(time.RFC3339)
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", h.Name, h.Endpoint, last)
+	}
+	return tw.Flush()
+}
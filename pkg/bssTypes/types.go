@@ -1,6 +1,6 @@
 // MIT License
 //
-// (C) Copyright [2021] Hewlett Packard Enterprise Development LP
+// (C) Copyright [2021,2026] Hewlett Packard Enterprise Development LP
 //
 // Permission is hereby granted, free of charge, to any person obtaining a
 // copy of this software and associated documentation files (the "Software"),
@@ -22,6 +22,12 @@
 
 package bssTypes
 
+import (
+	"encoding/json"
+
+	base "github.com/Cray-HPE/hms-base"
+)
+
 type PhoneHome struct {
 	PublicKeyDSA     string `form:"pub_key_dsa" json:"pub_key_dsa" binding:"omitempty"`
 	PublicKeyRSA     string `form:"pub_key_rsa" json:"pub_key_rsa" binding:"omitempty"`
@@ -58,6 +64,109 @@ type BootParams struct {
 	Kernel    string    `json:"kernel,omitempty"`
 	Initrd    string    `json:"initrd,omitempty"`
 	CloudInit CloudInit `json:"cloud-init,omitempty"`
+	Profile   string    `json:"profile,omitempty"`
+
+	// Kernel, initrd and params for nodes of each architecture
+	ArchVariants map[string]ArchVariant `json:"arch-variants,omitempty"`
+
+	// Only set by GET, for use with If-Match.  It is ignored in requests.
+	ETag string `json:"etag,omitempty"`
+	// Only set by GET, the override the host boots with now, if any.
+	Override *BootOverride `json:"override,omitempty"`
+
+	// Only used by PATCH, to change individual kernel parameters
+	ParamsPatch *ParamsPatch `json:"params-patch,omitempty"`
+}
+
+// Changes to individual kernel parameters.  Each entry is a parameter as it
+// would appear on the command line, such as console=ttyS0 or quiet.  Remove
+// entries are applied first and take out every parameter with the key, or
+// only the exact parameter when given as key=value.  Set entries then replace
+// any parameters with the same key, and Add entries are appended.
+type ParamsPatch struct {
+	Set    []string `json:"set,omitempty"`
+	Add    []string `json:"add,omitempty"`
+	Remove []string `json:"remove,omitempty"`
+}
+
+// A named boot profile.  Hosts which reference a profile boot with its
+// kernel, initrd, params and cloud-init data, except where the host entry
+// sets its own values.
+type BootProfile struct {
+	Name      string    `json:"name"`
+	Params    string    `json:"params,omitempty"`
+	Kernel    string    `json:"kernel,omitempty"`
+	Initrd    string    `json:"initrd,omitempty"`
+	CloudInit CloudInit `json:"cloud-init,omitempty"`
+
+	ArchVariants map[string]ArchVariant `json:"arch-variants,omitempty"`
+}
+
+// The boot images of nodes with one architecture, such as x86_64 or arm64.
+// The kernel and initrd replace those of the entry and the params are
+// appended to its params.  In a PATCH, an empty variant removes the
+// architecture.
+type ArchVariant struct {
+	Params string `json:"params,omitempty"`
+	Kernel string `json:"kernel,omitempty"`
+	Initrd string `json:"initrd,omitempty"`
+}
+
+// Whether the nodes of a role with one architecture have a kernel to boot.
+type RoleArch struct {
+	Role    string   `json:"role"`
+	Arch    string   `json:"arch"`
+	Nodes   int      `json:"nodes"`
+	Missing []string `json:"missing,omitempty"` // Nodes with no kernel for the architecture
+}
+
+// Result status of each host of a boot parameters request
+const (
+	HostStored             = "stored"
+	HostUpdated            = "updated"
+	HostUnchanged          = "unchanged"
+	HostDeleted            = "deleted"
+	HostNotFound           = "not-found"
+	HostFailed             = "failed"
+	HostNotApplied         = "not-applied"         // Another host failed, so nothing was changed
+	HostPreconditionFailed = "precondition-failed" // The entry did not match If-Match
+)
+
+// The result for one host, MAC or NID of a boot parameters request.
+type HostResult struct {
+	Host   string `json:"host"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	ETag   string `json:"etag,omitempty"` // ETag of the entry after the request
+}
+
+// Returned by PUT, POST, PATCH and DELETE of /bootparameters.  The hosts are
+// all changed, or if any of them fails, none are.
+type HostResults struct {
+	Results []HostResult `json:"results"`
+}
+
+// A kernel argument and the parameter layer it came from.  For arguments
+// which did not make it into the command line, RemovedBy is the layer which
+// removed or replaced them.
+type ParamSource struct {
+	Arg       string `json:"arg"`
+	Layer     string `json:"layer"`
+	RemovedBy string `json:"removed-by,omitempty"`
+}
+
+// How the boot parameters of a node were put together from the parameter
+// layers: Global, Default, role, role:subrole, Group-<label> and the host.
+type ParamsExplanation struct {
+	Host        string        `json:"host"`
+	Layers      []string      `json:"layers"`
+	Params      string        `json:"params"`
+	Kernel      string        `json:"kernel,omitempty"`
+	KernelLayer string        `json:"kernel-layer,omitempty"`
+	Initrd      string        `json:"initrd,omitempty"`
+	InitrdLayer string        `json:"initrd-layer,omitempty"`
+	Args        []ParamSource `json:"args"`
+	Removed     []ParamSource `json:"removed,omitempty"`
 }
 
 // The following structures and types all related to the last access information for bootscripts and cloud-init data.
@@ -79,3 +188,302 @@ type EndpointAccess struct {
 	Endpoint  EndpointType `json:"endpoint"`
 	LastEpoch int64        `json:"last_epoch"`
 }
+
+// Host information as known to BSS.  This is the component data retrieved
+// from the Hardware State Manager, augmented with the FQDN, MAC addresses and
+// endpoint enablement of the node.  It is returned by the /hosts endpoint.
+type SMComponent struct {
+	base.Component
+	Fqdn            string   `json:"FQDN"`
+	Mac             []string `json:"MAC"`
+	EndpointEnabled bool     `json:"EndpointEnabled"`
+	Groups          []string `json:"Groups,omitempty"`
+}
+
+// The full component and boot parameter info returned by /dumpstate.
+type DumpState struct {
+	Components []SMComponent `json:"Components"`
+	Params     []BootParams  `json:"Params"`
+}
+
+// Service status as returned by the /service endpoints.  Only the items that
+// were requested are filled in.
+type ServiceStatus struct {
+	Version    string `json:"bss-version,omitempty"`
+	Status     string `json:"bss-status,omitempty"`
+	HSMStatus  string `json:"bss-status-hsm,omitempty"`
+	EctdStatus string `json:"bss-status-etcd,omitempty"`
+
+	HSMClient *HSMClientStats `json:"bss-hsm-client,omitempty"`
+	HSMState  *HSMStateStatus `json:"bss-hsm-state,omitempty"`
+	SCN       *SCNStatus      `json:"bss-scn,omitempty"`
+}
+
+// Types of boot activity events.
+const (
+	EventBootscript  = "bootscript"  // A node was served its boot script
+	EventDiscovery   = "discovery"   // An unknown node was served the discovery kernel
+	EventDelayed     = "delayed"     // A node was told to retry while BSS refreshes its state
+	EventBlacklisted = "blacklisted" // A node was refused a boot script because of its role
+	EventMetaData    = "meta-data"   // A node fetched its cloud-init meta-data
+	EventUserData    = "user-data"   // A node fetched its cloud-init user-data
+	EventPhoneHome   = "phone-home"  // A node phoned home from cloud-init
+)
+
+// An event of boot activity, as sent by /events and to webhooks.  ID
+// increases with each event BSS publishes, and starts again from 1 when BSS
+// restarts.  Kernel and Initrd are the images of a boot script, and Retry is
+// the number of times the node has chained back to BSS for it.
+type BootEvent struct {
+	ID      uint64 `json:"id"`
+	Type    string `json:"type"`
+	Time    string `json:"time"` // RFC3339
+	Xname   string `json:"xname,omitempty"`
+	Role    string `json:"role,omitempty"`
+	SubRole string `json:"subrole,omitempty"`
+	NID     string `json:"nid,omitempty"`
+	MAC     string `json:"mac,omitempty"`
+	IP      string `json:"ip,omitempty"`
+	Arch    string `json:"arch,omitempty"`
+	Kernel  string `json:"kernel,omitempty"`
+	Initrd  string `json:"initrd,omitempty"`
+	Retry   int    `json:"retry,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// States of a boot session, in the order a node normally goes through them.
+const (
+	BootRequested    = "requested"     // The node asked for its boot script
+	BootKernelServed = "kernel-served" // The node was served its kernel and initrd
+	BootCloudInit    = "cloud-init"    // The node fetched its cloud-init data
+	BootPhonedHome   = "phoned-home"   // The node phoned home from cloud-init
+	BootBooted       = "booted"        // HSM reported the node Ready
+)
+
+// A boot of a node, from its first boot script request until it is booted.
+// Sessions are keyed by the referral token of the node's boot parameters and
+// the node.  States has the time each state was reached, and MetaData and
+// UserData when the node last fetched them.  Retries is the highest retry=
+// of the boot script chain, and Attempts the number of times the node
+// started booting again before it phoned home.  A session is Stuck if the
+// node is in a boot loop or has made no progress for too long.
+type BootSession struct {
+	Xname         string            `json:"xname"`
+	ReferralToken string            `json:"referral-token"`
+	State         string            `json:"state"`
+	Started       string            `json:"started"` // RFC3339
+	Updated       string            `json:"updated"` // RFC3339
+	States        map[string]string `json:"states"`  // RFC3339
+	Kernel        string            `json:"kernel,omitempty"`
+	Initrd        string            `json:"initrd,omitempty"`
+	MetaData      string            `json:"meta-data,omitempty"` // RFC3339
+	UserData      string            `json:"user-data,omitempty"` // RFC3339
+	Retries       int               `json:"retries"`
+	Attempts      int               `json:"attempts"`
+	Stuck         bool              `json:"stuck"`
+	StuckReason   string            `json:"stuck-reason,omitempty"`
+}
+
+// States of the BSS subscription to state change notifications.
+const (
+	SCNPending    = "pending" // Waiting for the first HSM state
+	SCNSubscribed = "subscribed"
+	SCNFailed     = "failed" // The last attempt failed, and will be retried
+)
+
+// The subscription of BSS to state change notifications from hmnfd.
+// Components is the number of components subscribed to by name, and Roles
+// and SubRoles those subscribed to by role.  Subscribed and Verified are when
+// the subscription was last made and when hmnfd last confirmed it still has
+// it.  Attempts and Failures count the subscriptions made and failed since
+// BSS started.  Ignored counts the notifications BSS had no use for, and
+// Rejected those which failed verification or were invalid.
+type SCNStatus struct {
+	State         string   `json:"state"`
+	Subscriber    string   `json:"subscriber"`
+	URL           string   `json:"url"` // Where hmnfd sends notifications
+	Strategy      string   `json:"strategy"`
+	Components    int      `json:"components"`
+	Roles         []string `json:"roles,omitempty"`
+	SubRoles      []string `json:"subroles,omitempty"`
+	Subscribed    string   `json:"subscribed,omitempty"` // RFC3339
+	Verified      string   `json:"verified,omitempty"`   // RFC3339
+	NextCheck     string   `json:"next-check,omitempty"` // RFC3339
+	Attempts      int64    `json:"attempts"`
+	Failures      int64    `json:"failures"`
+	Ignored       int64    `json:"ignored"` // Notifications of nothing BSS uses
+	Rejected      int64    `json:"rejected"`
+	LastError     string   `json:"last-error,omitempty"`
+	LastErrorTime string   `json:"last-error-time,omitempty"` // RFC3339
+}
+
+// Sources of the HSM state BSS uses.
+const (
+	HSMSourceHSM      = "hsm"
+	HSMSourceSnapshot = "snapshot" // Saved by BSS when it last fetched the state
+	HSMSourceFile     = "file"     // An inventory file
+	HSMSourceEtcd     = "etcd"     // The inventory edited through /inventory
+	HSMSourceMem      = "mem"      // The canned inventory used for testing
+)
+
+// The HSM state BSS is using.  It is stale if it was loaded from a snapshot
+// at startup and hasn't been fetched from HSM since, or the last fetch
+// failed.  Updated is when it was fetched from HSM.
+type HSMStateStatus struct {
+	Source     string `json:"source"`
+	Updated    string `json:"updated,omitempty"` // RFC3339
+	Age        int64  `json:"age,omitempty"`     // Seconds
+	Stale      bool   `json:"stale"`
+	Components int    `json:"components"`
+}
+
+// HSM circuit breaker states.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open" // The next request will be tried
+)
+
+// Counters of the requests BSS has made to HSM since it started.  Failures
+// are requests which failed after all retries, and Rejected are those the
+// open circuit breaker didn't make.
+type HSMClientStats struct {
+	Requests      int64  `json:"requests"`
+	Retries       int64  `json:"retries"`
+	Failures      int64  `json:"failures"`
+	Timeouts      int64  `json:"timeouts"`
+	StatusErrors  int64  `json:"status-errors"`
+	DecodeErrors  int64  `json:"decode-errors"`
+	BreakerOpened int64  `json:"breaker-opened"`
+	Rejected      int64  `json:"rejected"`
+	BreakerState  string `json:"breaker-state"`
+	LastError     string `json:"last-error,omitempty"`
+	LastErrorTime string `json:"last-error-time,omitempty"` // RFC3339
+}
+
+// A site defined boot script template.  Template is a Go text/template which
+// is executed with the resolved kernel, initrd, params, xname, NID, MAC,
+// referral token and chain URL of the node.  The template is used for the
+// hosts, roles or Default listed in Hosts, for boot scripts of the given
+// Format (ipxe if empty).
+type BootTemplate struct {
+	Name     string   `json:"name"`
+	Format   string   `json:"format,omitempty"`
+	Hosts    []string `json:"hosts,omitempty"`
+	Template string   `json:"template"`
+}
+
+// A revision of a boot parameters, boot profile or image entry, as recorded
+// by each change to it.  Value is the entry after the change, and is absent
+// if the change deleted it.  Revision 1 of an entry which existed before its
+// history was kept has the action "existing".
+type Revision struct {
+	Revision int             `json:"revision"`
+	Time     string          `json:"time"`
+	Who      string          `json:"who,omitempty"`
+	Action   string          `json:"action"`
+	Rollback int             `json:"rollback,omitempty"` // Revision restored by a rollback
+	Value    json.RawMessage `json:"value,omitempty"`
+	Diff     []FieldChange   `json:"diff,omitempty"`
+}
+
+// A field of an entry changed by a revision.  Old or New is absent if the
+// field was added or removed.
+type FieldChange struct {
+	Field string          `json:"field"`
+	Old   json.RawMessage `json:"old,omitempty"`
+	New   json.RawMessage `json:"new,omitempty"`
+}
+
+// Restore a host's boot parameters, a boot profile, or an image to a prior
+// revision.  Only one of Host, Profile, Kernel or Initrd is given.
+type Rollback struct {
+	Host     string `json:"host,omitempty"`
+	Profile  string `json:"profile,omitempty"`
+	Kernel   string `json:"kernel,omitempty"`
+	Initrd   string `json:"initrd,omitempty"`
+	Revision int    `json:"revision"`
+}
+
+// A boot override, which replaces the params, kernel or initrd its hosts boot
+// with, either for a single boot or between the Start and End times (RFC
+// 3339).  Hosts may be xnames, roles or Default.  A one-shot override is used
+// once by each of its hosts, and Consumed, which is read only, has the time
+// each of them booted with it.
+type BootOverride struct {
+	Name     string            `json:"name"`
+	Hosts    []string          `json:"hosts"`
+	Params   string            `json:"params,omitempty"`
+	Kernel   string            `json:"kernel,omitempty"`
+	Initrd   string            `json:"initrd,omitempty"`
+	OneShot  bool              `json:"one-shot,omitempty"`
+	Start    string            `json:"start,omitempty"`
+	End      string            `json:"end,omitempty"`
+	Consumed map[string]string `json:"consumed,omitempty"`
+}
+
+// A kernel or initrd image in the image catalog.  ID identifies the image
+// along with its Type.  RefCount is the number of boot parameters and boot
+// profile entries which reference the image.  References lists them, and
+// Hosts the hosts and roles which boot with the image either directly or
+// through a profile; both are only returned for a single image.
+type Image struct {
+	ID         string            `json:"id"`
+	Type       string            `json:"type"`
+	Path       string            `json:"path"`
+	Params     string            `json:"params,omitempty"`
+	Name       string            `json:"name,omitempty"`
+	Version    string            `json:"version,omitempty"`
+	Arch       string            `json:"arch,omitempty"`
+	Size       int64             `json:"size,omitempty"`
+	Sha256     string            `json:"sha256,omitempty"`
+	Created    string            `json:"created,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	RefCount   int               `json:"ref-count"`
+	References []string          `json:"references,omitempty"`
+	Hosts      []string          `json:"hosts,omitempty"`
+	Validation *ImageStatus      `json:"validation,omitempty"`
+}
+
+// Image validation status values.
+const (
+	ImageOK               = "ok"
+	ImageUnreachable      = "unreachable"
+	ImageChecksumMismatch = "checksum-mismatch"
+	ImageSkipped          = "skipped" // Not an http, https or s3 URL
+)
+
+// The result of the last check of an image's URL.  Size and Sha256 are what
+// the server reported or, for Sha256, what the image was found to hash to.
+type ImageStatus struct {
+	Status     string `json:"status"`
+	Checked    string `json:"checked"` // RFC3339
+	HTTPStatus int    `json:"http-status,omitempty"`
+	Size       int64  `json:"size,omitempty"`
+	Sha256     string `json:"sha256,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// A component of an inventory file or the etcd inventory, used instead of
+// HSM.  State defaults to Ready and Enabled to true.  IP lists the addresses
+// the node makes cloud-init requests from.
+type InventoryComponent struct {
+	ID      string   `json:"id"`
+	NID     int      `json:"nid,omitempty"`
+	Role    string   `json:"role,omitempty"`
+	SubRole string   `json:"subrole,omitempty"`
+	Arch    string   `json:"arch,omitempty"`
+	State   string   `json:"state,omitempty"`
+	Enabled *bool    `json:"enabled,omitempty"`
+	FQDN    string   `json:"fqdn,omitempty"`
+	MAC     []string `json:"mac,omitempty"`
+	IP      []string `json:"ip,omitempty"`
+	Groups  []string `json:"groups,omitempty"`
+}
+
+// The components BSS boots, as returned by /inventory.  Source is where they
+// came from, one of the HSMSource values.
+type Inventory struct {
+	Source     string               `json:"source,omitempty"`
+	Components []InventoryComponent `json:"components"`
+}
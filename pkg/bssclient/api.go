@@ -0,0 +1,211 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package bssclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+// Selects the hosts for /bootscript.  Exactly one of Mac, Name or Nid should
+// be set.  Nid is only used when it is greater than zero.
+type BootscriptQuery struct {
+	Mac    string
+	Name   string
+	Nid    int
+	Arch   string
+	Retry  int
+	Format string // ipxe or grub, empty for the server default
+}
+
+// Selects the hosts for /hosts.  An empty query returns all hosts.
+type HostsQuery struct {
+	Names []string
+	Macs  []string
+	Nids  []int32
+}
+
+func nidStrings(nids []int32) []string {
+	var ret []string
+	for _, n := range nids {
+		ret = append(ret, strconv.FormatInt(int64(n), 10))
+	}
+	return ret
+}
+
+// GetAllBootParameters returns every boot parameter entry known to BSS,
+// including the kernel and initrd image entries.
+func (c *Client) GetAllBootParameters(ctx context.Context) ([]bssTypes.BootParams, error) {
+	var ret []bssTypes.BootParams
+	_, err := c.doJSON(ctx, http.MethodGet, "/bootparameters", nil, nil, &ret)
+	return ret, err
+}
+
+// GetBootParameters returns the boot parameters for the hosts, MACs and NIDs
+// in bp.  If bp names a kernel or initrd image, the request is sent as a body
+// so that the image entries are returned as well.
+func (c *Client) GetBootParameters(ctx context.Context, bp bssTypes.BootParams) ([]bssTypes.BootParams, error) {
+	var ret []bssTypes.BootParams
+	if bp.Kernel != "" || bp.Initrd != "" {
+		_, err := c.doJSON(ctx, http.MethodGet, "/bootparameters", nil, bp, &ret)
+		return ret, err
+	}
+	q := url.Values{}
+	if len(bp.Hosts) > 0 {
+		q.Set("name", strings.Join(bp.Hosts, ","))
+	}
+	if len(bp.Macs) > 0 {
+		q.Set("mac", strings.Join(bp.Macs, ","))
+	}
+	if len(bp.Nids) > 0 {
+		q.Set("nid", strings.Join(nidStrings(bp.Nids), ","))
+	}
+	_, err := c.doJSON(ctx, http.MethodGet, "/bootparameters", q, nil, &ret)
+	return ret, err
+}
+
+// PutBootParameters creates or replaces boot parameters.  The referral token
+// assigned to the hosts, if any, is returned.
+func (c *Client) PutBootParameters(ctx context.Context, bp bssTypes.BootParams) (string, error) {
+	rsp, err := c.doJSON(ctx, http.MethodPut, "/bootparameters", nil, bp, nil)
+	if err != nil {
+		return "", err
+	}
+	return rsp.Header.Get(ReferralTokenHeader), nil
+}
+
+// PostBootParameters creates new boot parameters.  It fails if any of the
+// hosts or images already exist.
+func (c *Client) PostBootParameters(ctx context.Context, bp bssTypes.BootParams) (string, error) {
+	rsp, err := c.doJSON(ctx, http.MethodPost, "/bootparameters", nil, bp, nil)
+	if err != nil {
+		return "", err
+	}
+	return rsp.Header.Get(ReferralTokenHeader), nil
+}
+
+// PatchBootParameters updates the fields set in bp for existing entries.
+func (c *Client) PatchBootParameters(ctx context.Context, bp bssTypes.BootParams) error {
+	_, err := c.doJSON(ctx, http.MethodPatch, "/bootparameters", nil, bp, nil)
+	return err
+}
+
+// DeleteBootParameters removes the entries selected by bp.
+func (c *Client) DeleteBootParameters(ctx context.Context, bp bssTypes.BootParams) error {
+	_, err := c.doJSON(ctx, http.MethodDelete, "/bootparameters", nil, bp, nil)
+	return err
+}
+
+// GetBootscript returns the boot script BSS would serve to the selected node.
+func (c *Client) GetBootscript(ctx context.Context, q BootscriptQuery) (string, error) {
+	v := url.Values{}
+	switch {
+	case q.Mac != "":
+		v.Set("mac", q.Mac)
+	case q.Name != "":
+		v.Set("name", q.Name)
+	case q.Nid > 0:
+		v.Set("nid", strconv.Itoa(q.Nid))
+	default:
+		return "", errors.New("bssclient: bootscript query needs a MAC, name or NID")
+	}
+	if q.Arch != "" {
+		v.Set("arch", q.Arch)
+	}
+	if q.Format != "" {
+		v.Set("format", q.Format)
+	}
+	if q.Retry > 0 {
+		v.Set("retry", strconv.Itoa(q.Retry))
+	}
+	_, body, err := c.do(ctx, http.MethodGet, "/bootscript", v, nil, nil)
+	return string(body), err
+}
+
+// GetHosts returns the host information BSS holds from the state manager.
+func (c *Client) GetHosts(ctx context.Context, q HostsQuery) ([]bssTypes.SMComponent, error) {
+	v := url.Values{}
+	if len(q.Names) > 0 {
+		v.Set("name", strings.Join(q.Names, ","))
+	}
+	if len(q.Macs) > 0 {
+		v.Set("mac", strings.Join(q.Macs, ","))
+	}
+	if len(q.Nids) > 0 {
+		v.Set("nid", strings.Join(nidStrings(q.Nids), ","))
+	}
+	var ret []bssTypes.SMComponent
+	_, err := c.doJSON(ctx, http.MethodGet, "/hosts", v, nil, &ret)
+	return ret, err
+}
+
+// RefreshHosts asks BSS to retrieve current state from the state manager.
+func (c *Client) RefreshHosts(ctx context.Context) error {
+	_, err := c.doJSON(ctx, http.MethodPost, "/hosts", nil, nil, nil)
+	return err
+}
+
+// GetEndpointHistory returns the last access times for bootscript and
+// cloud-init endpoints.  Either argument may be empty to widen the search,
+// but a type without a name is rejected by BSS.
+func (c *Client) GetEndpointHistory(ctx context.Context, name string, endpoint bssTypes.EndpointType) ([]bssTypes.EndpointAccess, error) {
+	v := url.Values{}
+	if name != "" {
+		v.Set("name", name)
+	}
+	if endpoint != "" {
+		v.Set("endpoint", string(endpoint))
+	}
+	var ret []bssTypes.EndpointAccess
+	_, err := c.doJSON(ctx, http.MethodGet, "/endpoint-history", v, nil, &ret)
+	return ret, err
+}
+
+// GetServiceStatus queries one of the /service endpoints, e.g. "status",
+// "version", "hsm", "etcd" or "status/all".  BSS reports failed checks with
+// a 500 and a status body, so the decoded status is returned along with the
+// error in that case.
+func (c *Client) GetServiceStatus(ctx context.Context, item string) (bssTypes.ServiceStatus, error) {
+	var ret bssTypes.ServiceStatus
+	_, body, err := c.do(ctx, http.MethodGet, "/service/"+strings.TrimLeft(item, "/"), nil, nil, nil)
+	if len(body) > 0 {
+		if e := json.Unmarshal(body, &ret); e != nil && err == nil {
+			err = e
+		}
+	}
+	return ret, err
+}
+
+// DumpState returns the hosts and boot parameters held by BSS.
+func (c *Client) DumpState(ctx context.Context) (bssTypes.DumpState, error) {
+	var ret bssTypes.DumpState
+	_, err := c.doJSON(ctx, http.MethodGet, "/dumpstate", nil, nil, &ret)
+	return ret, err
+}
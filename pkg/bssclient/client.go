@@ -0,0 +1,201 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+//
+// Go client for the Boot Script Service REST API.
+//
+// The client speaks the /boot/v1 API using the same bssTypes structures as
+// the service itself.  Errors returned by the service as RFC7807 problem
+// details are decoded into *Error values.
+//
+
+package bssclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	basePath = "/boot/v1"
+
+	DefaultRetries   = 3
+	DefaultRetryWait = 1 * time.Second
+	DefaultTimeout   = 30 * time.Second
+
+	// The header carrying the referral token on PUT and POST responses.
+	ReferralTokenHeader = "BSS-Referral-Token"
+)
+
+// Client for a single BSS instance.  The exported fields may be adjusted
+// after NewClient() and before the first request is made.
+type Client struct {
+	BaseURL    string       // e.g. https://api-gw-service-nmn.local/apis/bss
+	HTTPClient *http.Client // Client used for all requests
+	UserAgent  string       // Sent as the User-Agent header if not empty
+	Token      string       // Sent as a bearer token if not empty
+	Retries    int          // Retries for idempotent requests
+	RetryWait  time.Duration
+}
+
+// NewClient returns a client for the BSS instance at baseURL, which should
+// include any API gateway prefix but not the /boot/v1 portion of the path.
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+		UserAgent:  "bssclient",
+		Retries:    DefaultRetries,
+		RetryWait:  DefaultRetryWait,
+	}
+}
+
+// Methods which are safe to resend after a failure.  POST creates new
+// entries and fails if they exist, so it is never retried.
+func retryable(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (c *Client) url(path string, query url.Values) string {
+	u := c.BaseURL + basePath + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+// Issue a request and return the response with the body fully read.  A
+// non-2xx status is converted to an *Error.  Requests are retried with a
+// linear backoff on transport errors and on gateway/overload responses.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in interface{}, hdr http.Header) (*http.Response, []byte, error) {
+	var payload []byte
+	if in != nil {
+		var err error
+		payload, err = json.Marshal(in)
+		if err != nil {
+			return nil, nil, fmt.Errorf("bssclient: marshalling request: %w", err)
+		}
+	}
+	attempts := 1
+	if retryable(method) && c.Retries > 0 {
+		attempts += c.Retries
+	}
+	var (
+		rsp  *http.Response
+		body []byte
+		err  error
+	)
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, nil, ctx.Err()
+			case <-time.After(time.Duration(i) * c.RetryWait):
+			}
+		}
+		rsp, body, err = c.doOnce(ctx, method, c.url(path, query), payload, hdr)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			continue
+		}
+		if !retryableStatus(rsp.StatusCode) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		return rsp, body, newError(rsp, body)
+	}
+	return rsp, body, nil
+}
+
+func (c *Client) doOnce(ctx context.Context, method, u string, payload []byte, hdr http.Header) (*http.Response, []byte, error) {
+	var rdr io.Reader
+	if payload != nil {
+		rdr = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, rdr)
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, v := range hdr {
+		req.Header[k] = v
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	rsp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rsp.Body.Close()
+	body, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rsp, body, nil
+}
+
+// Issue a request and decode a JSON response into out, if out is not nil.
+func (c *Client) doJSON(ctx context.Context, method, path string, query url.Values, in, out interface{}) (*http.Response, error) {
+	rsp, body, err := c.do(ctx, method, path, query, in, nil)
+	if err != nil {
+		return rsp, err
+	}
+	if out != nil && len(bytes.TrimSpace(body)) > 0 {
+		if err = json.Unmarshal(body, out); err != nil {
+			return rsp, fmt.Errorf("bssclient: decoding %s %s response: %w", method, path, err)
+		}
+	}
+	return rsp, nil
+}
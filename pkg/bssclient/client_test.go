@@ -0,0 +1,147 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package bssclient
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Cray-HPE/hms-bss/pkg/bssTypes"
+)
+
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	c := NewClient(ts.URL + "/")
+	c.RetryWait = time.Millisecond
+	return c
+}
+
+func TestGetBootParametersQuery(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/boot/v1/bootparameters" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if got := r.URL.Query().Get("name"); got != "x0c0s1b0n0,x0c0s2b0n0" {
+			t.Errorf("Unexpected name query %q", got)
+		}
+		if got := r.URL.Query().Get("nid"); got != "1" {
+			t.Errorf("Unexpected nid query %q", got)
+		}
+		json.NewEncoder(w).Encode([]bssTypes.BootParams{{Hosts: []string{"x0c0s1b0n0"}, Kernel: "k"}})
+	})
+	bp := bssTypes.BootParams{Hosts: []string{"x0c0s1b0n0", "x0c0s2b0n0"}, Nids: []int32{1}}
+	ret, err := c.GetBootParameters(context.Background(), bp)
+	if err != nil {
+		t.Fatalf("GetBootParameters failed: %v", err)
+	}
+	if len(ret) != 1 || ret[0].Kernel != "k" {
+		t.Errorf("Unexpected result %+v", ret)
+	}
+}
+
+func TestPutBootParametersToken(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("Unexpected method %s", r.Method)
+		}
+		var bp bssTypes.BootParams
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &bp); err != nil || bp.Params != "console=ttyS0" {
+			t.Errorf("Unexpected body %s", body)
+		}
+		w.Header().Set(ReferralTokenHeader, "abc")
+	})
+	tok, err := c.PutBootParameters(context.Background(),
+		bssTypes.BootParams{Hosts: []string{"Default"}, Params: "console=ttyS0"})
+	if err != nil {
+		t.Fatalf("PutBootParameters failed: %v", err)
+	}
+	if tok != "abc" {
+		t.Errorf("Expected referral token abc, got %q", tok)
+	}
+}
+
+func TestProblemDetailsError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"type":"about:blank","title":"Not Found","detail":"Cannot find host","status":404}`))
+	})
+	_, err := c.GetBootscript(context.Background(), BootscriptQuery{Name: "x0c0s1b0n0"})
+	if !IsNotFound(err) {
+		t.Fatalf("Expected a not found error, got %v", err)
+	}
+	if e := err.(*Error); e.Detail != "Cannot find host" {
+		t.Errorf("Unexpected detail %q", e.Detail)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	calls := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("#!ipxe\n"))
+	})
+	script, err := c.GetBootscript(context.Background(), BootscriptQuery{Nid: 1})
+	if err != nil {
+		t.Fatalf("GetBootscript failed: %v", err)
+	}
+	if calls != 3 || script != "#!ipxe\n" {
+		t.Errorf("Expected 3 calls and a script, got %d and %q", calls, script)
+	}
+
+	// POST is not idempotent and must only be tried once
+	calls = 0
+	c = newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	_, err = c.PostBootParameters(context.Background(), bssTypes.BootParams{Hosts: []string{"Default"}})
+	if StatusCode(err) != http.StatusServiceUnavailable || calls != 1 {
+		t.Errorf("Expected a single failed POST, got %d calls and %v", calls, err)
+	}
+}
+
+func TestServiceStatusFailure(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"bss-status-etcd":"error"}`))
+	})
+	st, err := c.GetServiceStatus(context.Background(), "etcd")
+	if StatusCode(err) != http.StatusInternalServerError {
+		t.Errorf("Expected a 500 error, got %v", err)
+	}
+	if st.EctdStatus != "error" {
+		t.Errorf("Expected etcd status to be decoded, got %+v", st)
+	}
+}
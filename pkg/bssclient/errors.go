@@ -0,0 +1,75 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package bssclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Error is a non-2xx response from BSS.  BSS reports errors as RFC7807
+// problem details, which are decoded into the corresponding fields.  If the
+// body was not a problem details document, Detail holds the raw body.
+type Error struct {
+	StatusCode int    `json:"-"`
+	Type       string `json:"type,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+	Instance   string `json:"instance,omitempty"`
+	Status     int    `json:"status,omitempty"`
+}
+
+func (e *Error) Error() string {
+	msg := e.Title
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return fmt.Sprintf("bss: %d %s", e.StatusCode, msg)
+}
+
+func newError(rsp *http.Response, body []byte) *Error {
+	e := &Error{StatusCode: rsp.StatusCode}
+	if json.Unmarshal(body, e) != nil || (e.Title == "" && e.Detail == "") {
+		e.Detail = strings.TrimSpace(string(body))
+	}
+	return e
+}
+
+// StatusCode returns the HTTP status of err if it is (or wraps) an *Error,
+// otherwise zero.
+func StatusCode(err error) int {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.StatusCode
+	}
+	return 0
+}
+
+func IsNotFound(err error) bool   { return StatusCode(err) == http.StatusNotFound }
+func IsBadRequest(err error) bool { return StatusCode(err) == http.StatusBadRequest }
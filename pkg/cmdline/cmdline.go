@@ -0,0 +1,281 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+//
+// Kernel command line parsing.
+//
+// A command line is a list of space separated parameters, each either a bare
+// flag such as "quiet" or a key=value pair.  Values may be quoted with single
+// or double quotes to include spaces, and a key may be repeated, as with
+// console=.  Everything after a "--" word is passed on to init rather than
+// interpreted by the kernel.
+//
+
+package cmdline
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A single kernel parameter.
+type Param struct {
+	Key      string
+	Value    string
+	HasValue bool // "key=" rather than just "key"
+	quote    rune // Quote character used in the original value, if any
+}
+
+// A parsed kernel command line.
+type CmdLine struct {
+	Params  []Param
+	Init    []string // Words after "--", passed to init as they are
+	hasInit bool
+}
+
+// Split a command line into words, keeping any quotes.  An error is returned
+// for an unterminated quote, along with the words found, the last of which
+// runs to the end of the line.
+func Fields(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	var quote rune
+	for _, c := range s {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ' ' || c == '\t' || c == '\n':
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+			continue
+		}
+		word.WriteRune(c)
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	if quote != 0 {
+		return words, fmt.Errorf("unterminated %c quote in '%s'", quote, s)
+	}
+	return words, nil
+}
+
+// Remove the quotes from a word, returning the first quote character used.
+func unquote(word string) (string, rune) {
+	var ret strings.Builder
+	var quote, first rune
+	for _, c := range word {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			ret.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote = c
+			if first == 0 {
+				first = c
+			}
+		default:
+			ret.WriteRune(c)
+		}
+	}
+	return ret.String(), first
+}
+
+// Split a command line into words with the quotes removed, such as
+// root="live:LABEL=my root" becoming root=live:LABEL=my root.
+func Split(s string) []string {
+	words, _ := Fields(s)
+	for i, w := range words {
+		words[i], _ = unquote(w)
+	}
+	return words
+}
+
+// Parse a single word of a command line.
+func ParseParam(word string) Param {
+	var p Param
+	// The key ends at the first '=' outside of quotes
+	var quote rune
+	end := -1
+	for i, c := range word {
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+		} else if c == '"' || c == '\'' {
+			quote = c
+		} else if c == '=' {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		p.Key, _ = unquote(word)
+		return p
+	}
+	p.Key, _ = unquote(word[:end])
+	p.Value, p.quote = unquote(word[end+1:])
+	p.HasValue = true
+	return p
+}
+
+// The parameter as it appears on a command line.  A value keeps its original
+// quotes, and is double quoted if it needs quotes and had none.
+func (p Param) String() string {
+	if !p.HasValue {
+		return p.Key
+	}
+	q := p.quote
+	if q == 0 && strings.ContainsAny(p.Value, " \t\n\"'") {
+		q = '"'
+		if strings.ContainsRune(p.Value, '"') {
+			q = '\''
+		}
+	}
+	if q == 0 {
+		return p.Key + "=" + p.Value
+	}
+	return p.Key + "=" + string(q) + p.Value + string(q)
+}
+
+// Parse a command line.  As with Fields, an error is returned for an
+// unterminated quote along with the parameters which could be parsed.
+func Parse(s string) (*CmdLine, error) {
+	words, err := Fields(s)
+	c := &CmdLine{}
+	for i, w := range words {
+		if w == "--" {
+			c.hasInit = true
+			c.Init = append(c.Init, words[i+1:]...)
+			break
+		}
+		c.Params = append(c.Params, ParseParam(w))
+	}
+	return c, err
+}
+
+// The command line as a string.
+func (c *CmdLine) String() string {
+	words := make([]string, 0, len(c.Params)+len(c.Init)+1)
+	for _, p := range c.Params {
+		words = append(words, p.String())
+	}
+	if c.hasInit || len(c.Init) > 0 {
+		words = append(words, "--")
+		words = append(words, c.Init...)
+	}
+	return strings.Join(words, " ")
+}
+
+// Check whether a parameter is present, with or without a value.
+func (c *CmdLine) Has(key string) bool {
+	for _, p := range c.Params {
+		if p.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the value of the first parameter with the given key.
+func (c *CmdLine) Get(key string) (string, bool) {
+	for _, p := range c.Params {
+		if p.Key == key {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// Get the values of every parameter with the given key, in order.
+func (c *CmdLine) GetAll(key string) []string {
+	var ret []string
+	for _, p := range c.Params {
+		if p.Key == key {
+			ret = append(ret, p.Value)
+		}
+	}
+	return ret
+}
+
+// Append a parameter, keeping any others with the same key.
+func (c *CmdLine) Add(p Param) {
+	c.Params = append(c.Params, p)
+}
+
+// Replace the parameters with the key of p by p, keeping the position of the
+// first of them.  If there are none, p is appended.
+func (c *CmdLine) Set(p Param) {
+	kept := c.Params[:0:0]
+	replaced := false
+	for _, old := range c.Params {
+		if old.Key != p.Key {
+			kept = append(kept, old)
+		} else if !replaced {
+			kept = append(kept, p)
+			replaced = true
+		}
+	}
+	if !replaced {
+		kept = append(kept, p)
+	}
+	c.Params = kept
+}
+
+// Add key=value if there is no parameter with the key already.  An empty
+// value is not added.
+func (c *CmdLine) SetDefault(key, value string) {
+	if value != "" && !c.Has(key) {
+		c.Add(Param{Key: key, Value: value, HasValue: true})
+	}
+}
+
+// Remove every parameter with the given key, returning how many there were.
+func (c *CmdLine) Remove(key string) int {
+	return c.removeIf(func(p Param) bool { return p.Key == key })
+}
+
+// Remove the parameters matching p exactly, returning how many there were.
+func (c *CmdLine) RemoveParam(p Param) int {
+	return c.removeIf(func(o Param) bool {
+		return o.Key == p.Key && o.HasValue == p.HasValue && o.Value == p.Value
+	})
+}
+
+func (c *CmdLine) removeIf(match func(Param) bool) int {
+	kept := c.Params[:0:0]
+	for _, p := range c.Params {
+		if !match(p) {
+			kept = append(kept, p)
+		}
+	}
+	n := len(c.Params) - len(kept)
+	c.Params = kept
+	return n
+}
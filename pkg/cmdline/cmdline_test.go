@@ -0,0 +1,127 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package cmdline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in     string
+		params []Param
+		init   []string
+		out    string
+	}{
+		{"", nil, nil, ""},
+		{"  quiet   console=ttyS0,115200n8 ", []Param{
+			{Key: "quiet"},
+			{Key: "console", Value: "ttyS0,115200n8", HasValue: true},
+		}, nil, "quiet console=ttyS0,115200n8"},
+		{`root='live:LABEL=my root' rd.break=`, []Param{
+			{Key: "root", Value: "live:LABEL=my root", HasValue: true, quote: '\''},
+			{Key: "rd.break", HasValue: true},
+		}, nil, `root='live:LABEL=my root' rd.break=`},
+		{`a="x=y" b`, []Param{
+			{Key: "a", Value: "x=y", HasValue: true, quote: '"'},
+			{Key: "b"},
+		}, nil, `a="x=y" b`},
+		{"init=/bin/sh -- single 'a b'", []Param{
+			{Key: "init", Value: "/bin/sh", HasValue: true},
+		}, []string{"single", "'a b'"}, "init=/bin/sh -- single 'a b'"},
+		{"quiet --", []Param{{Key: "quiet"}}, nil, "quiet --"},
+	}
+	for _, tt := range tests {
+		c, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(c.Params, tt.params) || !reflect.DeepEqual(c.Init, tt.init) {
+			t.Errorf("Parse(%q) = %+v %q, expected %+v %q", tt.in, c.Params, c.Init, tt.params, tt.init)
+		}
+		if s := c.String(); s != tt.out {
+			t.Errorf("Parse(%q).String() = %q, expected %q", tt.in, s, tt.out)
+		}
+	}
+	if _, err := Parse(`root="live:LABEL=x quiet`); err == nil {
+		t.Errorf("Expected an unterminated quote to fail")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	got := Split(`console=ttyS0 root='live:LABEL=my root' -- x`)
+	want := []string{"console=ttyS0", "root=live:LABEL=my root", "--", "x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Split() = %q, expected %q", got, want)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	c, _ := Parse("nidfoo=1 console=tty0 quiet console=ttyS0 -- single")
+	if c.Has("nid") || !c.Has("nidfoo") || !c.Has("quiet") {
+		t.Errorf("Has() matched the wrong keys")
+	}
+	if v, ok := c.Get("console"); !ok || v != "tty0" {
+		t.Errorf("Get(console) = %q, %v", v, ok)
+	}
+	if v := c.GetAll("console"); !reflect.DeepEqual(v, []string{"tty0", "ttyS0"}) {
+		t.Errorf("GetAll(console) = %q", v)
+	}
+
+	c.SetDefault("nid", "4")
+	c.SetDefault("nidfoo", "2")
+	c.SetDefault("xname", "")
+	c.Set(ParseParam("console=ttyS1,115200"))
+	c.Add(ParseParam("rd.shell"))
+	if n := c.Remove("quiet"); n != 1 {
+		t.Errorf("Remove(quiet) removed %d", n)
+	}
+	want := "nidfoo=1 console=ttyS1,115200 nid=4 rd.shell -- single"
+	if s := c.String(); s != want {
+		t.Errorf("Edited command line %q, expected %q", s, want)
+	}
+
+	c.Add(ParseParam("console=tty0"))
+	if n := c.RemoveParam(ParseParam("console=tty0")); n != 1 || len(c.GetAll("console")) != 1 {
+		t.Errorf("RemoveParam(console=tty0) removed %d, left %q", n, c.GetAll("console"))
+	}
+}
+
+func TestParamString(t *testing.T) {
+	tests := []struct {
+		p    Param
+		want string
+	}{
+		{Param{Key: "quiet"}, "quiet"},
+		{Param{Key: "a", HasValue: true}, "a="},
+		{Param{Key: "root", Value: "live:LABEL=my root", HasValue: true}, `root="live:LABEL=my root"`},
+		{Param{Key: "x", Value: `say "hi"`, HasValue: true}, `x='say "hi"'`},
+	}
+	for _, tt := range tests {
+		if s := tt.p.String(); s != tt.want {
+			t.Errorf("%+v.String() = %q, expected %q", tt.p, s, tt.want)
+		}
+	}
+}